@@ -0,0 +1,233 @@
+// Package cbz converts image based epubs, like manga or comics, to CBZ
+// files and back
+//
+// A CBZ is a zip with an image per page, ordered by their names, and a
+// ComicInfo.xml with the metadata:
+//
+//	book, _ := raw.NewEpub("path/of/the/manga.epub")
+//	err := cbz.WriteFile(book, "manga.cbz")
+package cbz
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ssor/epubgo/internal/docutil"
+	"github.com/ssor/epubgo/raw"
+	"golang.org/x/net/html"
+)
+
+// ComicInfo is the ComicInfo.xml of a CBZ
+type ComicInfo struct {
+	XMLName     xml.Name `xml:"ComicInfo"`
+	Title       string   `xml:"Title,omitempty"`
+	Summary     string   `xml:"Summary,omitempty"`
+	Year        int      `xml:"Year,omitempty"`
+	Month       int      `xml:"Month,omitempty"`
+	Day         int      `xml:"Day,omitempty"`
+	Writer      string   `xml:"Writer,omitempty"`
+	Publisher   string   `xml:"Publisher,omitempty"`
+	Genre       string   `xml:"Genre,omitempty"`
+	PageCount   int      `xml:"PageCount,omitempty"`
+	LanguageISO string   `xml:"LanguageISO,omitempty"`
+	// Manga is "YesAndRightToLeft" for the books read from right to left
+	Manga string `xml:"Manga,omitempty"`
+}
+
+// IsRightToLeft returns whether the pages are read from right to left
+func (info ComicInfo) IsRightToLeft() bool {
+	return info.Manga == "YesAndRightToLeft"
+}
+
+// WriteFile writes the pages of the epub on the CBZ file path
+func WriteFile(e *raw.Epub, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := Write(e, f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Write writes the pages of the epub on w as a CBZ
+//
+// Every page of the spine has to be an image, or a document with a single
+// <img> or <svg><image>, otherwise it returns an error. The pages are
+// named by their position on the spine, the reading direction goes on the
+// Manga field of the ComicInfo.xml.
+func Write(e *raw.Epub, w io.Writer) error {
+	pages, err := pageImages(e)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	zw := zip.NewWriter(w)
+	width := len(strconv.Itoa(len(pages)))
+	if width < 3 {
+		width = 3
+	}
+	for i, page := range pages {
+		ext := strings.ToLower(path.Ext(page))
+		if ext == ".jpeg" {
+			ext = ".jpg"
+		}
+		if err := copyImage(e, zw, page, fmt.Sprintf("%0*d%s", width, i+1, ext), now); err != nil {
+			return err
+		}
+	}
+
+	info := comicInfo(e)
+	info.PageCount = len(pages)
+	data, err := xml.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: "ComicInfo.xml", Method: zip.Deflate, Modified: now})
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append([]byte(xml.Header), data...)); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func copyImage(e *raw.Epub, zw *zip.Writer, href, name string, modified time.Time) error {
+	src, err := e.OpenResource(href)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	// the images are already compressed
+	dst, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: modified})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	return err
+}
+
+// pageImages returns the image of each page of the spine
+func pageImages(e *raw.Epub) ([]string, error) {
+	it, err := e.Spine()
+	if err != nil {
+		return nil, err
+	}
+	images := []string{}
+	for i := 0; ; i++ {
+		href := it.URL()
+		item := e.FileManifest(href)
+		switch {
+		case item == nil:
+			return nil, errors.New("The page " + href + " is not on the manifest")
+		case strings.HasPrefix(item.MediaType, "image/"):
+			images = append(images, href)
+		default:
+			doc, err := e.ParseDocument(href)
+			if err != nil {
+				return nil, err
+			}
+			src, ok := singleImage(doc)
+			if !ok {
+				return nil, errors.New("The page " + strconv.Itoa(i) + " (" + href + ") is not a single image")
+			}
+			images = append(images, docutil.ResolveHref(href, src))
+		}
+		if it.Next() != nil {
+			break
+		}
+	}
+	return images, nil
+}
+
+// singleImage returns the src of the only image of the document, false if
+// the document has text or more than one image
+func singleImage(doc *html.Node) (string, bool) {
+	srcs := []string{}
+	hasText := false
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			if strings.TrimSpace(n.Data) != "" {
+				hasText = true
+			}
+		case n.Type == html.ElementNode && n.Namespace == "" && n.Data == "img":
+			srcs = append(srcs, docutil.Attr(n, "src"))
+		case n.Type == html.ElementNode && n.Namespace == "svg" && n.Data == "image":
+			src := docutil.Attr(n, "xlink:href")
+			if src == "" {
+				src = docutil.Attr(n, "href")
+			}
+			srcs = append(srcs, src)
+		case n.Type == html.ElementNode && (n.Data == "head" || n.Data == "script" || n.Data == "style"):
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	if hasText || len(srcs) != 1 || srcs[0] == "" {
+		return "", false
+	}
+	src := srcs[0]
+	if i := strings.IndexAny(src, "#?"); i >= 0 {
+		src = src[:i]
+	}
+	return src, true
+}
+
+// comicInfo builds the ComicInfo from the metadata of the epub
+func comicInfo(e *raw.Epub) ComicInfo {
+	first := func(field string) string {
+		values, _ := e.Metadata(field)
+		if len(values) == 0 {
+			return ""
+		}
+		return strings.TrimSpace(values[0])
+	}
+	all := func(field string) string {
+		values, _ := e.Metadata(field)
+		trimmed := []string{}
+		for _, v := range values {
+			if v = strings.TrimSpace(v); v != "" {
+				trimmed = append(trimmed, v)
+			}
+		}
+		return strings.Join(trimmed, ", ")
+	}
+
+	info := ComicInfo{
+		Title:       first("title"),
+		Summary:     first("description"),
+		Writer:      all("creator"),
+		Publisher:   first("publisher"),
+		Genre:       all("subject"),
+		LanguageISO: first("language"),
+	}
+	date := strings.SplitN(first("date"), "-", 3)
+	info.Year, _ = strconv.Atoi(date[0])
+	if len(date) > 1 {
+		info.Month, _ = strconv.Atoi(date[1])
+	}
+	if len(date) > 2 {
+		info.Day, _ = strconv.Atoi(strings.SplitN(date[2], "T", 2)[0])
+	}
+	if e.PageProgressionDirection() == "rtl" {
+		info.Manga = "YesAndRightToLeft"
+	}
+	return info
+}
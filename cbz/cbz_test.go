@@ -0,0 +1,136 @@
+package cbz
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/ssor/epubgo/raw"
+)
+
+const bookPath = "../testdata/a_dogs_tale.epub"
+
+// testCBZ builds a CBZ with the pages unordered, each page is as wide as
+// its number
+func testCBZ(t *testing.T) []byte {
+	var buff bytes.Buffer
+	zw := zip.NewWriter(&buff)
+	for _, page := range []struct {
+		name  string
+		width int
+	}{{"page10.png", 10}, {"page2.png", 2}, {"page1.png", 1}} {
+		f, _ := zw.Create(page.name)
+		if err := png.Encode(f, image.NewGray(image.Rect(0, 0, page.width, 20))); err != nil {
+			t.Fatalf("Can't encode the image: %v", err)
+		}
+	}
+	f, _ := zw.Create("ComicInfo.xml")
+	f.Write([]byte(`<ComicInfo><Title>Manga</Title><Writer>One, Two</Writer><LanguageISO>ja</LanguageISO><Manga>YesAndRightToLeft</Manga></ComicInfo>`))
+	zw.Close()
+	return buff.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	data := testCBZ(t)
+	dir := t.TempDir()
+	epubPath := filepath.Join(dir, "manga.epub")
+	var epub bytes.Buffer
+	if err := ToEpub(bytes.NewReader(data), int64(len(data)), &epub); err != nil {
+		t.Fatalf("ToEpub() return an error: %v", err)
+	}
+	ioutil.WriteFile(epubPath, epub.Bytes(), 0644)
+
+	e, err := raw.NewEpub(epubPath)
+	if err != nil {
+		t.Fatalf("Can't open the epub: %v", err)
+	}
+	defer e.Close()
+	if titles, _ := e.Metadata("title"); len(titles) != 1 || titles[0] != "Manga" {
+		t.Errorf("The titles are %v", titles)
+	}
+	if creators, _ := e.Metadata("creator"); len(creators) != 2 {
+		t.Errorf("The creators are %v", creators)
+	}
+	if !e.Rendition().IsFixedLayout() || e.PageProgressionDirection() != "rtl" {
+		t.Errorf("The epub is not a fixed layout rtl: %+v %v", e.Rendition(), e.PageProgressionDirection())
+	}
+	for i, width := range []int{1, 2, 10} {
+		r, err := e.PageRendition(i)
+		if err != nil {
+			t.Fatalf("PageRendition(%d) return an error: %v", i, err)
+		}
+		if r.Viewport != (raw.Viewport{Width: width, Height: 20}) {
+			t.Errorf("The viewport of the page %d is %v", i, r.Viewport)
+		}
+	}
+
+	var cbz bytes.Buffer
+	if err := Write(e, &cbz); err != nil {
+		t.Fatalf("Write() return an error: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(cbz.Bytes()), int64(cbz.Len()))
+	if err != nil {
+		t.Fatalf("The CBZ is not a zip: %v", err)
+	}
+	expected := []struct {
+		name  string
+		width int
+	}{{"001.png", 1}, {"002.png", 2}, {"003.png", 10}, {"ComicInfo.xml", 0}}
+	if len(zr.File) != len(expected) {
+		t.Fatalf("The CBZ has %d files", len(zr.File))
+	}
+	for i, f := range zr.File {
+		if f.Name != expected[i].name {
+			t.Errorf("The file %d is %v, the expected was %v", i, f.Name, expected[i].name)
+			continue
+		}
+		r, _ := f.Open()
+		if expected[i].width != 0 {
+			config, _, err := image.DecodeConfig(r)
+			if err != nil || config.Width != expected[i].width {
+				t.Errorf("The image %v is %+v, %v", f.Name, config, err)
+			}
+		} else {
+			var info ComicInfo
+			if err := xml.NewDecoder(r).Decode(&info); err != nil {
+				t.Errorf("Can't decode the ComicInfo.xml: %v", err)
+			}
+			if info.Title != "Manga" || info.PageCount != 3 || !info.IsRightToLeft() || info.LanguageISO != "ja" {
+				t.Errorf("The ComicInfo is %+v", info)
+			}
+		}
+		r.Close()
+	}
+}
+
+func TestWriteNotImages(t *testing.T) {
+	e, _ := raw.NewEpub(bookPath)
+	defer e.Close()
+
+	if err := Write(e, ioutil.Discard); err == nil {
+		t.Errorf("Write() didn't fail on a book with text")
+	}
+}
+
+func TestNaturalLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		less bool
+	}{
+		{"page2.png", "page10.png", true},
+		{"page10.png", "page2.png", false},
+		{"a/002.jpg", "a/10.jpg", true},
+		{"ch1/p9.jpg", "ch2/p1.jpg", true},
+		{"p1.jpg", "p1b.jpg", true},
+	}
+	for _, test := range tests {
+		if naturalLess(test.a, test.b) != test.less {
+			t.Errorf("naturalLess(%q, %q) is not %v", test.a, test.b, test.less)
+		}
+	}
+}
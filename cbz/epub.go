@@ -0,0 +1,343 @@
+package cbz
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/rand"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	_ "golang.org/x/image/webp"
+	"golang.org/x/net/html"
+)
+
+var imageTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+}
+
+// cbzPage is an image of the CBZ
+type cbzPage struct {
+	name   string
+	data   []byte
+	width  int
+	height int
+}
+
+// ToEpubFile builds the fixed layout epub epubPath from the CBZ cbzPath
+//
+// Without ComicInfo.xml the name of the CBZ is the title of the book.
+func ToEpubFile(cbzPath, epubPath string) error {
+	r, err := zip.OpenReader(cbzPath)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	f, err := os.Create(epubPath)
+	if err != nil {
+		return err
+	}
+	title := strings.TrimSuffix(filepath.Base(cbzPath), filepath.Ext(cbzPath))
+	if err := toEpub(&r.Reader, f, title); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ToEpub writes on w a fixed layout epub with a page for each image of the
+// CBZ
+//
+// The images are ordered by their names, with the numbers compared by
+// their value. The metadata and the reading direction come from the
+// ComicInfo.xml if there is one.
+func ToEpub(r io.ReaderAt, size int64, w io.Writer) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+	return toEpub(zr, w, "")
+}
+
+func toEpub(zr *zip.Reader, w io.Writer, title string) error {
+	var info ComicInfo
+	pages := []cbzPage{}
+	for _, f := range zr.File {
+		name := f.Name
+		if strings.HasSuffix(name, "/") || strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(path.Base(name), ".") {
+			continue
+		}
+		if path.Base(name) == "ComicInfo.xml" {
+			if err := readComicInfo(f, &info); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, ok := imageTypes[strings.ToLower(path.Ext(name))]; !ok {
+			continue
+		}
+		page, err := readPage(f)
+		if err != nil {
+			return err
+		}
+		pages = append(pages, page)
+	}
+	if len(pages) == 0 {
+		return errors.New("The CBZ has no images")
+	}
+	sort.SliceStable(pages, func(i, j int) bool {
+		return naturalLess(pages[i].name, pages[j].name)
+	})
+	if info.Title == "" {
+		info.Title = title
+	}
+	return writeEpub(w, info, pages)
+}
+
+func readComicInfo(f *zip.File, info *ComicInfo) error {
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return xml.NewDecoder(r).Decode(info)
+}
+
+func readPage(f *zip.File) (cbzPage, error) {
+	r, err := f.Open()
+	if err != nil {
+		return cbzPage{}, err
+	}
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return cbzPage{}, err
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return cbzPage{}, errors.New("Can't read the size of the image " + f.Name + ": " + err.Error())
+	}
+	return cbzPage{name: f.Name, data: data, width: config.Width, height: config.Height}, nil
+}
+
+// naturalLess compares the names with the numbers on them compared by
+// value, so "page2" goes before "page10"
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		ra, sizeA := utf8.DecodeRuneInString(a)
+		rb, sizeB := utf8.DecodeRuneInString(b)
+		if isDigit(ra) && isDigit(rb) {
+			na, resta := leadingNumber(a)
+			nb, restb := leadingNumber(b)
+			if na != nb {
+				return numberLess(na, nb)
+			}
+			a, b = resta, restb
+			continue
+		}
+		if ra != rb {
+			return ra < rb
+		}
+		a, b = a[sizeA:], b[sizeB:]
+	}
+	return len(a) < len(b)
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func leadingNumber(s string) (string, string) {
+	i := 0
+	for i < len(s) && isDigit(rune(s[i])) {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+func numberLess(a, b string) bool {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
+type epubFile struct {
+	name    string
+	content []byte
+	method  uint16
+}
+
+func writeEpub(w io.Writer, info ComicInfo, pages []cbzPage) error {
+	now := time.Now()
+	zw := zip.NewWriter(w)
+	mimetype, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store, Modified: now})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mimetype, "application/epub+zip"); err != nil {
+		return err
+	}
+
+	width := len(strconv.Itoa(len(pages)))
+	if width < 3 {
+		width = 3
+	}
+	names := make([]string, len(pages))
+	for i := range pages {
+		names[i] = fmt.Sprintf("%0*d", width, i+1)
+	}
+
+	files := []epubFile{
+		{"META-INF/container.xml", []byte(containerXML), zip.Deflate},
+		{"OEBPS/content.opf", packageDocument(info, pages, names), zip.Deflate},
+		{"OEBPS/nav.xhtml", navDocument(info, names), zip.Deflate},
+	}
+	for i, page := range pages {
+		imageHref := "images/" + names[i] + strings.ToLower(path.Ext(page.name))
+		files = append(files,
+			epubFile{"OEBPS/pages/" + names[i] + ".xhtml", pageDocument(i, imageHref, page), zip.Deflate},
+			// the images are already compressed
+			epubFile{"OEBPS/" + imageHref, page.data, zip.Store})
+	}
+
+	for _, file := range files {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: file.name, Method: file.method, Modified: now})
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(file.content); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+const containerXML = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+func packageDocument(info ComicInfo, pages []cbzPage, names []string) []byte {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	b.WriteString(`<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid">` + "\n")
+	b.WriteString(`  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">` + "\n")
+	b.WriteString(`    <dc:identifier id="uid">urn:uuid:` + newUUID() + "</dc:identifier>\n")
+	b.WriteString("    <dc:title>" + html.EscapeString(info.Title) + "</dc:title>\n")
+	language := info.LanguageISO
+	if language == "" {
+		language = "und"
+	}
+	b.WriteString("    <dc:language>" + html.EscapeString(language) + "</dc:language>\n")
+	for _, writer := range strings.Split(info.Writer, ",") {
+		if writer = strings.TrimSpace(writer); writer != "" {
+			b.WriteString("    <dc:creator>" + html.EscapeString(writer) + "</dc:creator>\n")
+		}
+	}
+	if info.Publisher != "" {
+		b.WriteString("    <dc:publisher>" + html.EscapeString(info.Publisher) + "</dc:publisher>\n")
+	}
+	if info.Summary != "" {
+		b.WriteString("    <dc:description>" + html.EscapeString(info.Summary) + "</dc:description>\n")
+	}
+	if info.Year > 0 {
+		date := fmt.Sprintf("%04d", info.Year)
+		if info.Month > 0 {
+			date += fmt.Sprintf("-%02d", info.Month)
+			if info.Day > 0 {
+				date += fmt.Sprintf("-%02d", info.Day)
+			}
+		}
+		b.WriteString("    <dc:date>" + date + "</dc:date>\n")
+	}
+	b.WriteString(`    <meta property="dcterms:modified">` + time.Now().UTC().Format("2006-01-02T15:04:05Z") + "</meta>\n")
+	b.WriteString(`    <meta property="rendition:layout">pre-paginated</meta>` + "\n")
+	b.WriteString(`    <meta property="rendition:orientation">auto</meta>` + "\n")
+	b.WriteString(`    <meta property="rendition:spread">landscape</meta>` + "\n")
+	b.WriteString(`    <meta name="cover" content="img` + names[0] + `"/>` + "\n")
+	b.WriteString("  </metadata>\n  <manifest>\n")
+	b.WriteString(`    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>` + "\n")
+	for i, page := range pages {
+		ext := strings.ToLower(path.Ext(page.name))
+		properties := ""
+		if i == 0 {
+			properties = ` properties="cover-image"`
+		}
+		b.WriteString(`    <item id="img` + names[i] + `" href="images/` + names[i] + ext + `" media-type="` + imageTypes[ext] + `"` + properties + "/>\n")
+		b.WriteString(`    <item id="page` + names[i] + `" href="pages/` + names[i] + `.xhtml" media-type="application/xhtml+xml"/>` + "\n")
+	}
+	b.WriteString("  </manifest>\n")
+	direction := "ltr"
+	if info.IsRightToLeft() {
+		direction = "rtl"
+	}
+	b.WriteString(`  <spine page-progression-direction="` + direction + `">` + "\n")
+	for _, name := range names {
+		b.WriteString(`    <itemref idref="page` + name + `"/>` + "\n")
+	}
+	b.WriteString("  </spine>\n</package>\n")
+	return []byte(b.String())
+}
+
+func navDocument(info ComicInfo, names []string) []byte {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n<!DOCTYPE html>\n")
+	b.WriteString(`<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">` + "\n")
+	b.WriteString("<head>\n<meta charset=\"utf-8\"/>\n<title>" + html.EscapeString(info.Title) + "</title>\n</head>\n<body>\n")
+	b.WriteString("<nav epub:type=\"toc\" id=\"toc\">\n<ol>\n")
+	b.WriteString(`<li><a href="pages/` + names[0] + `.xhtml">` + html.EscapeString(info.Title) + "</a></li>\n")
+	b.WriteString("</ol>\n</nav>\n")
+	b.WriteString("<nav epub:type=\"page-list\" id=\"page-list\" hidden=\"hidden\">\n<ol>\n")
+	for i, name := range names {
+		b.WriteString(`<li><a href="pages/` + name + `.xhtml">` + strconv.Itoa(i+1) + "</a></li>\n")
+	}
+	b.WriteString("</ol>\n</nav>\n</body>\n</html>\n")
+	return []byte(b.String())
+}
+
+func pageDocument(index int, imageHref string, page cbzPage) []byte {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n<!DOCTYPE html>\n")
+	b.WriteString(`<html xmlns="http://www.w3.org/1999/xhtml">` + "\n<head>\n<meta charset=\"utf-8\"/>\n")
+	b.WriteString("<title>" + strconv.Itoa(index+1) + "</title>\n")
+	b.WriteString(`<meta name="viewport" content="width=` + strconv.Itoa(page.width) + ", height=" + strconv.Itoa(page.height) + `"/>` + "\n")
+	b.WriteString("<style>html, body { margin: 0; padding: 0; } img { display: block; width: 100%; height: 100%; }</style>\n")
+	b.WriteString("</head>\n<body>\n")
+	b.WriteString(`<img src="../` + imageHref + `" alt=""/>` + "\n")
+	b.WriteString("</body>\n</html>\n")
+	return []byte(b.String())
+}
+
+// newUUID returns a random version 4 uuid
+func newUUID() string {
+	var u [16]byte
+	rand.Read(u[:])
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
@@ -0,0 +1,360 @@
+// Package chunker splits books on passages for retrieval pipelines
+//
+// The text of the documents of the spine is split on headings and
+// paragraphs into chunks up to a target size, optionally overlapping.
+// Every chunk knows where it comes from: the book, the chapter of the index,
+// the document of the spine and the position inside it:
+//
+//	book, _ := raw.NewEpub("path/of/the/file.epub")
+//	err := chunker.WriteJSONL(book, os.Stdout, chunker.Options{MaxSize: 500})
+//
+// Everything runs offline, tokens are estimated unless a CountTokens
+// function is provided.
+package chunker
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ssor/epubgo/internal/docutil"
+	"github.com/ssor/epubgo/raw"
+)
+
+// Unit is the unit of the sizes of the chunks
+type Unit int
+
+const (
+	// Characters counts the unicode characters of the text
+	Characters Unit = iota
+	// Tokens counts the tokens of the text with Options.CountTokens
+	Tokens
+)
+
+// DefaultMaxSize is the size of the chunks if none is configured
+const DefaultMaxSize = 1000
+
+// Options configures how the text is split
+type Options struct {
+	// MaxSize is the maximum size of a chunk, DefaultMaxSize if 0
+	MaxSize int
+	// Overlap is the size of the text at the end of a chunk repeated at the
+	// start of the next one of the same section
+	Overlap int
+	Unit    Unit
+	// CountTokens counts the tokens of the text when the Unit is Tokens,
+	// EstimateTokens is used if nil
+	CountTokens func(string) int
+}
+
+// Chunk is a passage of the book
+type Chunk struct {
+	BookID     string   `json:"book_id"`
+	Index      int      `json:"index"`
+	Chapter    string   `json:"chapter,omitempty"`
+	SpineIndex int      `json:"spine_index"`
+	Href       string   `json:"href"`
+	Start      Position `json:"start"`
+	End        Position `json:"end"`
+	Text       string   `json:"text"`
+}
+
+// Position is a position on a document of the spine
+//
+// Offset counts the characters of the plain text of the document.
+type Position struct {
+	Offset int    `json:"offset"`
+	CFI    string `json:"cfi"`
+}
+
+// WriteJSONL writes the chunks of the book on w, a JSON object per line
+func WriteJSONL(e *raw.Epub, w io.Writer, opts Options) error {
+	chunks, err := Split(e, opts)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	for _, chunk := range chunks {
+		if err := encoder.Encode(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Split splits the text of the documents of the spine of the book on chunks
+func Split(e *raw.Epub, opts Options) ([]Chunk, error) {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultMaxSize
+	}
+	if opts.Overlap < 0 || opts.Overlap >= opts.MaxSize {
+		return nil, errors.New("The overlap must be smaller than the size of the chunks")
+	}
+	size := func(s string) int { return utf8.RuneCountInString(s) }
+	if opts.Unit == Tokens {
+		size = opts.CountTokens
+		if size == nil {
+			size = EstimateTokens
+		}
+	}
+
+	bookID := e.UniqueIdentifier()
+	it, err := e.Spine()
+	if err != nil {
+		return nil, err
+	}
+	chunks := []Chunk{}
+	for i := 0; ; i++ {
+		if item := e.FileManifest(it.URL()); item != nil && docutil.IsTextContent(item.MediaType) {
+			text, err := e.SpineText(i)
+			if err != nil {
+				return nil, err
+			}
+			s := splitter{text: text.Text, max: opts.MaxSize, overlap: opts.Overlap, size: size}
+			spans := s.split(text.Blocks)
+			starts := make([]int, len(spans))
+			for j, r := range spans {
+				starts[j] = r.start
+			}
+			points := e.NavPointsAt(text, starts)
+			for j, r := range spans {
+				chunks = append(chunks, newChunk(text, r, points[j], bookID, len(chunks)))
+			}
+		}
+		if it.Next() != nil {
+			break
+		}
+	}
+	return chunks, nil
+}
+
+func newChunk(text *raw.DocumentText, r span, point *raw.NavPoint, bookID string, index int) Chunk {
+	chunk := Chunk{
+		BookID:     bookID,
+		Index:      index,
+		SpineIndex: text.SpineIndex,
+		Href:       text.Href,
+		Text:       text.Text[r.start:r.end],
+		Start: Position{
+			Offset: utf8.RuneCountInString(text.Text[:r.start]),
+			CFI:    text.Location(r.start).CFI(),
+		},
+		End: Position{
+			Offset: utf8.RuneCountInString(text.Text[:r.end]),
+			CFI:    text.EndLocation(r.end).CFI(),
+		},
+	}
+	if point != nil {
+		chunk.Chapter = point.Title()
+	}
+	return chunk
+}
+
+// EstimateTokens estimates the tokens of a language model on the text
+//
+// It counts a token for every four characters of a word, one for each CJK
+// character and one for each punctuation sign.
+func EstimateTokens(s string) int {
+	tokens := 0
+	word := 0
+	for _, r := range s {
+		switch {
+		case docutil.IsCJK(r):
+			tokens += (word + 3) / 4
+			word = 0
+			tokens++
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r):
+			word++
+		default:
+			tokens += (word + 3) / 4
+			word = 0
+			if !unicode.IsSpace(r) {
+				tokens++
+			}
+		}
+	}
+	return tokens + (word+3)/4
+}
+
+// span is a range of bytes of the text
+type span struct {
+	start int
+	end   int
+}
+
+type splitter struct {
+	text    string
+	max     int
+	overlap int
+	size    func(string) int
+}
+
+// split groups the blocks on chunks
+//
+// A heading starts a new chunk and stays with the text that follows it.
+// Blocks are not split unless they don't fit on an empty chunk, then they
+// are cut preferably at the end of a sentence or else between words.
+func (s splitter) split(blocks []raw.TextBlock) []span {
+	chunks := []span{}
+	var curr *span
+	hasBody := false // curr has text besides headings
+	overlapOnly := false
+
+	flush := func(overlap bool) {
+		chunks = append(chunks, *curr)
+		curr = nil
+		if !overlap {
+			return
+		}
+		if start := s.overlapStart(chunks[len(chunks)-1]); start >= 0 {
+			curr = &span{start, chunks[len(chunks)-1].end}
+			hasBody = false
+			overlapOnly = true
+		}
+	}
+
+	for _, block := range blocks {
+		heading := isHeading(block.Tag)
+		if heading && curr != nil {
+			if overlapOnly {
+				curr = nil
+			} else if hasBody {
+				flush(false)
+			}
+		}
+		start := block.Start
+		for start < block.End {
+			if curr == nil {
+				curr = &span{start, start}
+				hasBody = false
+				overlapOnly = false
+			}
+			if s.size(s.text[curr.start:block.End]) <= s.max {
+				curr.end = block.End
+				overlapOnly = false
+				break
+			}
+			if hasBody {
+				flush(true)
+				continue
+			}
+
+			cut := s.cut(curr.start, start, block.End)
+			if cut == start {
+				if overlapOnly || curr.end == curr.start {
+					// nothing fits after the overlap, drop it
+					curr = nil
+				} else {
+					flush(false)
+				}
+				continue
+			}
+			curr.end = cut
+			flush(true)
+			start = cut
+			for start < block.End && s.text[start] == ' ' {
+				start++
+			}
+		}
+		if !heading {
+			hasBody = true
+		}
+	}
+	if curr != nil && !overlapOnly {
+		chunks = append(chunks, *curr)
+	}
+	return chunks
+}
+
+// cut returns where to cut the text between start and end for the chunk
+// starting at from to fit, start if nothing fits
+//
+// Cuts on a chunk that starts at the same place as the text always take at
+// least one character.
+func (s splitter) cut(from, start, end int) int {
+	text := s.text[start:end]
+	// longest prefix that fits, the size grows with the length of the text
+	boundaries := []int{}
+	for i := range text {
+		if i > 0 {
+			boundaries = append(boundaries, i)
+		}
+	}
+	fits := sort.Search(len(boundaries), func(i int) bool {
+		return s.size(s.text[from:start+boundaries[i]]) > s.max
+	})
+	if fits == 0 {
+		if from < start {
+			return start
+		}
+		_, size := utf8.DecodeRuneInString(text)
+		return start + size
+	}
+	limit := boundaries[fits-1]
+
+	// prefer the end of a sentence on the second half, then a space
+	if i := lastSentenceEnd(text[:limit]); i > limit/2 {
+		return start + i
+	}
+	if i := strings.LastIndexByte(text[:limit], ' '); i > 0 {
+		return start + i
+	}
+	return start + limit
+}
+
+// overlapStart returns where the overlap of the next chunk of c starts, -1
+// if there is none
+func (s splitter) overlapStart(c span) int {
+	if s.overlap == 0 {
+		return -1
+	}
+	start := -1
+	for i := c.end; i > c.start; {
+		r, size := utf8.DecodeLastRuneInString(s.text[c.start:i])
+		i -= size
+		if !isBoundary(s.text, i, r) {
+			continue
+		}
+		if s.size(s.text[i:c.end]) > s.overlap {
+			break
+		}
+		start = i
+	}
+	if start < 0 {
+		return -1
+	}
+	for start < c.end && (s.text[start] == ' ' || s.text[start] == '\n') {
+		start++
+	}
+	return start
+}
+
+// isBoundary returns whether a chunk can start at the offset i of the text,
+// where the rune r is
+func isBoundary(text string, i int, r rune) bool {
+	if i == 0 || docutil.IsCJK(r) {
+		return true
+	}
+	prev, _ := utf8.DecodeLastRuneInString(text[:i])
+	return unicode.IsSpace(prev) || docutil.IsCJK(prev)
+}
+
+func lastSentenceEnd(text string) int {
+	end := -1
+	for i, r := range text {
+		switch r {
+		case '.', '!', '?', '。', '！', '？', '；', ';':
+			end = i + utf8.RuneLen(r)
+		}
+	}
+	return end
+}
+
+func isHeading(tag string) bool {
+	return len(tag) == 2 && tag[0] == 'h' && tag[1] >= '1' && tag[1] <= '6'
+}
@@ -0,0 +1,178 @@
+package chunker
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ssor/epubgo/raw"
+)
+
+const (
+	bookPath  = "../testdata/a_dogs_tale.epub"
+	gcdxyPath = "../testdata/gcdxy.epub"
+)
+
+func TestSplit(t *testing.T) {
+	f, err := raw.NewEpub(bookPath)
+	if err != nil {
+		t.Fatalf("Can't open the book: %v", err)
+	}
+	defer f.Close()
+
+	chunks, err := Split(f, Options{MaxSize: 300})
+	if err != nil {
+		t.Fatalf("Split() return an error: %v", err)
+	}
+	if len(chunks) < 2 {
+		t.Fatalf("Split() returned %d chunks", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if chunk.Index != i {
+			t.Errorf("chunk %d has index %d", i, chunk.Index)
+		}
+		if size := len([]rune(chunk.Text)); size > 300 {
+			t.Errorf("chunk %d has %d characters", i, size)
+		}
+		if chunk.BookID == "" {
+			t.Errorf("chunk %d has no book id", i)
+		}
+		if chunk.End.Offset-chunk.Start.Offset != len([]rune(chunk.Text)) {
+			t.Errorf("chunk %d positions %d-%d don't match its text", i, chunk.Start.Offset, chunk.End.Offset)
+		}
+		if !strings.HasPrefix(chunk.Start.CFI, "epubcfi(/6/") {
+			t.Errorf("chunk %d has the cfi '%v'", i, chunk.Start.CFI)
+		}
+	}
+}
+
+func TestSplitOverlap(t *testing.T) {
+	f, _ := raw.NewEpub(bookPath)
+	defer f.Close()
+
+	chunks, err := Split(f, Options{MaxSize: 300, Overlap: 60})
+	if err != nil {
+		t.Fatalf("Split() return an error: %v", err)
+	}
+	overlaps := 0
+	for i := 1; i < len(chunks); i++ {
+		prev, curr := chunks[i-1], chunks[i]
+		if prev.SpineIndex == curr.SpineIndex && curr.Start.Offset < prev.End.Offset {
+			overlaps++
+			if prev.End.Offset-curr.Start.Offset > 60 {
+				t.Errorf("chunks %d and %d overlap %d characters", i-1, i, prev.End.Offset-curr.Start.Offset)
+			}
+		}
+	}
+	if overlaps == 0 {
+		t.Errorf("No chunk overlaps")
+	}
+
+	if _, err := Split(f, Options{MaxSize: 100, Overlap: 100}); err == nil {
+		t.Errorf("Split() didn't fail with an overlap as big as the chunks")
+	}
+}
+
+func TestSplitChapters(t *testing.T) {
+	f, err := raw.NewEpub(gcdxyPath)
+	if err != nil {
+		t.Fatalf("Can't open the book: %v", err)
+	}
+	defer f.Close()
+
+	chunks, err := Split(f, Options{MaxSize: 200, Unit: Tokens})
+	if err != nil {
+		t.Fatalf("Split() return an error: %v", err)
+	}
+	chapters := map[string]bool{}
+	for i, chunk := range chunks {
+		if chunk.BookID != "1001·1165" {
+			t.Fatalf("chunk %d has the book id '%v'", i, chunk.BookID)
+		}
+		if tokens := EstimateTokens(chunk.Text); tokens > 200 {
+			t.Errorf("chunk %d has %d tokens", i, tokens)
+		}
+		chapters[chunk.Chapter] = true
+	}
+	if len(chapters) < 10 {
+		t.Errorf("Only %d chapters found on the chunks", len(chapters))
+	}
+}
+
+func TestSplitEndCFI(t *testing.T) {
+	f, err := raw.NewEpub(gcdxyPath)
+	if err != nil {
+		t.Fatalf("Can't open the book: %v", err)
+	}
+	defer f.Close()
+
+	chunks, err := Split(f, Options{MaxSize: 6})
+	if err != nil {
+		t.Fatalf("Split() return an error: %v", err)
+	}
+	expected := []struct {
+		text  string
+		start string
+		end   string
+	}{
+		{"目 录", "epubcfi(/6/4[b_content_xhtml]!/4/2/1:0)", "epubcfi(/6/4[b_content_xhtml]!/4/2/1:3)"},
+		{"版权页", "epubcfi(/6/4[b_content_xhtml]!/4/4/2/1:0)", "epubcfi(/6/4[b_content_xhtml]!/4/4/2/1:3)"},
+		{"1872年德", "epubcfi(/6/4[b_content_xhtml]!/4/8/2/1:0)", "epubcfi(/6/4[b_content_xhtml]!/4/8/2/1:6)"},
+		{"文版序言", "epubcfi(/6/4[b_content_xhtml]!/4/8/2/1:6)", "epubcfi(/6/4[b_content_xhtml]!/4/8/2/1:10)"},
+	}
+	for _, exp := range expected {
+		found := false
+		for _, chunk := range chunks {
+			if chunk.Text != exp.text {
+				continue
+			}
+			found = true
+			if chunk.Start.CFI != exp.start {
+				t.Errorf("chunk '%v' starts at %v", exp.text, chunk.Start.CFI)
+			}
+			if chunk.End.CFI != exp.end {
+				t.Errorf("chunk '%v' ends at %v", exp.text, chunk.End.CFI)
+			}
+			break
+		}
+		if !found {
+			t.Errorf("No chunk '%v' found", exp.text)
+		}
+	}
+}
+
+func TestWriteJSONL(t *testing.T) {
+	f, _ := raw.NewEpub(bookPath)
+	defer f.Close()
+
+	var buff bytes.Buffer
+	if err := WriteJSONL(f, &buff, Options{}); err != nil {
+		t.Fatalf("WriteJSONL() return an error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buff.String()), "\n")
+	for i, line := range lines {
+		var chunk Chunk
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			t.Fatalf("Line %d is not valid json: %v", i, err)
+		}
+		if chunk.Index != i || chunk.Href == "" {
+			t.Errorf("Line %d has the chunk %+v", i, chunk)
+		}
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	tests := map[string]int{
+		"":                0,
+		"dog":             1,
+		"a dog's tale.":   6,
+		"海伦·麦克法兰":         7,
+		"extraordinarily": 4,
+	}
+	for text, expected := range tests {
+		if tokens := EstimateTokens(text); tokens != expected {
+			t.Errorf("EstimateTokens(%q) = %d, the expected was %d", text, tokens, expected)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"os"
+
+	"github.com/ssor/epubgo/chunker"
+	"github.com/ssor/epubgo/raw"
+)
+
+func chunk(args []string) error {
+	flags := newFlagSet("chunk")
+	size := flags.Int("size", chunker.DefaultMaxSize, "maximum size of the chunks")
+	overlap := flags.Int("overlap", 0, "size of the overlap between consecutive chunks")
+	tokens := flags.Bool("tokens", false, "measure the sizes on estimated tokens instead of characters")
+	output := flags.String("o", "", "output file, the standard output by default")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("one epub file is needed")
+	}
+
+	book, err := raw.NewEpub(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer book.Close()
+
+	opts := chunker.Options{MaxSize: *size, Overlap: *overlap}
+	if *tokens {
+		opts.Unit = chunker.Tokens
+	}
+	if *output == "" {
+		return chunker.WriteJSONL(book, os.Stdout, opts)
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := chunker.WriteJSONL(book, f, opts); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
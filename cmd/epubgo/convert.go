@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ssor/epubgo/cbz"
+	"github.com/ssor/epubgo/markdown"
+	"github.com/ssor/epubgo/raw"
+	"github.com/ssor/epubgo/singlehtml"
+)
+
+func convert(args []string) error {
+	flags := newFlagSet("convert")
+	to := flags.String("to", "md", "output format: md, html, cbz, or epub from a cbz")
+	output := flags.String("o", "", "output file, the name of the book with the format extension by default")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("one book file is needed")
+	}
+	path := flags.Arg(0)
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	if *to == "epub" {
+		if *output == "" {
+			*output = name + ".epub"
+		}
+		return cbz.ToEpubFile(path, *output)
+	}
+
+	book, err := raw.NewEpub(path)
+	if err != nil {
+		return err
+	}
+	defer book.Close()
+
+	switch *to {
+	case "md", "markdown":
+		if *output == "" {
+			*output = name + ".md"
+		}
+		return markdown.ConvertFile(book, *output, markdown.Options{
+			MissingAsset: func(href string, err error) {
+				fmt.Fprintln(os.Stderr, "epubgo convert: missing image", href+":", err)
+			},
+		})
+	case "html":
+		if *output == "" {
+			*output = name + ".html"
+		}
+		return singlehtml.ExportFile(book, *output, singlehtml.Options{})
+	case "cbz":
+		if *output == "" {
+			*output = name + ".cbz"
+		}
+		return cbz.WriteFile(book, *output)
+	}
+	return errors.New("unknown format " + *to)
+}
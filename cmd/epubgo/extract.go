@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/ssor/epubgo/raw"
+)
+
+func extract(args []string) error {
+	flags := newFlagSet("extract")
+	types := flags.String("type", "", "comma separated families to extract: image, font, css, audio, video, script; all by default")
+	property := flags.String("property", "", "manifest property the files need to have, like cover-image")
+	output := flags.String("o", "", "output directory, the name of the book by default")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("one epub file is needed")
+	}
+	path := flags.Arg(0)
+
+	book, err := raw.NewEpub(path)
+	if err != nil {
+		return err
+	}
+	defer book.Close()
+
+	filter := raw.ResourceFilter{Property: *property}
+	if *types != "" {
+		filter.Families = strings.Split(*types, ",")
+	}
+	if *output == "" {
+		*output = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	written, err := book.ExtractResources(*output, filter)
+	for _, file := range written {
+		fmt.Println(file)
+	}
+	return err
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/ssor/epubgo/raw"
+)
+
+func links(args []string) error {
+	flags := newFlagSet("links")
+	dot := flags.Bool("dot", false, "print the whole graph on the graphviz dot language")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("one epub file is needed")
+	}
+
+	book, err := raw.NewEpub(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer book.Close()
+
+	graph, err := book.CheckLinks()
+	if err != nil {
+		return err
+	}
+	if *dot {
+		fmt.Print(graph.Dot())
+	} else {
+		for _, ref := range graph.Broken() {
+			problem := "missing fragment"
+			switch {
+			case ref.MissingFile:
+				problem = "missing file"
+			case ref.NotInManifest:
+				problem = "not in the manifest"
+			}
+			from := ref.From
+			if from == "" {
+				from = "package"
+			}
+			fmt.Printf("%s: %s %q: %s\n", from, ref.Kind, ref.Value, problem)
+		}
+		for _, file := range graph.Unreachable {
+			fmt.Printf("%s: unreachable\n", file)
+		}
+	}
+
+	if broken := len(graph.Broken()); broken > 0 {
+		return errors.New(strconv.Itoa(broken) + " broken references")
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+// Command epubgo works with epub files from the command line
+//
+// Usage:
+//
+//	epubgo convert --to md|html|cbz [-o output] book.epub
+//	epubgo convert --to epub [-o output] book.cbz
+//	epubgo chunk [-size n] [-overlap n] [-tokens] [-o output] book.epub
+//	epubgo upgrade [-o output] book.epub
+//	epubgo links [-dot] book.epub
+//	epubgo extract [-type image,font] [-property name] [-o dir] book.epub
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{"convert", "convert --to md|html|cbz [-o output] book.epub | --to epub [-o output] book.cbz", convert},
+	{"chunk", "chunk [-size n] [-overlap n] [-tokens] [-o output] book.epub", chunk},
+	{"upgrade", "upgrade [-o output] book.epub", upgrade},
+	{"links", "links [-dot] book.epub", links},
+	{"extract", "extract [-type image,font] [-property name] [-o dir] book.epub", extract},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	for _, cmd := range commands {
+		if cmd.name == os.Args[1] {
+			if err := cmd.run(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, "epubgo "+cmd.name+":", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage:")
+	for _, cmd := range commands {
+		fmt.Fprintln(os.Stderr, "\tepubgo "+cmd.usage)
+	}
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	return flags
+}
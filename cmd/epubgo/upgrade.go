@@ -0,0 +1,32 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"github.com/ssor/epubgo/raw"
+)
+
+func upgrade(args []string) error {
+	flags := newFlagSet("upgrade")
+	output := flags.String("o", "", "output file, the name of the book ending on -epub3.epub by default")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("one epub file is needed")
+	}
+	path := flags.Arg(0)
+
+	book, err := raw.NewEpub(path)
+	if err != nil {
+		return err
+	}
+	defer book.Close()
+
+	if *output == "" {
+		*output = strings.TrimSuffix(path, filepath.Ext(path)) + "-epub3.epub"
+	}
+	return book.UpgradeFile(*output)
+}
@@ -0,0 +1,94 @@
+// Package docutil has the helpers on the content documents and their hrefs
+// shared by the packages of epubgo
+package docutil
+
+import (
+	"net/url"
+	"path"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+// Attr returns the value of the attribute key of the node, or "" if it's missing
+func Attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// FindElement returns the first element named tag on the tree of n
+func FindElement(n *html.Node, tag string) *html.Node {
+	if n.Type == html.ElementNode && n.Data == tag {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := FindElement(c, tag); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// SplitFragment splits an url on the path and the fragment after the '#'
+func SplitFragment(url string) (string, string) {
+	if i := strings.Index(url, "#"); i >= 0 {
+		return url[:i], url[i+1:]
+	}
+	return url, ""
+}
+
+// ResolveHref resolves the relative reference ref found on the file base
+//
+// Both base and the result are relative to the opf file, like the hrefs of
+// the manifest. ref must not have a fragment.
+func ResolveHref(base, ref string) string {
+	if unescaped, err := url.PathUnescape(ref); err == nil {
+		ref = unescaped
+	}
+	return path.Join(path.Dir(base), ref)
+}
+
+// CleanHref normalizes an href of the manifest to compare it with resolved ones
+func CleanHref(href string) string {
+	if unescaped, err := url.PathUnescape(href); err == nil {
+		href = unescaped
+	}
+	return path.Clean(href)
+}
+
+// IsTextContent returns whether the media type is of a content document,
+// the html ones are read as well, they are a common mistake
+func IsTextContent(mediaType string) bool {
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if i := strings.Index(mediaType, ";"); i >= 0 {
+		mediaType = strings.TrimSpace(mediaType[:i])
+	}
+	return mediaType == "application/xhtml+xml" || mediaType == "text/html"
+}
+
+// IsCJK returns whether the rune is a Chinese, Japanese or Korean character,
+// the text of those languages has no spaces between the words
+func IsCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// the elements that start a new block of text
+var blockElements = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true,
+	"body": true, "caption": true, "dd": true, "div": true, "dl": true,
+	"dt": true, "figcaption": true, "figure": true, "footer": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"header": true, "hr": true, "li": true, "main": true, "nav": true,
+	"ol": true, "p": true, "pre": true, "section": true, "table": true,
+	"td": true, "th": true, "tr": true, "ul": true,
+}
+
+// IsBlockElement returns whether the html element starts a new block of text
+func IsBlockElement(tag string) bool {
+	return blockElements[tag]
+}
@@ -0,0 +1,480 @@
+// Package markdown converts epub books to CommonMark
+//
+// The documents of the spine are converted in order into a single markdown
+// file. Links between documents become links to anchors on the output and
+// the images are extracted to an assets folder:
+//
+//	book, _ := raw.NewEpub("path/of/the/file.epub")
+//	err := markdown.ConvertFile(book, "book.md", markdown.Options{})
+//
+// Footnotes marked with epub:type use the [^label] footnote syntax supported
+// by most markdown renderers.
+package markdown
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ssor/epubgo/internal/docutil"
+	"github.com/ssor/epubgo/raw"
+	"golang.org/x/net/html"
+)
+
+// DefaultAssetsDir is the folder where the images are extracted by default
+const DefaultAssetsDir = "assets"
+
+// Options configures the conversion
+type Options struct {
+	// AssetsDir is the folder, relative to the markdown file, where the
+	// images are linked from. DefaultAssetsDir if empty.
+	AssetsDir string
+	// WriteAsset is called with the name of each image on the assets folder
+	// and its content. If nil the images are linked but not extracted.
+	WriteAsset func(name string, content io.Reader) error
+	// MissingAsset is called with the href of each image that can't be read
+	// from the book. The image is replaced by its alt text.
+	MissingAsset func(href string, err error)
+}
+
+// ConvertFile converts the book into the markdown file path
+//
+// The images are extracted to the assets folder next to the file.
+func ConvertFile(e *raw.Epub, path string, opts Options) error {
+	if opts.AssetsDir == "" {
+		opts.AssetsDir = DefaultAssetsDir
+	}
+	if opts.WriteAsset == nil {
+		assetsDir := filepath.Join(filepath.Dir(path), filepath.FromSlash(opts.AssetsDir))
+		opts.WriteAsset = func(name string, content io.Reader) error {
+			if err := os.MkdirAll(assetsDir, 0755); err != nil {
+				return err
+			}
+			f, err := os.Create(filepath.Join(assetsDir, name))
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = io.Copy(f, content)
+			return err
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return Convert(e, f, opts)
+}
+
+// Convert writes the documents of the spine of the book as markdown on w
+func Convert(e *raw.Epub, w io.Writer, opts Options) error {
+	if opts.AssetsDir == "" {
+		opts.AssetsDir = DefaultAssetsDir
+	}
+	c := converter{
+		epub:       e,
+		opts:       opts,
+		docs:       make(map[string]string),
+		assets:     make(map[string]string),
+		assetNames: make(map[string]bool),
+		linked:     make(map[string]bool),
+	}
+
+	it, err := e.Spine()
+	if err != nil {
+		return err
+	}
+	hrefs := []string{}
+	slugs := make(map[string]bool)
+	for {
+		href := it.URL()
+		if item := e.FileManifest(href); item != nil && docutil.IsTextContent(item.MediaType) {
+			hrefs = append(hrefs, href)
+			c.docs[docutil.CleanHref(href)] = uniqueName(slug(strings.TrimSuffix(path.Base(href), path.Ext(href))), slugs)
+		}
+		if it.Next() != nil {
+			break
+		}
+	}
+
+	parts := []string{}
+	for _, href := range hrefs {
+		part, err := c.document(href)
+		if err != nil {
+			return err
+		}
+		parts = append(parts, part)
+	}
+
+	_, err = io.WriteString(w, c.resolveAnchors(strings.Join(parts, "\n\n"))+"\n")
+	return err
+}
+
+type converter struct {
+	epub *raw.Epub
+	opts Options
+	// slugs of the documents of the spine by href
+	docs map[string]string
+	// names on the assets folder by href
+	assets     map[string]string
+	assetNames map[string]bool
+	// anchors that are the target of some link
+	linked map[string]bool
+
+	href      string
+	footnotes []string
+	err       error
+}
+
+func (c *converter) document(href string) (string, error) {
+	doc, err := c.epub.ParseDocument(href)
+	if err != nil {
+		return "", err
+	}
+	c.href = href
+	c.footnotes = nil
+
+	body := docutil.FindElement(doc, "body")
+	if body == nil {
+		body = doc
+	}
+	out := anchor(c.docs[docutil.CleanHref(href)]) + "\n\n" + c.blocks(body)
+	if len(c.footnotes) > 0 {
+		out += "\n\n" + strings.Join(c.footnotes, "\n\n")
+	}
+	return out, c.err
+}
+
+// blocks renders the children of n, grouping the inline ones on paragraphs
+func (c *converter) blocks(n *html.Node) string {
+	parts := []string{}
+	var inline strings.Builder
+	flush := func() {
+		if p := paragraph(inline.String()); p != "" {
+			parts = append(parts, p)
+		}
+		inline.Reset()
+	}
+
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if isBlock(child) {
+			flush()
+			if block := c.block(child); block != "" {
+				parts = append(parts, block)
+			}
+		} else {
+			inline.WriteString(c.inline(child))
+		}
+	}
+	flush()
+	return strings.Join(parts, "\n\n")
+}
+
+func (c *converter) block(n *html.Node) string {
+	if isNote(n) {
+		c.footnote(n)
+		return ""
+	}
+
+	idAnchor := c.idAnchor(n)
+	switch n.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		level, _ := strconv.Atoi(n.Data[1:])
+		text := strings.Replace(paragraph(c.inlineChildren(n)), "\\\n", " ", -1)
+		return strings.Repeat("#", level) + " " + idAnchor + strings.Replace(text, "\n", " ", -1)
+	case "p":
+		return paragraph(idAnchor + c.inlineChildren(n))
+	case "blockquote":
+		return prefixLines(joinBlocks(paragraph(idAnchor), c.blocks(n)), "> ", ">")
+	case "ul", "ol":
+		return joinBlocks(paragraph(idAnchor), c.list(n))
+	case "pre":
+		return joinBlocks(paragraph(idAnchor), fence(textContent(n)))
+	case "hr":
+		return "* * *"
+	case "table":
+		return joinBlocks(paragraph(idAnchor), c.table(n))
+	case "dt":
+		return paragraph(idAnchor + "**" + strings.TrimSpace(c.inlineChildren(n)) + "**")
+	}
+	return joinBlocks(paragraph(idAnchor), c.blocks(n))
+}
+
+func (c *converter) list(n *html.Node) string {
+	items := []string{}
+	number := 1
+	if start, err := strconv.Atoi(docutil.Attr(n, "start")); err == nil {
+		number = start
+	}
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type != html.ElementNode {
+			continue
+		}
+		if isNote(li) {
+			c.footnote(li)
+			continue
+		}
+
+		marker := "- "
+		if n.Data == "ol" {
+			marker = strconv.Itoa(number) + ". "
+			number++
+		}
+		content := joinBlocks(paragraph(c.idAnchor(li)), c.blocks(li))
+		indent := strings.Repeat(" ", len(marker))
+		items = append(items, marker+strings.TrimPrefix(prefixLines(content, indent, ""), indent))
+	}
+	return strings.Join(items, "\n")
+}
+
+func (c *converter) table(n *html.Node) string {
+	rows := [][]string{}
+	columns := 0
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode {
+				continue
+			}
+			switch child.Data {
+			case "tr":
+				row := []string{}
+				for cell := child.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type == html.ElementNode && (cell.Data == "td" || cell.Data == "th") {
+						text := strings.Replace(paragraph(c.inlineChildren(cell)), "\\\n", " ", -1)
+						text = strings.Replace(text, "\n", " ", -1)
+						row = append(row, strings.Replace(text, "|", "\\|", -1))
+					}
+				}
+				if len(row) > columns {
+					columns = len(row)
+				}
+				rows = append(rows, row)
+			case "thead", "tbody", "tfoot":
+				walk(child)
+			}
+		}
+	}
+	walk(n)
+	if len(rows) == 0 || columns == 0 {
+		return ""
+	}
+
+	lines := []string{}
+	for i, row := range rows {
+		for len(row) < columns {
+			row = append(row, "")
+		}
+		lines = append(lines, "| "+strings.Join(row, " | ")+" |")
+		if i == 0 {
+			lines = append(lines, "|"+strings.Repeat(" --- |", columns))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// footnote adds the note n to the footnotes of the current document
+func (c *converter) footnote(n *html.Node) {
+	content := prefixLines(c.blocks(n), "    ", "")
+	label := c.anchorName(c.href, docutil.Attr(n, "id"))
+	c.footnotes = append(c.footnotes, "[^"+label+"]: "+strings.TrimPrefix(content, "    "))
+}
+
+func (c *converter) inlineChildren(n *html.Node) string {
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		b.WriteString(c.inline(child))
+	}
+	return b.String()
+}
+
+func (c *converter) inline(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return escape(collapseSpaces(n.Data))
+	case html.ElementNode:
+	default:
+		return ""
+	}
+	if skipped[n.Data] {
+		return ""
+	}
+
+	idAnchor := c.idAnchor(n)
+	switch n.Data {
+	case "em", "i", "cite", "dfn", "var":
+		return idAnchor + wrap(c.inlineChildren(n), "*")
+	case "strong", "b":
+		return idAnchor + wrap(c.inlineChildren(n), "**")
+	case "code", "kbd", "samp", "tt":
+		return idAnchor + codeSpan(textContent(n))
+	case "sup", "sub":
+		return idAnchor + "<" + n.Data + ">" + c.inlineChildren(n) + "</" + n.Data + ">"
+	case "br":
+		return "\\\n"
+	case "img":
+		return idAnchor + c.image(docutil.Attr(n, "src"), docutil.Attr(n, "alt"))
+	case "image":
+		src := docutil.Attr(n, "xlink:href")
+		if src == "" {
+			src = docutil.Attr(n, "href")
+		}
+		return idAnchor + c.image(src, "")
+	case "svg":
+		if image := docutil.FindElement(n, "image"); image != nil {
+			return idAnchor + c.inline(image)
+		}
+		return idAnchor
+	case "a":
+		return idAnchor + c.link(n)
+	}
+	return idAnchor + c.inlineChildren(n)
+}
+
+func (c *converter) link(n *html.Node) string {
+	text := c.inlineChildren(n)
+	href := docutil.Attr(n, "href")
+	if href == "" {
+		return text
+	}
+	if hasType(n, "noteref") {
+		file, fragment := docutil.SplitFragment(href)
+		target := c.href
+		if file != "" {
+			target = docutil.ResolveHref(c.href, file)
+		}
+		return "[^" + c.anchorName(target, fragment) + "]"
+	}
+	if strings.TrimSpace(text) == "" {
+		return ""
+	}
+	return "[" + strings.TrimSpace(text) + "](" + destination(c.rewriteLink(href)) + ")"
+}
+
+// rewriteLink rewrites the links to documents of the spine into anchors
+func (c *converter) rewriteLink(href string) string {
+	if isExternal(href) {
+		return href
+	}
+	file, fragment := docutil.SplitFragment(href)
+	target := c.href
+	if file != "" {
+		target = docutil.ResolveHref(c.href, file)
+	}
+	if _, ok := c.docs[docutil.CleanHref(target)]; !ok {
+		return href
+	}
+	name := c.anchorName(target, fragment)
+	c.linked[name] = true
+	return "#" + name
+}
+
+func (c *converter) image(src, alt string) string {
+	if src == "" {
+		return ""
+	}
+	if isExternal(src) {
+		return "![" + escape(alt) + "](" + destination(src) + ")"
+	}
+
+	href := docutil.ResolveHref(c.href, src)
+	name, ok := c.assets[href]
+	if !ok {
+		if c.opts.WriteAsset != nil && c.err == nil {
+			data, err := c.readAsset(href)
+			if err == nil {
+				name = uniqueName(path.Base(href), c.assetNames)
+				c.err = c.opts.WriteAsset(name, bytes.NewReader(data))
+			} else if c.opts.MissingAsset != nil {
+				c.opts.MissingAsset(href, err)
+			}
+		} else {
+			name = uniqueName(path.Base(href), c.assetNames)
+		}
+		c.assets[href] = name
+	}
+	if name == "" {
+		return escape(alt)
+	}
+	return "![" + escape(alt) + "](" + destination(path.Join(c.opts.AssetsDir, name)) + ")"
+}
+
+func (c *converter) readAsset(href string) ([]byte, error) {
+	f, err := c.epub.OpenFile(href)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+// idAnchor returns the placeholder of the anchor of the id of n, if any
+func (c *converter) idAnchor(n *html.Node) string {
+	id := docutil.Attr(n, "id")
+	if id == "" {
+		return ""
+	}
+	return anchor(c.anchorName(c.href, id))
+}
+
+// anchorName returns the name on the output of the id of the document href
+func (c *converter) anchorName(href, id string) string {
+	name := c.docs[docutil.CleanHref(href)]
+	if name == "" {
+		name = slug(strings.TrimSuffix(path.Base(href), path.Ext(href)))
+	}
+	if id == "" {
+		return name
+	}
+	return name + "-" + slug(id)
+}
+
+var anchorPlaceholder = regexp.MustCompile("\x00([^\x00]*)\x00")
+var fenceLine = regexp.MustCompile("^[> ]*(```+)\\s*$")
+
+// anchor returns a placeholder for the anchor name
+//
+// The anchors are only written if some link points to them, the
+// placeholders are resolved once the whole book is converted.
+func anchor(name string) string {
+	return "\x00" + name + "\x00"
+}
+
+func (c *converter) resolveAnchors(s string) string {
+	s = anchorPlaceholder.ReplaceAllStringFunc(s, func(placeholder string) string {
+		name := placeholder[1 : len(placeholder)-1]
+		if !c.linked[name] {
+			return ""
+		}
+		return `<a id="` + name + `"></a>`
+	})
+
+	// trim the blank lines and remove the repeated ones, except on the
+	// fenced code blocks
+	lines := []string{}
+	fence := ""
+	for _, line := range strings.Split(s, "\n") {
+		if m := fenceLine.FindStringSubmatch(line); m != nil {
+			if fence == "" {
+				fence = m[1]
+			} else if len(m[1]) >= len(fence) {
+				fence = ""
+			}
+		} else if fence == "" && (strings.TrimSpace(line) == "" || strings.TrimLeft(line, "> ") == "") {
+			line = strings.TrimRight(line, " ")
+			if line == "" && len(lines) > 0 && lines[len(lines)-1] == "" {
+				continue
+			}
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,130 @@
+package markdown
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ssor/epubgo/internal/docutil"
+	"github.com/ssor/epubgo/raw"
+	"golang.org/x/net/html"
+)
+
+const (
+	gcdxyPath = "../testdata/gcdxy.epub"
+)
+
+func TestConvert(t *testing.T) {
+	book, err := raw.NewEpub(gcdxyPath)
+	if err != nil {
+		t.Fatalf("NewEpub(%v) return an error: %v", gcdxyPath, err)
+	}
+	defer book.Close()
+
+	assets := map[string]int{}
+	var buff bytes.Buffer
+	err = Convert(book, &buff, Options{WriteAsset: func(name string, content io.Reader) error {
+		n, err := io.Copy(io.Discard, content)
+		assets[name] = int(n)
+		return err
+	}})
+	if err != nil {
+		t.Fatalf("Convert() return an error: %v", err)
+	}
+	md := buff.String()
+
+	for _, expected := range []string{
+		"![cover](assets/cover.jpg)",
+		"[1872年德文版序言](#chapter_00003-CHP2-1)",
+		`## <a id="chapter_00003-CHP2-1"></a>1872年德文版序言`,
+	} {
+		if !strings.Contains(md, expected) {
+			t.Errorf("the markdown doesn't contain %q", expected)
+		}
+	}
+	if strings.Contains(md, "\x00") {
+		t.Errorf("there are anchor placeholders left on the markdown")
+	}
+	if assets["cover.jpg"] != 252195 {
+		t.Errorf("cover.jpg was extracted with %v bytes", assets["cover.jpg"])
+	}
+	if len(assets) != 4 {
+		t.Errorf("%v images were extracted, 4 were expected", len(assets))
+	}
+}
+
+func convertBody(t *testing.T, body string) string {
+	doc, err := html.Parse(strings.NewReader("<html><body>" + body + "</body></html>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := converter{
+		href:   "text.xhtml",
+		docs:   map[string]string{"text.xhtml": "text"},
+		linked: make(map[string]bool),
+	}
+	out := c.blocks(docutil.FindElement(doc, "body"))
+	if len(c.footnotes) > 0 {
+		out += "\n\n" + strings.Join(c.footnotes, "\n\n")
+	}
+	return c.resolveAnchors(out)
+}
+
+func TestConvertBlocks(t *testing.T) {
+	tests := []struct {
+		html string
+		md   string
+	}{
+		{"<h2>Title <em>one</em></h2><p>Some <strong>bold</strong> text</p>",
+			"## Title *one*\n\nSome **bold** text"},
+		{"<ul><li>one</li><li>two<ol><li>nested</li></ol></li></ul>",
+			"- one\n- two\n\n  1. nested"},
+		{"<blockquote><p>quoted</p><p>twice</p></blockquote>",
+			"> quoted\n>\n> twice"},
+		{"<table><tr><th>a</th><th>b</th></tr><tr><td>1</td><td>x|y</td></tr></table>",
+			"| a | b |\n| --- | --- |\n| 1 | x\\|y |"},
+		{`<p>See<a epub:type="noteref" href="#n1">1</a></p><aside epub:type="footnote" id="n1"><p>The note</p></aside>`,
+			"See[^text-n1]\n\n[^text-n1]: The note"},
+		{`<p><a href="#target">link</a></p><p id="target">here</p>`,
+			"[link](#text-target)\n\n<a id=\"text-target\"></a>here"},
+		{"<p>1. not a list</p><pre>code\n  block</pre>",
+			"1\\. not a list\n\n```\ncode\n  block\n```"},
+		{"<pre>code\n\n\n\nwith blank lines \n  \nend</pre><blockquote><pre>quoted\n\n\n  </pre></blockquote>",
+			"```\ncode\n\n\n\nwith blank lines \n  \nend\n```\n\n> ```\n> quoted\n>\n>\n>   \n> ```"},
+	}
+	for _, test := range tests {
+		if md := convertBody(t, test.html); md != test.md {
+			t.Errorf("converting %v\n got: %q\nwant: %q", test.html, md, test.md)
+		}
+	}
+}
+
+func TestConvertMissingImage(t *testing.T) {
+	book, err := raw.NewEpub(gcdxyPath)
+	if err != nil {
+		t.Fatalf("NewEpub(%v) return an error: %v", gcdxyPath, err)
+	}
+	defer book.Close()
+
+	missing := []string{}
+	c := converter{
+		epub: book,
+		opts: Options{
+			AssetsDir:    DefaultAssetsDir,
+			WriteAsset:   func(name string, content io.Reader) error { return nil },
+			MissingAsset: func(href string, err error) { missing = append(missing, href) },
+		},
+		href:       "text/chapter.xhtml",
+		assets:     make(map[string]string),
+		assetNames: make(map[string]bool),
+	}
+	for i := 0; i < 2; i++ {
+		if md := c.image("../images/missing.png", "a *figure*"); md != "a \\*figure\\*" {
+			t.Errorf("the missing image is %q", md)
+		}
+	}
+	if c.err != nil || len(missing) != 1 || missing[0] != "images/missing.png" {
+		t.Errorf("the missing images are %v (%v)", missing, c.err)
+	}
+}
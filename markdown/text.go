@@ -0,0 +1,187 @@
+package markdown
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ssor/epubgo/internal/docutil"
+	"golang.org/x/net/html"
+)
+
+// elements whose content is not converted
+var skipped = map[string]bool{
+	"head":     true,
+	"script":   true,
+	"style":    true,
+	"template": true,
+	"title":    true,
+}
+
+func isBlock(n *html.Node) bool {
+	return n.Type == html.ElementNode && (docutil.IsBlockElement(n.Data) || skipped[n.Data] || isNote(n))
+}
+
+// isNote returns whether n is the content of a footnote or endnote
+func isNote(n *html.Node) bool {
+	return hasType(n, "footnote") || hasType(n, "endnote") || hasType(n, "rearnote") ||
+		docutil.Attr(n, "role") == "doc-footnote" || docutil.Attr(n, "role") == "doc-endnote"
+}
+
+// hasType returns whether the epub:type attribute of n includes the value
+func hasType(n *html.Node, value string) bool {
+	for _, t := range strings.Fields(docutil.Attr(n, "epub:type")) {
+		if t == value {
+			return true
+		}
+	}
+	return false
+}
+
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(textContent(c))
+	}
+	return b.String()
+}
+
+var schemeRegexp = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+
+// isExternal returns whether the url points outside of the book
+func isExternal(url string) bool {
+	return schemeRegexp.MatchString(url)
+}
+
+var slugRegexp = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+func slug(s string) string {
+	s = strings.Trim(slugRegexp.ReplaceAllString(s, "-"), "-")
+	if s == "" {
+		return "section"
+	}
+	return s
+}
+
+// uniqueName returns name or a variation of it that is not on used, and adds
+// it to used
+func uniqueName(name string, used map[string]bool) string {
+	unique := name
+	ext := ""
+	if i := strings.LastIndex(name, "."); i > 0 {
+		name, ext = name[:i], name[i:]
+	}
+	for i := 2; used[unique]; i++ {
+		unique = name + "-" + strconv.Itoa(i) + ext
+	}
+	used[unique] = true
+	return unique
+}
+
+var spacesRegexp = regexp.MustCompile(`[ \t\r\n\f]+`)
+
+func collapseSpaces(s string) string {
+	return spacesRegexp.ReplaceAllString(s, " ")
+}
+
+var markdownSpecial = strings.NewReplacer(
+	`\`, `\\`,
+	"*", `\*`,
+	"_", `\_`,
+	"`", "\\`",
+	"[", `\[`,
+	"]", `\]`,
+	"<", `&lt;`,
+)
+
+func escape(s string) string {
+	return markdownSpecial.Replace(s)
+}
+
+var blockStart = regexp.MustCompile(`^([#>+=-]|[0-9]+[.)])`)
+var innerSpaces = regexp.MustCompile(` {2,}`)
+
+// paragraph cleans up the white spaces of inline content and escapes the
+// characters that would start another kind of block
+func paragraph(s string) string {
+	lines := strings.Split(s, "\n")
+	out := lines[:0]
+	for _, line := range lines {
+		line = strings.TrimSpace(innerSpaces.ReplaceAllString(line, " "))
+		if line != "" && line != `\` {
+			out = append(out, line)
+		}
+	}
+	p := strings.TrimSuffix(strings.Join(out, "\n"), `\`)
+	if m := blockStart.FindString(p); m != "" {
+		p = p[:len(m)-1] + `\` + p[len(m)-1:]
+	}
+	return p
+}
+
+// wrap surrounds the text with the emphasis delimiter, keeping the spaces
+// on the borders outside of it
+func wrap(s, delimiter string) string {
+	trimmed := strings.TrimSpace(s)
+	if trimmed == "" {
+		return s
+	}
+	start := s[:strings.Index(s, trimmed)]
+	end := s[len(start)+len(trimmed):]
+	return start + delimiter + trimmed + delimiter + end
+}
+
+func codeSpan(s string) string {
+	s = collapseSpaces(s)
+	ticks := "`"
+	for strings.Contains(s, ticks) {
+		ticks += "`"
+	}
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") {
+		s = " " + s + " "
+	}
+	return ticks + s + ticks
+}
+
+func fence(s string) string {
+	marker := "```"
+	for strings.Contains(s, marker) {
+		marker += "`"
+	}
+	return marker + "\n" + strings.Trim(s, "\n") + "\n" + marker
+}
+
+// prefixLines adds prefix at the start of every non empty line of s, and
+// empty on the empty ones
+func prefixLines(s, prefix, empty string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = empty
+		} else {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func joinBlocks(blocks ...string) string {
+	nonEmpty := []string{}
+	for _, block := range blocks {
+		if block != "" {
+			nonEmpty = append(nonEmpty, block)
+		}
+	}
+	return strings.Join(nonEmpty, "\n\n")
+}
+
+// destination returns the url as a link destination
+func destination(url string) string {
+	if strings.ContainsAny(url, " ()<>") {
+		return "<" + strings.Replace(url, ">", "%3E", -1) + ">"
+	}
+	return url
+}
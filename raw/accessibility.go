@@ -0,0 +1,324 @@
+package raw
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Accessibility is the accessibility metadata of the book, the schema.org
+// properties and the conformance claims of EPUB Accessibility
+type Accessibility struct {
+	AccessModes []string
+	// AccessModesSufficient are comma separated sets of access modes, like
+	// "textual,visual"
+	AccessModesSufficient []string
+	Features              []string
+	Hazards               []string
+	Summary               string
+	// ConformsTo are the dcterms:conformsTo of the publication, like
+	// "EPUB Accessibility 1.1 - WCAG 2.1 Level AA"
+	ConformsTo  []string
+	CertifiedBy string
+}
+
+// AccessibilityReport is the accessibility metadata of the book and the
+// problems found on its content documents
+type AccessibilityReport struct {
+	Metadata Accessibility
+	// Findings are in the order of the spine, the ones about the whole
+	// publication go first
+	Findings []AccessibilityFinding
+}
+
+// AccessibilityFinding is a problem found checking the accessibility
+type AccessibilityFinding struct {
+	// File is the href of the document relative to the opf, "" for the
+	// problems of the whole publication
+	File string
+	// Check is "metadata", "document", "image-alt", "html-lang",
+	// "heading-order", "table-header", "page-list" or "nav-heading"
+	Check   string
+	Message string
+}
+
+// Files returns the findings grouped by the file they were found on
+func (r AccessibilityReport) Files() map[string][]AccessibilityFinding {
+	files := make(map[string][]AccessibilityFinding)
+	for _, finding := range r.Findings {
+		files[finding.File] = append(files[finding.File], finding)
+	}
+	return files
+}
+
+// Accessibility returns the accessibility metadata of the book
+//
+// The schema.org properties are read from the EPUB 3 metas and from the
+// name/content metas used on EPUB 2.
+func (e Epub) Accessibility() Accessibility {
+	var a Accessibility
+	for _, m := range e.opf.Metadata.Meta {
+		property, value := m.Property, strings.TrimSpace(m.Data)
+		if property == "" {
+			property, value = m.Name, strings.TrimSpace(m.Content)
+		}
+		if m.Refines != "" || value == "" {
+			continue
+		}
+		switch strings.TrimPrefix(property, "schema:") {
+		case "accessMode":
+			a.AccessModes = append(a.AccessModes, value)
+		case "accessModeSufficient":
+			a.AccessModesSufficient = append(a.AccessModesSufficient, value)
+		case "accessibilityFeature":
+			a.Features = append(a.Features, value)
+		case "accessibilityHazard":
+			a.Hazards = append(a.Hazards, value)
+		case "accessibilitySummary":
+			a.Summary = value
+		case "dcterms:conformsTo":
+			a.ConformsTo = append(a.ConformsTo, value)
+		case "a11y:certifiedBy":
+			a.CertifiedBy = value
+		}
+	}
+	for _, l := range e.opf.Metadata.Link {
+		if l.Refines == "" && l.Rel == "dcterms:conformsTo" && l.Href != "" {
+			a.ConformsTo = append(a.ConformsTo, l.Href)
+		}
+	}
+	return a
+}
+
+// hasFeature returns whether the feature is on the accessibilityFeature list
+func (a Accessibility) hasFeature(feature string) bool {
+	for _, f := range a.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// AccessibilityReport checks the accessibility metadata and the content
+// documents of the spine
+//
+// The content checks look for images without alt, documents without
+// language, jumps on the heading levels, tables without headers, books
+// from a print source without page list, and headings missing on the
+// index. The index is expected to reach the headings up to the deepest
+// level it links to.
+func (e Epub) AccessibilityReport() AccessibilityReport {
+	r := AccessibilityReport{Metadata: e.Accessibility()}
+	r.checkMetadata()
+	if e.hasPrintSource(r.Metadata) && !e.hasPageList() {
+		r.add("", "page-list", "The book declares a print source but has no page list")
+	}
+
+	texts := []*DocumentText{}
+	// position of the documents on the spine, the findings of the book first
+	order := map[string]int{"": -1}
+	for i := 0; i < e.opf.spineLength(); i++ {
+		item := e.opf.spineItem(i)
+		if item == nil || !isTextContent(item.MediaType) {
+			continue
+		}
+		if _, ok := order[item.Href]; !ok {
+			order[item.Href] = i
+		}
+		doc, err := e.ParseDocument(item.Href)
+		if err != nil {
+			r.add(item.Href, "document", "Can't parse the document: "+err.Error())
+			continue
+		}
+		r.checkDocument(item.Href, doc)
+		text := newDocumentText(doc)
+		text.Href = item.Href
+		text.SpineIndex = i
+		texts = append(texts, text)
+	}
+	r.checkNavHeadings(e.navTargets(), texts)
+
+	sort.SliceStable(r.Findings, func(i, j int) bool {
+		return order[r.Findings[i].File] < order[r.Findings[j].File]
+	})
+	return r
+}
+
+func (r *AccessibilityReport) add(file, check, message string) {
+	r.Findings = append(r.Findings, AccessibilityFinding{File: file, Check: check, Message: message})
+}
+
+func (r *AccessibilityReport) checkMetadata() {
+	m := r.Metadata
+	for _, required := range []struct {
+		name    string
+		missing bool
+	}{
+		{"schema:accessMode", len(m.AccessModes) == 0},
+		{"schema:accessModeSufficient", len(m.AccessModesSufficient) == 0},
+		{"schema:accessibilityFeature", len(m.Features) == 0},
+		{"schema:accessibilityHazard", len(m.Hazards) == 0},
+		{"schema:accessibilitySummary", m.Summary == ""},
+		{"dcterms:conformsTo", len(m.ConformsTo) == 0},
+	} {
+		if required.missing {
+			r.add("", "metadata", "Missing "+required.name+" metadata")
+		}
+	}
+}
+
+// hasPrintSource returns whether the book declares to reproduce the pages
+// of a print edition
+func (e Epub) hasPrintSource(a Accessibility) bool {
+	if len(e.opf.Metadata.Source) > 0 || a.hasFeature("printPageNumbers") || a.hasFeature("pageBreakMarkers") {
+		return true
+	}
+	for _, m := range e.opf.Metadata.Meta {
+		if m.Property == "pageBreakSource" || m.Property == "a11y:pageBreakSource" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPageList returns whether the NCX or the navigation document have a
+// page list
+func (e Epub) hasPageList() bool {
+	if e.NCX != nil && len(e.NCX.PageList) > 0 {
+		return true
+	}
+	_, pages, err := e.parseNav()
+	return err == nil && len(pages) > 0
+}
+
+func (r *AccessibilityReport) checkDocument(href string, doc *html.Node) {
+	if root := findElement(doc, "html"); root != nil && attr(root, "lang") == "" && attr(root, "xml:lang") == "" {
+		r.add(href, "html-lang", "The html element has no lang")
+	}
+
+	lastHeading := 0
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Namespace == "" {
+			switch n.Data {
+			case "head", "script", "style", "template":
+				return
+			case "img":
+				if _, ok := attrValue(n, "alt"); !ok {
+					r.add(href, "image-alt", "The image "+attr(n, "src")+" has no alt")
+				}
+			case "h1", "h2", "h3", "h4", "h5", "h6":
+				level := int(n.Data[1] - '0')
+				if lastHeading != 0 && level > lastHeading+1 {
+					r.add(href, "heading-order", "The heading "+strconv.Quote(nodeText(n))+" jumps from h"+
+						strconv.Itoa(lastHeading)+" to h"+strconv.Itoa(level))
+				}
+				lastHeading = level
+			case "table":
+				role := attr(n, "role")
+				if role != "presentation" && role != "none" && findElement(n, "th") == nil {
+					r.add(href, "table-header", "A table has no header cells")
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+}
+
+// attrValue returns the value of the attribute key and whether it's present
+func attrValue(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// navTargets returns the fragments each file is linked with from the NCX
+// and the navigation document, "" for the links to the start of the file
+func (e Epub) navTargets() map[string][]string {
+	targets := make(map[string][]string)
+	for _, point := range e.NavPoints().flatten() {
+		file, fragment := e.NavPointHref(point)
+		if file != "" {
+			targets[cleanHref(file)] = append(targets[cleanHref(file)], fragment)
+		}
+	}
+	toc, _, err := e.parseNav()
+	if err != nil {
+		return targets
+	}
+	var add func(entries []*navEntry)
+	add = func(entries []*navEntry) {
+		for _, entry := range entries {
+			if entry.file != "" {
+				targets[cleanHref(entry.file)] = append(targets[cleanHref(entry.file)], entry.fragment)
+			}
+			add(entry.children)
+		}
+	}
+	add(toc)
+	return targets
+}
+
+// checkNavHeadings reports the headings that no entry of the index links to
+//
+// A link reaches a heading if there is no text between where it points and
+// the heading. Only the headings up to the deepest level reached are
+// expected on the index, or the top level ones if none is reached.
+func (r *AccessibilityReport) checkNavHeadings(targets map[string][]string, texts []*DocumentText) {
+	type heading struct {
+		text    *DocumentText
+		block   TextBlock
+		level   int
+		reached bool
+	}
+	headings := []heading{}
+	maxReached, minLevel := 0, 7
+	for _, text := range texts {
+		offsets := []int{}
+		for _, fragment := range targets[cleanHref(text.Href)] {
+			if fragment == "" {
+				offsets = append(offsets, 0)
+			} else if offset, ok := text.AnchorOffset(fragment); ok {
+				offsets = append(offsets, offset)
+			}
+		}
+		sort.Ints(offsets)
+
+		prevEnd := -1
+		for _, block := range text.Blocks {
+			if len(block.Tag) == 2 && block.Tag[0] == 'h' && block.Tag[1] >= '1' && block.Tag[1] <= '6' {
+				h := heading{text: text, block: block, level: int(block.Tag[1] - '0')}
+				i := sort.SearchInts(offsets, prevEnd+1)
+				h.reached = i < len(offsets) && offsets[i] < block.End
+				if h.reached && h.level > maxReached {
+					maxReached = h.level
+				}
+				if h.level < minLevel {
+					minLevel = h.level
+				}
+				headings = append(headings, h)
+			}
+			prevEnd = block.End
+		}
+	}
+
+	limit := maxReached
+	if limit == 0 {
+		limit = minLevel
+	}
+	for _, h := range headings {
+		if !h.reached && h.level <= limit {
+			r.add(h.text.Href, "nav-heading", "The heading "+strconv.Quote(h.text.Text[h.block.Start:h.block.End])+
+				" is not on the index")
+		}
+	}
+}
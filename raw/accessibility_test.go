@@ -0,0 +1,108 @@
+package raw
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAccessibilityMetadata(t *testing.T) {
+	const opf = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <meta property="schema:accessMode">textual</meta>
+    <meta property="schema:accessMode">visual</meta>
+    <meta property="schema:accessModeSufficient">textual</meta>
+    <meta property="schema:accessibilityFeature">alternativeText</meta>
+    <meta property="schema:accessibilityHazard">none</meta>
+    <meta property="schema:accessibilitySummary">Images have descriptions.</meta>
+    <meta property="dcterms:conformsTo" id="conf">EPUB Accessibility 1.1 - WCAG 2.1 Level AA</meta>
+    <meta property="a11y:certifiedBy" refines="#conf">Some Agency</meta>
+    <meta name="schema:accessibilityFeature" content="tableOfContents"/>
+    <link rel="dcterms:conformsTo" href="http://www.idpf.org/epub/a11y/accessibility-20170105.html#wcag-aa"/>
+  </metadata>
+  <manifest/>
+  <spine/>
+</package>`
+	a := newTestEpub(t, map[string]string{"content.opf": opf}).Accessibility()
+	if strings.Join(a.AccessModes, ",") != "textual,visual" || len(a.AccessModesSufficient) != 1 {
+		t.Errorf("The access modes are %v and %v", a.AccessModes, a.AccessModesSufficient)
+	}
+	if strings.Join(a.Features, ",") != "alternativeText,tableOfContents" || strings.Join(a.Hazards, ",") != "none" {
+		t.Errorf("The features are %v and the hazards %v", a.Features, a.Hazards)
+	}
+	if a.Summary != "Images have descriptions." || a.CertifiedBy != "" {
+		t.Errorf("The summary is %q and the certifier %q", a.Summary, a.CertifiedBy)
+	}
+	if len(a.ConformsTo) != 2 || a.ConformsTo[0] != "EPUB Accessibility 1.1 - WCAG 2.1 Level AA" {
+		t.Errorf("The conformance is %v", a.ConformsTo)
+	}
+}
+
+func TestAccessibilityReport(t *testing.T) {
+	const nav = `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="en"><body>
+<nav epub:type="toc"><ol>
+  <li><a href="ch1.xhtml">One</a><ol><li><a href="ch1.xhtml#s2">Two</a></li></ol></li>
+  <li><a href="ch2.xhtml">Three</a></li>
+</ol></nav></body></html>`
+	const ch1 = `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="en"><body>
+<h1>One</h1>
+<p><img src="a.png" alt=""/><img src="b.png"/></p>
+<section id="s2"><h2>Two</h2><h4>Deep</h4><p>Text</p></section>
+<h2>Missing</h2>
+<table><tr><td>1</td></tr></table>
+<table><tr><th>A</th></tr><tr><td>1</td></tr></table>
+<table role="presentation"><tr><td>1</td></tr></table>
+</body></html>`
+	const ch2 = `<html><body><p>Intro</p><h1>Three</h1></body></html>`
+	const opf = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:source>urn:isbn:9780000000000</dc:source>
+    <meta property="schema:accessMode">textual</meta>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ch1" href="ch1.xhtml" media-type="application/xhtml+xml"/>
+    <item id="ch2" href="ch2.xhtml" media-type="application/xhtml+xml"/>
+    <item id="ch3" href="ch3.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine><itemref idref="ch1"/><itemref idref="ch2"/><itemref idref="ch3"/></spine>
+</package>`
+
+	e := newTestEpub(t, map[string]string{
+		"content.opf": opf,
+		"nav.xhtml":   nav,
+		"ch1.xhtml":   ch1,
+		"ch2.xhtml":   ch2,
+	})
+
+	r := e.AccessibilityReport()
+	expected := []AccessibilityFinding{
+		{"", "metadata", "Missing schema:accessModeSufficient metadata"},
+		{"", "metadata", "Missing schema:accessibilityFeature metadata"},
+		{"", "metadata", "Missing schema:accessibilityHazard metadata"},
+		{"", "metadata", "Missing schema:accessibilitySummary metadata"},
+		{"", "metadata", "Missing dcterms:conformsTo metadata"},
+		{"", "page-list", "The book declares a print source but has no page list"},
+		{"ch1.xhtml", "image-alt", "The image b.png has no alt"},
+		{"ch1.xhtml", "heading-order", `The heading "Deep" jumps from h2 to h4`},
+		{"ch1.xhtml", "table-header", "A table has no header cells"},
+		{"ch1.xhtml", "nav-heading", `The heading "Missing" is not on the index`},
+		{"ch2.xhtml", "html-lang", "The html element has no lang"},
+		{"ch2.xhtml", "nav-heading", `The heading "Three" is not on the index`},
+		{"ch3.xhtml", "document", "Can't parse the document: File ch3.xhtml not found"},
+	}
+	if len(r.Findings) != len(expected) {
+		t.Fatalf("The findings are %+v", r.Findings)
+	}
+	for i, finding := range r.Findings {
+		if finding != expected[i] {
+			t.Errorf("The finding %d is %+v, the expected was %+v", i, finding, expected[i])
+		}
+	}
+	if files := r.Files(); len(files) != 4 || len(files["ch1.xhtml"]) != 4 {
+		t.Errorf("The findings by file are %+v", files)
+	}
+}
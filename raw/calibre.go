@@ -0,0 +1,182 @@
+package raw
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Series is a series, or other collection, the book belongs to
+type Series struct {
+	Name string
+	// Position is the place of the book on the series, like 2 or 1.5, 0
+	// if it's unknown
+	Position float64
+	// Type is "series" or "set" for the EPUB 3 collections, "" if it's not
+	// set. The calibre series are "series".
+	Type string
+}
+
+// VendorMetadata is the metadata added by calibre and the EPUB 3
+// collections, on the opf of the book or on a metadata.opf sidecar
+type VendorMetadata struct {
+	Title     string
+	TitleSort string
+	Authors   []string
+	// Series has the EPUB 3 collections first, with the calibre series
+	// merged into the one of the same name
+	Series []Series
+	// Rating goes from 0 to 5 stars, 0 if the book is not rated
+	Rating float64
+	// Tags are the subjects of the book, calibre stores its tags there
+	Tags []string
+	// UserCategories are the items of each calibre user category
+	UserCategories map[string][]string
+	// Timestamp is when the book was added to calibre
+	Timestamp string
+}
+
+// VendorMetadata returns the calibre and EPUB 3 collection metadata of the
+// book
+func (e Epub) VendorMetadata() VendorMetadata {
+	return e.opf.vendorMetadata()
+}
+
+// ReadMetadataOPF reads the calibre metadata.opf sidecar file path
+func ReadMetadataOPF(path string) (VendorMetadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return VendorMetadata{}, err
+	}
+	defer f.Close()
+	opf, err := parseOPF(f)
+	if err != nil {
+		return VendorMetadata{}, err
+	}
+	return opf.vendorMetadata(), nil
+}
+
+// SidecarMetadata reads the metadata.opf that calibre places on the
+// directory of each book of its library, next to the book on bookPath
+func SidecarMetadata(bookPath string) (VendorMetadata, error) {
+	return ReadMetadataOPF(filepath.Join(filepath.Dir(bookPath), "metadata.opf"))
+}
+
+func (opf xmlOPF) vendorMetadata() VendorMetadata {
+	m := VendorMetadata{Series: []Series{}, Tags: []string{}, UserCategories: map[string][]string{}}
+	if len(opf.Metadata.Title) > 0 {
+		m.Title = strings.TrimSpace(opf.Metadata.Title[0])
+	}
+	for _, creator := range opf.Metadata.Creator {
+		if name := strings.TrimSpace(creator.Data); name != "" {
+			m.Authors = append(m.Authors, name)
+		}
+	}
+	for _, subject := range opf.Metadata.Subject {
+		if tag := strings.TrimSpace(subject); tag != "" {
+			m.Tags = append(m.Tags, tag)
+		}
+	}
+	m.Series = opf.collections()
+
+	calibreSeries := Series{Type: "series"}
+	for _, meta := range opf.Metadata.Meta {
+		name, value := meta.Name, strings.TrimSpace(meta.Content)
+		if name == "" {
+			name, value = meta.Property, strings.TrimSpace(meta.Data)
+		}
+		switch name {
+		case "calibre:series":
+			calibreSeries.Name = value
+		case "calibre:series_index":
+			calibreSeries.Position, _ = strconv.ParseFloat(value, 64)
+		case "calibre:rating":
+			// calibre stores the double of the stars
+			if rating, err := strconv.ParseFloat(value, 64); err == nil {
+				m.Rating = rating / 2
+			}
+		case "calibre:title_sort":
+			m.TitleSort = value
+		case "calibre:timestamp":
+			m.Timestamp = value
+		case "calibre:user_categories":
+			m.UserCategories = parseUserCategories(value)
+		}
+	}
+	if calibreSeries.Name != "" {
+		m.Series = mergeSeries(m.Series, calibreSeries)
+	}
+	return m
+}
+
+// collections returns the EPUB 3 belongs-to-collection metas with their
+// collection-type and group-position refinements
+func (opf xmlOPF) collections() []Series {
+	collections := []Series{}
+	for _, meta := range opf.Metadata.Meta {
+		if meta.Property != "belongs-to-collection" || meta.Refines != "" {
+			continue
+		}
+		s := Series{Name: strings.TrimSpace(meta.Data)}
+		if meta.ID != "" {
+			for _, refine := range opf.Metadata.Meta {
+				if refine.Refines != "#"+meta.ID {
+					continue
+				}
+				switch refine.Property {
+				case "collection-type":
+					s.Type = strings.TrimSpace(refine.Data)
+				case "group-position":
+					s.Position, _ = strconv.ParseFloat(strings.TrimSpace(refine.Data), 64)
+				}
+			}
+		}
+		if s.Name != "" {
+			collections = append(collections, s)
+		}
+	}
+	return collections
+}
+
+// mergeSeries adds the series to the list, or fills the gaps of the one
+// with the same name
+func mergeSeries(list []Series, s Series) []Series {
+	for i := range list {
+		if !strings.EqualFold(list[i].Name, s.Name) {
+			continue
+		}
+		if list[i].Position == 0 {
+			list[i].Position = s.Position
+		}
+		if list[i].Type == "" {
+			list[i].Type = s.Type
+		}
+		return list
+	}
+	return append(list, s)
+}
+
+// parseUserCategories parses the calibre:user_categories json, a map of
+// the category names to lists of [item, kind, id]
+func parseUserCategories(value string) map[string][]string {
+	categories := map[string][]string{}
+	var raw map[string][][]interface{}
+	if err := json.Unmarshal([]byte(value), &raw); err != nil {
+		return categories
+	}
+	for name, entries := range raw {
+		items := []string{}
+		for _, entry := range entries {
+			if len(entry) == 0 {
+				continue
+			}
+			if item, ok := entry[0].(string); ok {
+				items = append(items, item)
+			}
+		}
+		categories[name] = items
+	}
+	return categories
+}
@@ -0,0 +1,105 @@
+package raw
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const calibreOPF = `<?xml version='1.0' encoding='utf-8'?>
+<package xmlns="http://www.idpf.org/2007/opf" unique-identifier="uuid_id" version="2.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:opf="http://www.idpf.org/2007/opf" xmlns:calibre="http://calibre.kovidgoyal.net/2009/metadata">
+    <dc:title>Second Foundation</dc:title>
+    <dc:creator opf:role="aut">Isaac Asimov</dc:creator>
+    <dc:subject>Science Fiction</dc:subject>
+    <dc:subject>Classics</dc:subject>
+    <meta name="calibre:series" content="Foundation"/>
+    <meta name="calibre:series_index" content="3.5"/>
+    <meta name="calibre:rating" content="8"/>
+    <meta name="calibre:title_sort" content="Second Foundation"/>
+    <meta name="calibre:timestamp" content="2016-08-16T00:48:34+00:00"/>
+    <meta name="calibre:user_categories" content="{&quot;Favourites&quot;: [[&quot;Isaac Asimov&quot;, &quot;authors&quot;, 0], [&quot;Classics&quot;, &quot;tags&quot;, 0]]}"/>
+  </metadata>
+</package>`
+
+func TestVendorMetadataCalibre(t *testing.T) {
+	m := newTestEpub(t, map[string]string{"content.opf": calibreOPF}).VendorMetadata()
+	if m.Title != "Second Foundation" || m.TitleSort != "Second Foundation" || len(m.Authors) != 1 {
+		t.Errorf("The title and authors are %q, %q, %v", m.Title, m.TitleSort, m.Authors)
+	}
+	if len(m.Series) != 1 || m.Series[0] != (Series{Name: "Foundation", Position: 3.5, Type: "series"}) {
+		t.Errorf("The series are %+v", m.Series)
+	}
+	if m.Rating != 4 {
+		t.Errorf("The rating is %v", m.Rating)
+	}
+	if strings.Join(m.Tags, ",") != "Science Fiction,Classics" {
+		t.Errorf("The tags are %v", m.Tags)
+	}
+	if favourites := m.UserCategories["Favourites"]; strings.Join(favourites, ",") != "Isaac Asimov,Classics" {
+		t.Errorf("The user categories are %v", m.UserCategories)
+	}
+	if m.Timestamp != "2016-08-16T00:48:34+00:00" {
+		t.Errorf("The timestamp is %q", m.Timestamp)
+	}
+}
+
+func TestVendorMetadataCollections(t *testing.T) {
+	e := newTestEpub(t, map[string]string{"content.opf": `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <metadata>
+    <meta property="belongs-to-collection" id="c1">Foundation</meta>
+    <meta property="collection-type" refines="#c1">series</meta>
+    <meta property="belongs-to-collection" id="c2">Asimov Collected</meta>
+    <meta property="collection-type" refines="#c2">set</meta>
+    <meta property="group-position" refines="#c2">12</meta>
+    <meta name="calibre:series" content="foundation"/>
+    <meta name="calibre:series_index" content="2"/>
+  </metadata>
+</package>`})
+
+	m := e.VendorMetadata()
+	expected := []Series{
+		{Name: "Foundation", Position: 2, Type: "series"},
+		{Name: "Asimov Collected", Position: 12, Type: "set"},
+	}
+	if len(m.Series) != len(expected) {
+		t.Fatalf("The series are %+v", m.Series)
+	}
+	for i, s := range m.Series {
+		if s != expected[i] {
+			t.Errorf("The series %d is %+v, the expected was %+v", i, s, expected[i])
+		}
+	}
+}
+
+func TestVendorMetadataBook(t *testing.T) {
+	f, _ := NewEpub("../testdata/the_art_of_learning.epub")
+	defer f.Close()
+
+	m := f.VendorMetadata()
+	if m.TitleSort != "学习之道:美国公认学习第一书" || m.Timestamp == "" {
+		t.Errorf("The calibre metadata is %+v", m)
+	}
+	if len(m.Series) != 0 || m.Rating != 0 {
+		t.Errorf("The book has series %v and rating %v", m.Series, m.Rating)
+	}
+}
+
+func TestSidecarMetadata(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "metadata.opf"), []byte(calibreOPF), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := SidecarMetadata(filepath.Join(dir, "Second Foundation - Isaac Asimov.epub"))
+	if err != nil {
+		t.Fatalf("SidecarMetadata() return an error: %v", err)
+	}
+	if m.Title != "Second Foundation" || len(m.Series) != 1 || m.Series[0].Position != 3.5 {
+		t.Errorf("The sidecar metadata is %+v", m)
+	}
+	if _, err := SidecarMetadata(filepath.Join(t.TempDir(), "book.epub")); err == nil {
+		t.Errorf("SidecarMetadata() didn't fail without sidecar")
+	}
+}
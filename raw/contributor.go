@@ -0,0 +1,198 @@
+package raw
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// marcRelators are the names of the MARC relator codes used on the books
+var marcRelators = map[string]string{
+	"abr": "Abridger",
+	"act": "Actor",
+	"adp": "Adapter",
+	"aft": "Author of afterword",
+	"ann": "Annotator",
+	"ant": "Bibliographic antecedent",
+	"arr": "Arranger",
+	"art": "Artist",
+	"aui": "Author of introduction",
+	"aut": "Author",
+	"bkp": "Book producer",
+	"ccp": "Conceptor",
+	"clr": "Colorist",
+	"cmm": "Commentator",
+	"cmp": "Composer",
+	"com": "Compiler",
+	"cov": "Cover designer",
+	"cre": "Creator",
+	"ctb": "Contributor",
+	"cwt": "Commentator for written text",
+	"dsr": "Designer",
+	"dte": "Dedicatee",
+	"edt": "Editor",
+	"egr": "Engraver",
+	"fmo": "Former owner",
+	"ill": "Illustrator",
+	"isb": "Issuing body",
+	"lyr": "Lyricist",
+	"mus": "Musician",
+	"nrt": "Narrator",
+	"oth": "Other",
+	"pbl": "Publisher",
+	"pht": "Photographer",
+	"prf": "Performer",
+	"prt": "Printer",
+	"red": "Redaktor",
+	"rev": "Reviewer",
+	"spn": "Sponsor",
+	"ths": "Thesis advisor",
+	"trc": "Transcriber",
+	"trl": "Translator",
+}
+
+// RoleName returns the name of the MARC relator code, or the code itself if
+// it's not known
+func RoleName(code string) string {
+	if name, ok := marcRelators[strings.ToLower(code)]; ok {
+		return name
+	}
+	return code
+}
+
+// roleCode returns the MARC relator code of a role, the books made by hand
+// have names like "Translator" instead of the codes
+func roleCode(role string) string {
+	role = strings.ToLower(strings.TrimSpace(role))
+	if _, ok := marcRelators[role]; ok {
+		return role
+	}
+	for code, name := range marcRelators {
+		if strings.ToLower(name) == role {
+			return code
+		}
+	}
+	return role
+}
+
+// Contributor is a creator or contributor of the book
+type Contributor struct {
+	Name   string
+	FileAs string
+	// Roles are the MARC relator codes, like "aut" or "trl"
+	Roles []string
+	// Creator is set for the dc:creator, unset for the dc:contributor
+	Creator bool
+	// DisplaySeq is the display-seq refinement, 0 if it's not set
+	DisplaySeq int
+}
+
+// HasRole returns whether the contributor has the MARC relator code role
+func (c Contributor) HasRole(role string) bool {
+	role = roleCode(role)
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleNames returns the names of the roles of the contributor
+func (c Contributor) RoleNames() []string {
+	names := make([]string, len(c.Roles))
+	for i, r := range c.Roles {
+		names[i] = RoleName(r)
+	}
+	return names
+}
+
+// IsAuthor returns whether the contributor is a primary author: a
+// dc:creator with the aut role or without roles
+func (c Contributor) IsAuthor() bool {
+	return c.Creator && (len(c.Roles) == 0 || c.HasRole("aut"))
+}
+
+// Contributors returns the creators, and then the contributors, of the book
+// on their display order
+//
+// The roles and file-as are the opf attributes of EPUB 2 and the
+// refinements of EPUB 3. The ones with display-seq go first sorted by it,
+// the rest keep the order of the opf.
+func (e Epub) Contributors() []Contributor {
+	creators := e.opf.contributors(e.opf.Metadata.Creator, true)
+	return append(creators, e.opf.contributors(e.opf.Metadata.Contributor, false)...)
+}
+
+// Authors returns the primary authors of the book
+func (e Epub) Authors() []Contributor {
+	authors := []Contributor{}
+	for _, c := range e.Contributors() {
+		if c.IsAuthor() {
+			authors = append(authors, c)
+		}
+	}
+	return authors
+}
+
+// OtherContributors returns the contributors that are not primary authors,
+// like the translators or the illustrators
+func (e Epub) OtherContributors() []Contributor {
+	others := []Contributor{}
+	for _, c := range e.Contributors() {
+		if !c.IsAuthor() {
+			others = append(others, c)
+		}
+	}
+	return others
+}
+
+func (opf xmlOPF) contributors(authors []author, creator bool) []Contributor {
+	contributors := []Contributor{}
+	for _, a := range authors {
+		c := Contributor{
+			Name:    strings.TrimSpace(a.Data),
+			FileAs:  strings.TrimSpace(a.FileAs),
+			Roles:   []string{},
+			Creator: creator,
+		}
+		if c.Name == "" {
+			continue
+		}
+		if a.Role != "" {
+			c.Roles = append(c.Roles, roleCode(a.Role))
+		}
+		if a.ID != "" {
+			for _, m := range opf.Metadata.Meta {
+				if m.Refines != "#"+a.ID {
+					continue
+				}
+				value := strings.TrimSpace(m.Data)
+				switch m.Property {
+				case "role":
+					// only the MARC relators are known, keep the other
+					// schemes as they are
+					if m.Scheme == "" || m.Scheme == "marc:relators" {
+						value = roleCode(value)
+					}
+					if value != "" && !c.HasRole(value) {
+						c.Roles = append(c.Roles, value)
+					}
+				case "file-as":
+					c.FileAs = value
+				case "display-seq":
+					c.DisplaySeq, _ = strconv.Atoi(value)
+				}
+			}
+		}
+		contributors = append(contributors, c)
+	}
+	sort.SliceStable(contributors, func(i, j int) bool {
+		si, sj := contributors[i].DisplaySeq, contributors[j].DisplaySeq
+		if si > 0 && sj > 0 {
+			return si < sj
+		}
+		return si > 0 && sj <= 0
+	})
+	return contributors
+}
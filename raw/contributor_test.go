@@ -0,0 +1,79 @@
+package raw
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestContributorsEPUB3(t *testing.T) {
+	e := newTestEpub(t, map[string]string{"content.opf": `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:creator id="c2">Second Author</dc:creator>
+    <dc:creator id="c1">First Author</dc:creator>
+    <dc:creator>Anonymous</dc:creator>
+    <dc:contributor id="t">Jane Translator</dc:contributor>
+    <dc:contributor id="i">Joe Illustrator</dc:contributor>
+    <meta property="role" refines="#c1" scheme="marc:relators">aut</meta>
+    <meta property="display-seq" refines="#c1">1</meta>
+    <meta property="file-as" refines="#c1">Author, First</meta>
+    <meta property="role" refines="#c2" scheme="marc:relators">aut</meta>
+    <meta property="role" refines="#c2" scheme="marc:relators">ill</meta>
+    <meta property="display-seq" refines="#c2">2</meta>
+    <meta property="role" refines="#t" scheme="marc:relators">trl</meta>
+    <meta property="role" refines="#i" scheme="onix:codelist17">A12</meta>
+    <meta property="role" refines="#i">Illustrator</meta>
+  </metadata>
+</package>`})
+
+	contributors := e.Contributors()
+	names := []string{}
+	for _, c := range contributors {
+		names = append(names, c.Name)
+	}
+	if strings.Join(names, ",") != "First Author,Second Author,Anonymous,Jane Translator,Joe Illustrator" {
+		t.Fatalf("The contributors are %v", names)
+	}
+	if c := contributors[0]; c.FileAs != "Author, First" || c.DisplaySeq != 1 || !c.Creator {
+		t.Errorf("The first contributor is %+v", c)
+	}
+	if roles := strings.Join(contributors[1].RoleNames(), ","); roles != "Author,Illustrator" {
+		t.Errorf("The roles of the second author are %v", roles)
+	}
+	if c := contributors[4]; strings.Join(c.Roles, ",") != "A12,ill" || !c.HasRole("Illustrator") {
+		t.Errorf("The illustrator is %+v", c)
+	}
+
+	if authors := e.Authors(); len(authors) != 3 || !authors[2].IsAuthor() {
+		t.Errorf("The authors are %+v", authors)
+	}
+	others := e.OtherContributors()
+	if len(others) != 2 || !others[0].HasRole("trl") || RoleName(others[0].Roles[0]) != "Translator" {
+		t.Errorf("The other contributors are %+v", others)
+	}
+}
+
+func TestContributorsBook(t *testing.T) {
+	f, err := NewEpub("../testdata/the_art_of_learning.epub")
+	if err != nil {
+		t.Fatalf("Can't open the book: %v", err)
+	}
+	defer f.Close()
+	authors := f.Authors()
+	if len(authors) != 1 || authors[0].Name != "乔希·维茨金" || !authors[0].HasRole("aut") {
+		t.Errorf("The authors are %+v", authors)
+	}
+	others := f.OtherContributors()
+	if len(others) != 1 || !others[0].HasRole("bkp") || others[0].RoleNames()[0] != "Book producer" {
+		t.Errorf("The other contributors are %+v", others)
+	}
+}
+
+func TestRoleName(t *testing.T) {
+	if name := RoleName("NRT"); name != "Narrator" {
+		t.Errorf("RoleName(NRT) = %q", name)
+	}
+	if name := RoleName("xyz"); name != "xyz" {
+		t.Errorf("RoleName(xyz) = %q", name)
+	}
+}
@@ -0,0 +1,292 @@
+package raw
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"golang.org/x/net/html"
+)
+
+// CoverImage returns the href, relative to the opf, of the cover
+//
+// It's the cover-image of the manifest, the item of the cover meta, the
+// cover reference of the guide or the first document of the spine if it
+// looks like a cover. The later ones can be an XHTML page wrapping the
+// image.
+func (e Epub) CoverImage() (string, error) {
+	for _, item := range e.opf.Manifest {
+		for _, property := range strings.Fields(item.Properties) {
+			if property == "cover-image" {
+				return item.Href, nil
+			}
+		}
+	}
+	for _, m := range e.opf.Metadata.Meta {
+		if m.Name != "cover" || m.Content == "" {
+			continue
+		}
+		if href := e.opf.filePath(m.Content); href != "" {
+			return href, nil
+		}
+		// some books have the href instead of the id
+		if e.FileManifest(m.Content) != nil {
+			return m.Content, nil
+		}
+	}
+	for _, ref := range e.opf.Guide {
+		if strings.ToLower(ref.Type) == "cover" && ref.Href != "" {
+			file, _ := splitFragment(ref.Href)
+			return file, nil
+		}
+	}
+	if e.opf.spineLength() == 0 {
+		return "", errors.New("The book has no cover")
+	}
+	if item := e.opf.spineItem(0); item != nil &&
+		(strings.Contains(strings.ToLower(item.ID), "cover") || strings.Contains(strings.ToLower(item.Href), "cover")) {
+		return item.Href, nil
+	}
+	return "", errors.New("The book has no cover")
+}
+
+// CoverThumbnail returns the cover scaled to fit in maxW x maxH, encoded
+// on format "jpeg" or "png"
+//
+// The cover can be a JPEG, PNG, GIF or WebP image, or an XHTML or SVG page
+// wrapping one, which gets rasterized. The cover is never enlarged, and a
+// size of 0 or less doesn't limit that dimension.
+func (e Epub) CoverThumbnail(maxW, maxH int, format string) ([]byte, error) {
+	format = strings.ToLower(format)
+	if format != "jpeg" && format != "jpg" && format != "png" {
+		return nil, errors.New("Unsupported thumbnail format " + format)
+	}
+	href, err := e.CoverImage()
+	if err != nil {
+		return nil, err
+	}
+	img, err := e.coverImage(href, maxW, maxH)
+	if err != nil {
+		return nil, err
+	}
+	img = scaleImage(img, maxW, maxH)
+
+	var b bytes.Buffer
+	if format == "png" {
+		err = png.Encode(&b, img)
+	} else {
+		// jpeg has no transparency, put the cover over white
+		flat := image.NewRGBA(img.Bounds())
+		draw.Draw(flat, flat.Bounds(), image.White, image.Point{}, draw.Src)
+		draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+		err = jpeg.Encode(&b, flat, &jpeg.Options{Quality: 85})
+	}
+	return b.Bytes(), err
+}
+
+// coverImage decodes the cover href, rasterizing the SVG wrappers to fit
+// in maxW x maxH
+func (e Epub) coverImage(href string, maxW, maxH int) (image.Image, error) {
+	mediaType := ""
+	if item := e.FileManifest(href); item != nil {
+		mediaType = item.MediaType
+	}
+	switch {
+	case mediaType == "image/svg+xml":
+		f, err := e.OpenResource(href)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		doc, err := html.Parse(f)
+		if err != nil {
+			return nil, err
+		}
+		svg := findSVG(doc)
+		if svg == nil {
+			return nil, errors.New("The cover " + href + " is not an svg")
+		}
+		return e.rasterizeSVG(svg, href, maxW, maxH)
+	case isTextContent(mediaType) || mediaType == "":
+		doc, err := e.ParseDocument(href)
+		if err != nil {
+			return nil, err
+		}
+		if svg := findSVG(doc); svg != nil {
+			return e.rasterizeSVG(svg, href, maxW, maxH)
+		}
+		if img := findElement(doc, "img"); img != nil && attr(img, "src") != "" {
+			src, _ := splitFragment(attr(img, "src"))
+			return e.decodeImage(resolveHref(href, src))
+		}
+		return nil, errors.New("The cover page " + href + " has no image")
+	}
+	return e.decodeImage(href)
+}
+
+func (e Epub) decodeImage(href string) (image.Image, error) {
+	f, err := e.OpenResource(href)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	return img, err
+}
+
+// findSVG returns the first svg element of the tree
+func findSVG(n *html.Node) *html.Node {
+	if n.Type == html.ElementNode && n.Data == "svg" {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findSVG(c); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// svgImages returns the image elements of the svg
+func svgImages(n *html.Node) []*html.Node {
+	images := []*html.Node{}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		if c.Data == "image" {
+			images = append(images, c)
+		} else {
+			images = append(images, svgImages(c)...)
+		}
+	}
+	return images
+}
+
+// svgHref returns the xlink:href or href of an svg element
+func svgHref(n *html.Node) string {
+	for _, a := range n.Attr {
+		if (a.Key == "xlink:href") || (a.Namespace == "xlink" && a.Key == "href") {
+			return a.Val
+		}
+	}
+	return attr(n, "href")
+}
+
+// rasterizeSVG draws the raster images of the svg, found on the file href,
+// on a white canvas of the size of the svg scaled to fit in maxW x maxH
+//
+// Only the image elements are drawn, the svg wrappers of the covers have
+// nothing else.
+func (e Epub) rasterizeSVG(svg *html.Node, href string, maxW, maxH int) (image.Image, error) {
+	type placed struct {
+		img        image.Image
+		x, y, w, h float64
+		aspect     string
+	}
+	images := []placed{}
+	for _, n := range svgImages(svg) {
+		src, _ := splitFragment(svgHref(n))
+		if src == "" || strings.HasPrefix(src, "data:") {
+			continue
+		}
+		img, err := e.decodeImage(resolveHref(href, src))
+		if err != nil {
+			return nil, err
+		}
+		p := placed{
+			img:    img,
+			x:      svgLength(attr(n, "x")),
+			y:      svgLength(attr(n, "y")),
+			w:      svgLength(attr(n, "width")),
+			h:      svgLength(attr(n, "height")),
+			aspect: attr(n, "preserveAspectRatio"),
+		}
+		if p.w <= 0 || p.h <= 0 {
+			p.w, p.h = float64(img.Bounds().Dx()), float64(img.Bounds().Dy())
+		}
+		images = append(images, p)
+	}
+	if len(images) == 0 {
+		return nil, errors.New("The svg of the cover " + href + " has no raster image")
+	}
+
+	minX, minY, width, height := 0.0, 0.0, svgLength(attr(svg, "width")), svgLength(attr(svg, "height"))
+	if box := strings.Fields(strings.Replace(attr(svg, "viewBox"), ",", " ", -1)); len(box) == 4 {
+		minX, minY = svgLength(box[0]), svgLength(box[1])
+		width, height = svgLength(box[2]), svgLength(box[3])
+	}
+	if width <= 0 || height <= 0 {
+		if len(images) == 1 {
+			return images[0].img, nil
+		}
+		width, height = images[0].x+images[0].w, images[0].y+images[0].h
+	}
+
+	scale := fitScale(width, height, maxW, maxH)
+	canvas := image.NewRGBA(image.Rect(0, 0, int(math.Max(1, math.Round(width*scale))), int(math.Max(1, math.Round(height*scale)))))
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	for _, p := range images {
+		x, y, w, h := p.x-minX, p.y-minY, p.w, p.h
+		if !strings.HasPrefix(p.aspect, "none") {
+			// xMidYMid meet, or slice
+			imgW, imgH := float64(p.img.Bounds().Dx()), float64(p.img.Bounds().Dy())
+			s := math.Min(w/imgW, h/imgH)
+			if strings.HasSuffix(p.aspect, "slice") {
+				s = math.Max(w/imgW, h/imgH)
+			}
+			x, y = x+(w-imgW*s)/2, y+(h-imgH*s)/2
+			w, h = imgW*s, imgH*s
+		}
+		rect := image.Rect(int(math.Round(x*scale)), int(math.Round(y*scale)),
+			int(math.Round((x+w)*scale)), int(math.Round((y+h)*scale)))
+		xdraw.CatmullRom.Scale(canvas, rect, p.img, p.img.Bounds(), xdraw.Over, nil)
+	}
+	return canvas, nil
+}
+
+// svgLength parses an svg length in user units, like "600" or "600px"
+func svgLength(value string) float64 {
+	n, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "px"), 64)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// fitScale returns the scale to fit width x height in maxW x maxH, never
+// bigger than 1
+func fitScale(width, height float64, maxW, maxH int) float64 {
+	scale := 1.0
+	if maxW > 0 && width > float64(maxW) {
+		scale = float64(maxW) / width
+	}
+	if maxH > 0 && height*scale > float64(maxH) {
+		scale = float64(maxH) / height
+	}
+	return scale
+}
+
+// scaleImage resizes the image to fit in maxW x maxH keeping its aspect
+func scaleImage(img image.Image, maxW, maxH int) image.Image {
+	bounds := img.Bounds()
+	scale := fitScale(float64(bounds.Dx()), float64(bounds.Dy()), maxW, maxH)
+	if scale == 1 {
+		return img
+	}
+	w := int(math.Max(1, math.Round(float64(bounds.Dx())*scale)))
+	h := int(math.Max(1, math.Round(float64(bounds.Dy())*scale)))
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, xdraw.Src, nil)
+	return dst
+}
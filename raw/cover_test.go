@@ -0,0 +1,108 @@
+package raw
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func TestCoverThumbnail(t *testing.T) {
+	for _, test := range []struct {
+		book, cover string
+	}{
+		{bookPath, "@public@vhost@g@gutenberg@html@files@3174@3174-h@images@cover.jpg"},
+		{"../testdata/gcdxy.epub", "images/cover.jpg"},
+	} {
+		f, err := NewEpub(test.book)
+		if err != nil {
+			t.Fatalf("Can't open %v: %v", test.book, err)
+		}
+		if cover, err := f.CoverImage(); err != nil || cover != test.cover {
+			t.Errorf("The cover of %v is %q (%v)", test.book, cover, err)
+		}
+		data, err := f.CoverThumbnail(120, 160, "jpeg")
+		f.Close()
+		if err != nil {
+			t.Fatalf("CoverThumbnail() of %v return an error: %v", test.book, err)
+		}
+		config, err := jpeg.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("The thumbnail of %v is not a jpeg: %v", test.book, err)
+		}
+		if config.Width > 120 || config.Height > 160 || (config.Width != 120 && config.Height != 160) {
+			t.Errorf("The thumbnail of %v is %dx%d", test.book, config.Width, config.Height)
+		}
+	}
+}
+
+func TestCoverImageEmptySpine(t *testing.T) {
+	e := newTestEpub(t, map[string]string{"content.opf": `<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+<metadata/><manifest/><spine/></package>`})
+	if cover, err := e.CoverImage(); err == nil {
+		t.Errorf("The cover of a book without spine is %q", cover)
+	}
+}
+
+func TestCoverThumbnailSVGWrapper(t *testing.T) {
+	// a red image of 400x200 wrapped on a square svg
+	img := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 400; x++ {
+			img.Set(x, y, color.RGBA{255, 0, 0, 255})
+		}
+	}
+	var imgData bytes.Buffer
+	png.Encode(&imgData, img)
+
+	const page = `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:xlink="http://www.w3.org/1999/xlink"><body>
+<svg xmlns="http://www.w3.org/2000/svg" version="1.1" viewBox="0 0 400 400" preserveAspectRatio="xMidYMid meet">
+  <image width="400" height="400" xlink:href="../images/cover.png"/>
+</svg></body></html>`
+
+	const opf = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="2.0">
+<metadata/>
+<manifest>
+<item id="cover" href="text/cover.xhtml" media-type="application/xhtml+xml"/>
+<item id="img" href="images/cover.png" media-type="image/png"/>
+</manifest>
+<spine/>
+<guide><reference type="cover" title="Cover" href="text/cover.xhtml#top"/></guide>
+</package>`
+
+	e := newTestEpub(t, map[string]string{
+		"content.opf":      opf,
+		"text/cover.xhtml": page,
+		"images/cover.png": imgData.String(),
+	})
+	if cover, _ := e.CoverImage(); cover != "text/cover.xhtml" {
+		t.Errorf("The cover is %q", cover)
+	}
+
+	data, err := e.CoverThumbnail(100, 0, "png")
+	if err != nil {
+		t.Fatalf("CoverThumbnail() return an error: %v", err)
+	}
+	thumb, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("The thumbnail is not a png: %v", err)
+	}
+	if thumb.Bounds().Dx() != 100 || thumb.Bounds().Dy() != 100 {
+		t.Fatalf("The thumbnail is %v", thumb.Bounds())
+	}
+	// the image is centered on the svg with white bands
+	if r, g, b, _ := thumb.At(50, 50).RGBA(); r>>8 != 255 || g>>8 != 0 || b>>8 != 0 {
+		t.Errorf("The center of the thumbnail is %v", thumb.At(50, 50))
+	}
+	if r, g, b, _ := thumb.At(50, 5).RGBA(); r>>8 != 255 || g>>8 != 255 || b>>8 != 255 {
+		t.Errorf("The top of the thumbnail is %v", thumb.At(50, 5))
+	}
+
+	if _, err := e.CoverThumbnail(100, 100, "bmp"); err == nil {
+		t.Errorf("CoverThumbnail() didn't fail with an unknown format")
+	}
+}
@@ -0,0 +1,245 @@
+package raw
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DatePrecision is how much of a date is known
+type DatePrecision int
+
+const (
+	PrecisionYear DatePrecision = iota + 1
+	PrecisionMonth
+	PrecisionDay
+	PrecisionTimestamp
+)
+
+func (p DatePrecision) String() string {
+	switch p {
+	case PrecisionYear:
+		return "year"
+	case PrecisionMonth:
+		return "month"
+	case PrecisionDay:
+		return "day"
+	case PrecisionTimestamp:
+		return "timestamp"
+	}
+	return "unknown"
+}
+
+// Date is a date of the metadata
+type Date struct {
+	// Time is the start of the period known, January 1st for the dates
+	// with only the year, on UTC if the date has no time zone
+	Time      time.Time
+	Precision DatePrecision
+	// Raw is the date as it's written
+	Raw string
+	// Event is "publication", "creation", "modification" or the opf:event
+	// of the date lowercased, "" if it's unknown
+	Event string
+}
+
+// Year returns the year of the date
+func (d Date) Year() int {
+	return d.Time.Year()
+}
+
+// String returns the date on ISO 8601 up to its precision, like "1978-11"
+func (d Date) String() string {
+	switch d.Precision {
+	case PrecisionYear:
+		return d.Time.Format("2006")
+	case PrecisionMonth:
+		return d.Time.Format("2006-01")
+	case PrecisionDay:
+		return d.Time.Format("2006-01-02")
+	case PrecisionTimestamp:
+		return d.Time.Format(time.RFC3339)
+	}
+	return d.Raw
+}
+
+// the layouts of the dates found in the wild, by precision
+var dateLayouts = []struct {
+	layout    string
+	precision DatePrecision
+}{
+	{"2006-1-2T15:04:05Z07:00", PrecisionTimestamp},
+	{"2006-1-2T15:04:05Z0700", PrecisionTimestamp},
+	{"2006-1-2T15:04:05", PrecisionTimestamp},
+	{"2006-1-2T15:04Z07:00", PrecisionTimestamp},
+	{"2006-1-2T15:04", PrecisionTimestamp},
+	{"2006-1-2 15:04:05Z07:00", PrecisionTimestamp},
+	{"2006-1-2 15:04:05Z0700", PrecisionTimestamp},
+	{"2006-1-2 15:04:05", PrecisionTimestamp},
+	{"2006-1-2", PrecisionDay},
+	{"2006/1/2", PrecisionDay},
+	{"2006.1.2", PrecisionDay},
+	{"20060102", PrecisionDay},
+	{"2 January 2006", PrecisionDay},
+	{"2 Jan 2006", PrecisionDay},
+	{"January 2, 2006", PrecisionDay},
+	{"Jan 2, 2006", PrecisionDay},
+	{"January 2 2006", PrecisionDay},
+	{"2006-1", PrecisionMonth},
+	{"2006/1", PrecisionMonth},
+	{"January 2006", PrecisionMonth},
+	{"Jan 2006", PrecisionMonth},
+	{"2006", PrecisionYear},
+}
+
+var (
+	// approximate dates like "c. 1850" or "[1850?]"
+	dateApproximate = regexp.MustCompile(`^(?i)(c\.|ca\.?|circa)\s*`)
+	dateLeadingYear = regexp.MustCompile(`^(\d{4})(\D|$)`)
+)
+
+// ParseDate parses a date of the metadata
+//
+// Besides the W3CDTF dates of the specs it reads the common malformed ones,
+// like "2011-05-20 14:18:57.392000+00:00", "2011/05/20", "20 May 2011" or
+// "c. 1850". The undefined date of calibre, the year 101, is an error.
+func ParseDate(value string) (Date, error) {
+	d := Date{Raw: value}
+	s := strings.TrimSpace(value)
+	s = dateApproximate.ReplaceAllString(s, "")
+	s = strings.Trim(s, "[]?")
+	if strings.HasSuffix(s, "z") {
+		s = s[:len(s)-1] + "Z"
+	}
+	if s == "" {
+		return d, errors.New("Empty date")
+	}
+
+	for _, l := range dateLayouts {
+		t, err := time.Parse(l.layout, s)
+		if err != nil {
+			continue
+		}
+		d.Time, d.Precision = t, l.precision
+		break
+	}
+	if d.Precision == 0 {
+		// a year followed by something we can't read
+		match := dateLeadingYear.FindStringSubmatch(s)
+		if match == nil {
+			return d, errors.New("Unknown date format " + value)
+		}
+		year, _ := strconv.Atoi(match[1])
+		d.Time, d.Precision = time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC), PrecisionYear
+	}
+	if d.Time.Year() <= 101 {
+		return d, errors.New("Undefined date " + value)
+	}
+	return d, nil
+}
+
+// aliases of the opf:event values
+var dateEvents = map[string]string{
+	"published":    "publication",
+	"issued":       "publication",
+	"created":      "creation",
+	"modified":     "modification",
+	"modify":       "modification",
+	"last-updated": "modification",
+}
+
+// date metas and their event
+var dateMetas = map[string]string{
+	"dcterms:issued":   "publication",
+	"dcterms:date":     "publication",
+	"dcterms:created":  "creation",
+	"dcterms:modified": "modification",
+}
+
+func normalizeDateEvent(event string) string {
+	event = strings.ToLower(strings.TrimSpace(event))
+	if alias, ok := dateEvents[event]; ok {
+		return alias
+	}
+	return event
+}
+
+// Dates returns the dates of the metadata that can be parsed, the dc:date
+// ones first and then the dcterms metas
+//
+// The event of the dc:date is its opf:event, or the event refinement of
+// EPUB 3. On EPUB 3 the dc:date without event is the publication date.
+func (e Epub) Dates() []Date {
+	dates := []Date{}
+	for _, dcDate := range e.opf.Metadata.Date {
+		d, err := ParseDate(dcDate.Data)
+		if err != nil {
+			continue
+		}
+		d.Event = normalizeDateEvent(dcDate.Event)
+		if d.Event == "" && dcDate.ID != "" {
+			for _, m := range e.opf.Metadata.Meta {
+				if m.Refines == "#"+dcDate.ID && m.Property == "event" {
+					d.Event = normalizeDateEvent(m.Data)
+				}
+			}
+		}
+		if d.Event == "" && strings.HasPrefix(e.opf.Version, "3") {
+			d.Event = "publication"
+		}
+		dates = append(dates, d)
+	}
+	for _, m := range e.opf.Metadata.Meta {
+		name, value := m.Name, m.Content
+		if m.Property != "" {
+			name, value = m.Property, m.Data
+		}
+		event, ok := dateMetas[name]
+		if !ok || m.Refines != "" {
+			continue
+		}
+		d, err := ParseDate(value)
+		if err != nil {
+			continue
+		}
+		d.Event = event
+		dates = append(dates, d)
+	}
+	return dates
+}
+
+// PublicationDate returns the publication date of the book, or the first
+// dc:date without event if there is none
+func (e Epub) PublicationDate() (Date, error) {
+	dates := e.Dates()
+	for _, d := range dates {
+		if d.Event == "publication" {
+			return d, nil
+		}
+	}
+	for _, d := range dates {
+		if d.Event == "" {
+			return d, nil
+		}
+	}
+	return Date{}, errors.New("The book has no publication date")
+}
+
+// ModificationDate returns the last modification date of the book, the
+// dcterms:modified of EPUB 3
+func (e Epub) ModificationDate() (Date, error) {
+	dates := e.Dates()
+	found := false
+	var last Date
+	for _, d := range dates {
+		if d.Event == "modification" && (!found || d.Time.After(last.Time)) {
+			last, found = d, true
+		}
+	}
+	if !found {
+		return Date{}, errors.New("The book has no modification date")
+	}
+	return last, nil
+}
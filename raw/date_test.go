@@ -0,0 +1,125 @@
+package raw
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	for _, test := range []struct {
+		value     string
+		precision DatePrecision
+		str       string
+	}{
+		{"1978-11", PrecisionMonth, "1978-11"},
+		{"2004-06-01", PrecisionDay, "2004-06-01"},
+		{"2004", PrecisionYear, "2004"},
+		{"2011-04-30T16:00:00+00:00", PrecisionTimestamp, "2011-04-30T16:00:00Z"},
+		{"2012-12-10T18:34:32.357240+00:00", PrecisionTimestamp, "2012-12-10T18:34:32Z"},
+		{"2011-05-20 14:18:57.392000+00:00", PrecisionTimestamp, "2011-05-20T14:18:57Z"},
+		{"2016-01-01T10:00:00z", PrecisionTimestamp, "2016-01-01T10:00:00Z"},
+		{"2016-01-01T10:00:00", PrecisionTimestamp, "2016-01-01T10:00:00Z"},
+		{"2011-5-3", PrecisionDay, "2011-05-03"},
+		{"2011/05/20", PrecisionDay, "2011-05-20"},
+		{"20110520", PrecisionDay, "2011-05-20"},
+		{"20 May 2011", PrecisionDay, "2011-05-20"},
+		{"May 20, 2011", PrecisionDay, "2011-05-20"},
+		{"may 2011", PrecisionMonth, "2011-05"},
+		{" c. 1850 ", PrecisionYear, "1850"},
+		{"[1850?]", PrecisionYear, "1850"},
+		{"1850 (first edition)", PrecisionYear, "1850"},
+	} {
+		d, err := ParseDate(test.value)
+		if err != nil {
+			t.Errorf("ParseDate(%q) return an error: %v", test.value, err)
+			continue
+		}
+		if d.Precision != test.precision || d.String() != test.str || d.Raw != test.value {
+			t.Errorf("ParseDate(%q) = %v %v (%q)", test.value, d.Precision, d, d.Raw)
+		}
+	}
+
+	for _, wrong := range []string{"", "unknown", "0101-01-01T00:00:00+00:00"} {
+		if _, err := ParseDate(wrong); err == nil {
+			t.Errorf("ParseDate(%q) didn't fail", wrong)
+		}
+	}
+}
+
+func TestDates(t *testing.T) {
+	e := newTestEpub(t, map[string]string{"content.opf": `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="2.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:opf="http://www.idpf.org/2007/opf">
+    <dc:date opf:event="conversion">2012-12-10T18:34:32.357240+00:00</dc:date>
+    <dc:date opf:event="Publication">2004-06-01</dc:date>
+    <dc:date>not a date</dc:date>
+    <meta name="dcterms:modified" content="2013-01-01"/>
+  </metadata>
+</package>`})
+	dates := e.Dates()
+	if len(dates) != 3 || dates[0].Event != "conversion" || dates[1].Event != "publication" || dates[2].Event != "modification" {
+		t.Fatalf("The dates are %+v", dates)
+	}
+	if d, err := e.PublicationDate(); err != nil || d.Year() != 2004 || d.Precision != PrecisionDay {
+		t.Errorf("PublicationDate() = %v, %v", d, err)
+	}
+	if d, err := e.ModificationDate(); err != nil || d.String() != "2013-01-01" {
+		t.Errorf("ModificationDate() = %v, %v", d, err)
+	}
+}
+
+func TestDatesEPUB3(t *testing.T) {
+	e := newTestEpub(t, map[string]string{"content.opf": `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:date id="created">2000-01-01</dc:date>
+    <dc:date>2001</dc:date>
+    <meta property="event" refines="#created">creation</meta>
+    <meta property="dcterms:modified">2016-02-29T12:34:56Z</meta>
+    <meta property="dcterms:modified">2015-02-28T12:34:56Z</meta>
+  </metadata>
+</package>`})
+	if d, err := e.PublicationDate(); err != nil || d.String() != "2001" {
+		t.Errorf("PublicationDate() = %v, %v", d, err)
+	}
+	d, err := e.ModificationDate()
+	if err != nil || !d.Time.Equal(time.Date(2016, 2, 29, 12, 34, 56, 0, time.UTC)) || d.Precision != PrecisionTimestamp {
+		t.Errorf("ModificationDate() = %v, %v", d, err)
+	}
+	if dates := e.Dates(); dates[0].Event != "creation" {
+		t.Errorf("The event of the refined date is %q", dates[0].Event)
+	}
+}
+
+func TestPublicationDateBook(t *testing.T) {
+	f, err := NewEpub("../testdata/gcdxy.epub")
+	if err != nil {
+		t.Fatalf("Can't open gcdxy: %v", err)
+	}
+	defer f.Close()
+	d, err := f.PublicationDate()
+	if err != nil || d.String() != "1978-11" || d.Precision != PrecisionMonth || d.Year() != 1978 {
+		t.Errorf("PublicationDate() = %v, %v", d, err)
+	}
+	if _, err := f.ModificationDate(); err == nil {
+		t.Errorf("ModificationDate() didn't fail")
+	}
+}
+
+func TestDatesBook(t *testing.T) {
+	f, err := NewEpub(bookPath)
+	if err != nil {
+		t.Fatalf("Can't open the book: %v", err)
+	}
+	defer f.Close()
+	dates := f.Dates()
+	if len(dates) != 2 || dates[0].Event != "publication" || dates[1].Event != "conversion" {
+		t.Fatalf("The dates are %+v", dates)
+	}
+	if dates[1].String() != "2012-12-10T18:34:32Z" || dates[1].Precision != PrecisionTimestamp {
+		t.Errorf("The conversion date is %v", dates[1])
+	}
+	if d, err := f.PublicationDate(); err != nil || d.String() != "2004-06-01" || d.Precision != PrecisionDay {
+		t.Errorf("PublicationDate() = %v, %v", d, err)
+	}
+}
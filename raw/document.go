@@ -0,0 +1,147 @@
+package raw
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+
+	"github.com/ssor/epubgo/internal/docutil"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+// well known namespaces of content documents, mapped to the prefix used
+// for the attributes on the parsed tree
+var namespacePrefixes = map[string]string{
+	"http://www.w3.org/XML/1998/namespace": "xml",
+	"http://www.idpf.org/2007/ops":         "epub",
+	"http://www.w3.org/1999/xlink":         "xlink",
+	"http://www.w3.org/2000/xmlns/":        "xmlns",
+}
+
+// ParseDocument parses the content document href into an html node tree
+//
+// The href is relative to the opf file, like the ones on the manifest.
+func (e Epub) ParseDocument(href string) (*html.Node, error) {
+	f, err := e.OpenFile(href)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseDocument(f)
+}
+
+// parseDocument parses an XHTML document
+//
+// Well formed documents are decoded as XML, so self closing elements like
+// <div id="page13"/> stay empty. Anything else goes through the HTML parser.
+func parseDocument(r io.Reader) (*html.Node, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := parseXMLDocument(data)
+	if err == nil {
+		return doc, nil
+	}
+	return html.Parse(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+}
+
+func parseXMLDocument(data []byte) (*html.Node, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Entity = xml.HTMLEntity
+	decoder.CharsetReader = charset.NewReaderLabel
+
+	prefixes := make(map[string]string)
+	for k, v := range namespacePrefixes {
+		prefixes[k] = v
+	}
+
+	doc := &html.Node{Type: html.DocumentNode}
+	curr := doc
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			for _, a := range t.Attr {
+				if a.Name.Space == "xmlns" {
+					prefixes[a.Value] = a.Name.Local
+				}
+			}
+			node := &html.Node{
+				Type:      html.ElementNode,
+				Data:      t.Name.Local,
+				Namespace: elementNamespace(t.Name.Space),
+			}
+			if node.Namespace == "" {
+				node.DataAtom = atom.Lookup([]byte(t.Name.Local))
+			}
+			for _, a := range t.Attr {
+				key := a.Name.Local
+				switch {
+				case a.Name.Space == "" && key == "xmlns":
+				case a.Name.Space != "":
+					prefix, ok := prefixes[a.Name.Space]
+					if !ok {
+						prefix = a.Name.Space
+					}
+					key = prefix + ":" + key
+				}
+				node.Attr = append(node.Attr, html.Attribute{Key: key, Val: a.Value})
+			}
+			curr.AppendChild(node)
+			curr = node
+		case xml.EndElement:
+			if curr.Parent != nil {
+				curr = curr.Parent
+			}
+		case xml.CharData:
+			if curr == doc {
+				continue
+			}
+			curr.AppendChild(&html.Node{Type: html.TextNode, Data: string(t)})
+		case xml.Comment:
+			curr.AppendChild(&html.Node{Type: html.CommentNode, Data: string(t)})
+		}
+	}
+
+	if findElement(doc, "html") == nil {
+		return nil, errors.New("The document has no html element")
+	}
+	return doc, nil
+}
+
+func elementNamespace(space string) string {
+	switch space {
+	case "http://www.w3.org/2000/svg":
+		return "svg"
+	case "http://www.w3.org/1998/Math/MathML":
+		return "math"
+	}
+	return ""
+}
+
+// findElement returns the first element named tag on the tree of n
+func findElement(n *html.Node, tag string) *html.Node {
+	return docutil.FindElement(n, tag)
+}
+
+// attr returns the value of the attribute key of the node, or "" if it's missing
+func attr(n *html.Node, key string) string {
+	return docutil.Attr(n, key)
+}
+
+// splitFragment splits an url on the path and the fragment after the '#'
+func splitFragment(url string) (string, string) {
+	return docutil.SplitFragment(url)
+}
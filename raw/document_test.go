@@ -0,0 +1,84 @@
+package raw
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseDocumentSelfClosing(t *testing.T) {
+	doc, err := parseDocument(strings.NewReader(`<?xml version="1.0"?>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<body><div id="page1" /><p epub:type="bodymatter">text</p></body></html>`))
+	if err != nil {
+		t.Fatalf("parseDocument() return an error: %v", err)
+	}
+	div := findElement(doc, "div")
+	if div == nil || div.FirstChild != nil {
+		t.Errorf("the self closing div should be empty")
+	}
+	p := findElement(doc, "p")
+	if attr(p, "epub:type") != "bodymatter" {
+		t.Errorf("epub:type attribute not found on %v", p.Attr)
+	}
+}
+
+func TestParseDocumentFallback(t *testing.T) {
+	doc, err := parseDocument(strings.NewReader(`<html><body><p>unclosed<br></body></html>`))
+	if err != nil {
+		t.Fatalf("parseDocument() return an error: %v", err)
+	}
+	if findElement(doc, "p") == nil {
+		t.Errorf("the p element is missing")
+	}
+}
+
+func TestDocumentText(t *testing.T) {
+	doc, _ := parseDocument(strings.NewReader(`<html xmlns="http://www.w3.org/1999/xhtml">
+<head><title>skip</title></head>
+<body><h1 id="t">The   title</h1>
+<p>First <em>para</em>graph</p></body></html>`))
+	text := newDocumentText(doc)
+	if text.Text != "The title\nFirst paragraph" {
+		t.Errorf("Text is %q", text.Text)
+	}
+	if len(text.Blocks) != 2 || text.Blocks[0].Tag != "h1" || text.Blocks[1].Tag != "p" {
+		t.Errorf("Blocks are %v", text.Blocks)
+	}
+	if offset, ok := text.AnchorOffset("t"); !ok || offset != 0 {
+		t.Errorf("AnchorOffset(t) return %v, %v", offset, ok)
+	}
+
+	loc := text.Location(strings.Index(text.Text, "graph"))
+	if loc.Path != "/4/4/3" || loc.Offset != 0 {
+		t.Errorf("Location() return %v:%v", loc.Path, loc.Offset)
+	}
+	loc = text.Location(strings.Index(text.Text, "title"))
+	if loc.Path != "/4/2[t]/1" || loc.Offset != 6 {
+		t.Errorf("Location() return %v:%v", loc.Path, loc.Offset)
+	}
+
+	loc = text.EndLocation(strings.Index(text.Text, "graph"))
+	if loc.Path != "/4/4/2/1" || loc.Offset != 4 {
+		t.Errorf("EndLocation() return %v:%v", loc.Path, loc.Offset)
+	}
+	loc = text.EndLocation(strings.Index(text.Text, "\n"))
+	if loc.Path != "/4/2[t]/1" || loc.Offset != 11 {
+		t.Errorf("EndLocation() return %v:%v", loc.Path, loc.Offset)
+	}
+}
+
+func TestDocumentTextEndSurrogate(t *testing.T) {
+	doc, _ := parseDocument(strings.NewReader(`<html xmlns="http://www.w3.org/1999/xhtml">
+<head><title>t</title></head>
+<body><p>smile 😀</p><p>é</p></body></html>`))
+	text := newDocumentText(doc)
+
+	loc := text.EndLocation(strings.Index(text.Text, "\n"))
+	if loc.Path != "/4/2/1" || loc.Offset != 8 {
+		t.Errorf("EndLocation() return %v:%v", loc.Path, loc.Offset)
+	}
+	loc = text.Location(len(text.Text))
+	if loc.Path != "/4/4/1" || loc.Offset != 1 {
+		t.Errorf("Location() at the end return %v:%v", loc.Path, loc.Offset)
+	}
+}
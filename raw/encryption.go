@@ -0,0 +1,122 @@
+package raw
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+	"unicode"
+)
+
+// font obfuscation algorithms
+const (
+	idpfObfuscation  = "http://www.idpf.org/2008/embedding"
+	adobeObfuscation = "http://ns.adobe.com/pdf/enc#RC"
+)
+
+type encryptionXML struct {
+	Data []encryptedData `xml:"EncryptedData"`
+}
+type encryptedData struct {
+	Method encryptionMethod `xml:"EncryptionMethod"`
+	Cipher cipherReference  `xml:"CipherData>CipherReference"`
+}
+type encryptionMethod struct {
+	Algorithm string `xml:"Algorithm,attr"`
+}
+type cipherReference struct {
+	URI string `xml:"URI,attr"`
+}
+
+// parseEncryption reads META-INF/encryption.xml if the epub has one
+//
+// Returns the algorithm used on each file by its path on the zip.
+func (e *Epub) parseEncryption() map[string]string {
+	encryption := make(map[string]string)
+	f, err := e.reader.OpenFile("META-INF/encryption.xml")
+	if err != nil {
+		return encryption
+	}
+	defer f.Close()
+
+	var enc encryptionXML
+	if err := decodeXML(f, &enc); err != nil {
+		return encryption
+	}
+	for _, data := range enc.Data {
+		uri := strings.TrimPrefix(data.Cipher.URI, "/")
+		encryption[cleanHref(uri)] = data.Method.Algorithm
+	}
+	return encryption
+}
+
+// OpenResource opens a file inside the epub like OpenFile, but removing the
+// font obfuscation declared for it on META-INF/encryption.xml
+//
+// Files encrypted with any other algorithm are returned as they are.
+func (e Epub) OpenResource(name string) (io.ReadCloser, error) {
+	f, err := e.OpenFile(name)
+	if err != nil {
+		return nil, err
+	}
+	algorithm := e.encryption[path.Join(e.rootPath, cleanHref(name))]
+	key := e.obfuscationKey(algorithm)
+	if key == nil {
+		return f, nil
+	}
+
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	length := 1040
+	if algorithm == adobeObfuscation {
+		length = 1024
+	}
+	deobfuscate(data, key, length)
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// IsObfuscated returns whether the file name is an obfuscated font
+func (e Epub) IsObfuscated(name string) bool {
+	algorithm := e.encryption[path.Join(e.rootPath, cleanHref(name))]
+	return algorithm == idpfObfuscation || algorithm == adobeObfuscation
+}
+
+// obfuscationKey returns the key of the font obfuscation algorithm, nil if
+// the algorithm is not a font obfuscation one
+func (e Epub) obfuscationKey(algorithm string) []byte {
+	uid := e.opf.uniqueIdentifier()
+	switch algorithm {
+	case idpfObfuscation:
+		var b strings.Builder
+		for _, r := range uid {
+			if !unicode.IsSpace(r) {
+				b.WriteRune(r)
+			}
+		}
+		key := sha1.Sum([]byte(b.String()))
+		return key[:]
+	case adobeObfuscation:
+		uid = strings.TrimPrefix(strings.TrimSpace(uid), "urn:uuid:")
+		key, err := hex.DecodeString(strings.Replace(uid, "-", "", -1))
+		if err != nil || len(key) != 16 {
+			return nil
+		}
+		return key
+	}
+	return nil
+}
+
+// deobfuscate xors the first length bytes of data with the key
+//
+// Obfuscating and deobfuscating are the same operation.
+func deobfuscate(data, key []byte, length int) {
+	for i := 0; i < length && i < len(data); i++ {
+		data[i] ^= key[i%len(key)]
+	}
+}
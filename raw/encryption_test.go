@@ -0,0 +1,85 @@
+package raw
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"testing"
+)
+
+// obfuscatedBook returns an epub with the font fonts/a.otf obfuscated with
+// the algorithm
+func obfuscatedBook(t *testing.T, uid, algorithm string, font []byte) *Epub {
+	return newTestEpub(t, map[string]string{
+		"OEBPS/content.opf": `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:identifier id="other">isbn</dc:identifier>
+<dc:identifier id="uid">` + uid + `</dc:identifier>
+<dc:title>Fonts</dc:title>
+</metadata>
+<manifest><item id="font" href="fonts/a.otf" media-type="application/vnd.ms-opentype"/></manifest>
+<spine/>
+</package>`,
+		"META-INF/encryption.xml": `<?xml version="1.0"?>
+<encryption xmlns="urn:oasis:names:tc:opendocument:xmlns:container" xmlns:enc="http://www.w3.org/2001/04/xmlenc#">
+<enc:EncryptedData>
+<enc:EncryptionMethod Algorithm="` + algorithm + `"/>
+<enc:CipherData><enc:CipherReference URI="OEBPS/fonts/a.otf"/></enc:CipherData>
+</enc:EncryptedData>
+</encryption>`,
+		"OEBPS/fonts/a.otf": string(font),
+	})
+}
+
+func TestOpenResourceObfuscated(t *testing.T) {
+	// an OpenType header followed by zeros, the obfuscated bytes past the
+	// header are the key itself
+	font := make([]byte, 1100)
+	copy(font, "OTTO\x00\x01\x00\x00")
+
+	tests := []struct {
+		algorithm string
+		uid       string
+		// the key of the known uid: the SHA-1 of "abc" of FIPS 180 and the
+		// bytes of the UUID
+		key    string
+		length int
+		// the first bytes of the font obfuscated
+		header string
+	}{
+		{idpfObfuscation, " a b\tc\n", "a9993e364706816aba3e25717850c26c9cd0d89d", 1040, "e6cd6a794707816a"},
+		{adobeObfuscation, "urn:uuid:00112233-4455-6677-8899-aabbccddeeff", "00112233445566778899aabbccddeeff", 1024, "4f45767c44546677"},
+	}
+	for _, test := range tests {
+		key, _ := hex.DecodeString(test.key)
+		obfuscated := append([]byte{}, font...)
+		for i := 0; i < test.length; i++ {
+			obfuscated[i] ^= key[i%len(key)]
+		}
+		if header := hex.EncodeToString(obfuscated[:8]); header != test.header {
+			t.Fatalf("The obfuscated header is %v, the expected was %v", header, test.header)
+		}
+
+		e := obfuscatedBook(t, test.uid, test.algorithm, obfuscated)
+		if !e.IsObfuscated("fonts/a.otf") {
+			t.Errorf("IsObfuscated() return false with %v", test.algorithm)
+		}
+		f, err := e.OpenResource("fonts/a.otf")
+		if err != nil {
+			t.Fatalf("OpenResource() return an error: %v", err)
+		}
+		data, _ := ioutil.ReadAll(f)
+		f.Close()
+		if !bytes.Equal(data, font) {
+			t.Errorf("OpenResource() didn't deobfuscate the font with %v: % x", test.algorithm, data[:8])
+		}
+
+		f, _ = e.OpenFile("fonts/a.otf")
+		data, _ = ioutil.ReadAll(f)
+		f.Close()
+		if !bytes.Equal(data, obfuscated) {
+			t.Errorf("OpenFile() didn't return the font as it is with %v", test.algorithm)
+		}
+	}
+}
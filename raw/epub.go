@@ -13,6 +13,30 @@ type Reader interface {
 	Close()
 }
 
+// FileLister is implemented by the readers that can list the files of the
+// epub, like the zip one
+type FileLister interface {
+	// FileNames returns the names of all the files of the epub
+	FileNames() []string
+}
+
+// fileNames returns the names of all the files of the epub, or only the
+// ones of the container, the package and the manifest if the reader can't
+// list them
+func (e Epub) fileNames() []string {
+	if lister, ok := e.reader.(FileLister); ok {
+		return lister.FileNames()
+	}
+	names := []string{"mimetype", "META-INF/container.xml"}
+	if opfPath, err := e.getOpfPath(); err == nil {
+		names = append(names, opfPath)
+	}
+	for _, item := range e.opf.Manifest {
+		names = append(names, path.Join(e.rootPath, cleanHref(item.Href)))
+	}
+	return names
+}
+
 func NewEpub(path string) (*Epub, error) {
 	epub_reader, err := reader.NewZipReader(path)
 	if err != nil {
@@ -39,7 +63,10 @@ type Epub struct {
 	metadata MetaDataList
 	opf      *xmlOPF
 	NCX      *XmlNCX
-	reader   Reader
+	ncxPath  string
+	// algorithm used to encrypt each file, by its path on the zip
+	encryption map[string]string
+	reader     Reader
 }
 
 type MetaDataList map[string][]MdataElement
@@ -89,6 +116,7 @@ func (e *Epub) parseFiles() error {
 	// }
 
 	e.metadata = e.opf.toMData()
+	e.encryption = e.parseEncryption()
 	ncxPath := e.opf.ncxPath()
 	if ncxPath != "" {
 		ncx, err := e.OpenFile(ncxPath)
@@ -100,6 +128,7 @@ func (e *Epub) parseFiles() error {
 		if err != nil {
 			return err
 		}
+		e.ncxPath = ncxPath
 		// e.NCX.NavMap.SetContentCount(func(file string) int {
 		// 	for _, ele := range e.opf.Manifest {
 		// 		if ele.Href == file {
@@ -200,11 +229,18 @@ func (e Epub) Spine() (*SpineIterator, error) {
 	return newSpineIterator(&e)
 }
 
+// PageProgressionDirection returns the page-progression-direction of the
+// spine, "ltr", "rtl" or "" if it's not set
+func (e Epub) PageProgressionDirection() string {
+	return e.opf.Spine.PageProgression
+}
+
 // Metadata returns the values of a metadata field
 //
 // The valid field names are:
 //    title, language, identifier, creator, subject, description, publisher,
-//    contributor, date, type, format, source, relation, coverage, rights, meta
+//    contributor, date, type, format, source, relation, coverage, rights, meta,
+//    link
 func (e Epub) Metadata(field string) ([]string, error) {
 	elem, ok := e.metadata[field]
 	if ok {
@@ -218,6 +254,17 @@ func (e Epub) Metadata(field string) ([]string, error) {
 	return nil, errors.New("Field " + field + " don't exists")
 }
 
+// Version returns the version of the package, like "2.0" or "3.0"
+func (e Epub) Version() string {
+	return e.opf.Version
+}
+
+// UniqueIdentifier returns the identifier referenced by the unique-identifier
+// attribute of the package
+func (e Epub) UniqueIdentifier() string {
+	return e.opf.uniqueIdentifier()
+}
+
 // MetadataFields retunrs the list of metadata fields pressent on the current epub
 func (e Epub) MetadataFields() []string {
 	fields := make([]string, len(e.metadata))
@@ -252,6 +299,12 @@ func (e *Epub) FileManifest(file string) *manifest {
 			return ele
 		}
 	}
+	file = cleanHref(file)
+	for _, ele := range e.opf.Manifest {
+		if cleanHref(ele.Href) == file {
+			return ele
+		}
+	}
 	return nil
 }
 
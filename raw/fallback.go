@@ -0,0 +1,59 @@
+package raw
+
+import "errors"
+
+// ResolveFallback follows the fallback chain of the manifest item id until
+// an item with a media type supported, returning its href
+//
+// An item with a fallback-style is resolved to itself if its style sheet
+// resolves to a supported one, it's an XML island rendered with css.
+// Returns an error if the chain has a cycle or ends without any supported
+// item.
+func (e Epub) ResolveFallback(id string, supported func(mediaType string) bool) (string, error) {
+	return e.resolveFallback(id, supported, make(map[string]bool))
+}
+
+func (e Epub) resolveFallback(id string, supported func(mediaType string) bool, visited map[string]bool) (string, error) {
+	if visited[id] {
+		return "", errors.New("The fallback chain has a cycle on " + id)
+	}
+	visited[id] = true
+
+	item := e.manifestItem(id)
+	if item == nil {
+		return "", errors.New("Fallback " + id + " not in the manifest")
+	}
+	if supported(item.MediaType) {
+		return item.Href, nil
+	}
+	if item.FallbackStyle != "" {
+		// the probe has its own copy, what it visits is not part of the chain
+		probe := make(map[string]bool, len(visited))
+		for k := range visited {
+			probe[k] = true
+		}
+		if _, err := e.resolveFallback(item.FallbackStyle, supported, probe); err == nil {
+			return item.Href, nil
+		}
+	}
+
+	next := item.ItemFallback
+	if next == "" {
+		next = item.Fallback
+	}
+	if next == "" {
+		return "", errors.New("No supported fallback for " + item.Href + " (" + item.MediaType + ")")
+	}
+	return e.resolveFallback(next, supported, visited)
+}
+
+// manifestItem returns the item of the manifest with the id, nil if there
+// is none
+func (e Epub) manifestItem(id string) *manifest {
+	for _, item := range e.opf.Manifest {
+		if item.ID == id {
+			return item
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package raw
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func fallbackBook(t *testing.T) *Epub {
+	const opf = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="2.0">
+  <manifest>
+    <item id="dtbook" href="book.xml" media-type="application/x-dtbook+xml" fallback="pdf"/>
+    <item id="pdf" href="book.pdf" media-type="application/pdf" fallback="xhtml"/>
+    <item id="xhtml" href="book.xhtml" media-type="application/xhtml+xml"/>
+    <item id="a" href="a.xml" media-type="application/xml" fallback="b"/>
+    <item id="b" href="b.xml" media-type="application/xml" fallback="a"/>
+    <item id="island" href="island.xml" media-type="application/xml" fallback-style="css"/>
+    <item id="css" href="island.css" media-type="text/css"/>
+    <item id="island2" href="island2.xml" media-type="application/xml" fallback-style="tiff" fallback="tiff"/>
+    <item id="tiff" href="other.tiff" media-type="image/tiff"/>
+    <item id="old" href="old.tiff" media-type="image/tiff" media-fallback="png"/>
+    <item id="png" href="old.png" media-type="image/png"/>
+  </manifest>
+  <spine>
+    <itemref idref="dtbook"/>
+    <itemref idref="xhtml"/>
+  </spine>
+</package>`
+	return newTestEpub(t, map[string]string{
+		"content.opf": opf,
+		"book.xml":    "<dtbook/>",
+		"book.xhtml":  "<html/>",
+	})
+}
+
+func TestResolveFallback(t *testing.T) {
+	e := fallbackBook(t)
+	if item := e.manifestItem("old"); item.Fallback != "png" || item.ItemFallback != "" {
+		t.Errorf("The fallbacks of the media-fallback item are %q, %q", item.Fallback, item.ItemFallback)
+	}
+	if item := e.manifestItem("pdf"); item.ItemFallback != "xhtml" || item.Fallback != "" {
+		t.Errorf("The fallbacks of the fallback item are %q, %q", item.ItemFallback, item.Fallback)
+	}
+	tests := []struct {
+		id   string
+		href string
+	}{
+		{"dtbook", "book.xhtml"},
+		{"xhtml", "book.xhtml"},
+		{"island", "island.xml"},
+		{"old", "old.png"},
+	}
+	for _, test := range tests {
+		href, err := e.ResolveFallback(test.id, IsCoreMediaType)
+		if err != nil || href != test.href {
+			t.Errorf("ResolveFallback(%q) is %q, %v; the expected was %q", test.id, href, err, test.href)
+		}
+	}
+
+	pdf := func(mediaType string) bool { return mediaType == "application/pdf" }
+	if href, _ := e.ResolveFallback("dtbook", pdf); href != "book.pdf" {
+		t.Errorf("ResolveFallback() with pdf support is %q", href)
+	}
+	if _, err := e.ResolveFallback("a", IsCoreMediaType); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("ResolveFallback() didn't find the cycle: %v", err)
+	}
+	if _, err := e.ResolveFallback("island2", IsCoreMediaType); err == nil || strings.Contains(err.Error(), "cycle") {
+		t.Errorf("ResolveFallback() with a failed fallback-style is %v", err)
+	}
+	if _, err := e.ResolveFallback("missing", IsCoreMediaType); err == nil {
+		t.Errorf("ResolveFallback() didn't fail with a missing id")
+	}
+	if _, err := e.ResolveFallback("css", func(string) bool { return false }); err == nil {
+		t.Errorf("ResolveFallback() didn't fail without supported items")
+	}
+}
+
+func TestSpineOpenFallback(t *testing.T) {
+	e := fallbackBook(t)
+	it, err := e.Spine()
+	if err != nil {
+		t.Fatalf("Spine() return an error: %v", err)
+	}
+
+	f, _ := it.Open()
+	data, _ := ioutil.ReadAll(f)
+	f.Close()
+	if string(data) != "<dtbook/>" {
+		t.Errorf("Open() without fallbacks read %q", data)
+	}
+
+	it.UseFallbacks(IsCoreMediaType)
+	f, err = it.Open()
+	if err != nil {
+		t.Fatalf("Open() with fallbacks return an error: %v", err)
+	}
+	data, _ = ioutil.ReadAll(f)
+	f.Close()
+	if string(data) != "<html/>" {
+		t.Errorf("Open() with fallbacks read %q", data)
+	}
+	if it.URL() != "book.xml" {
+		t.Errorf("The URL of the iterator changed to %q", it.URL())
+	}
+}
@@ -7,6 +7,7 @@ package raw
 import (
 	"encoding/xml"
 
+	"github.com/ssor/epubgo/internal/docutil"
 	"golang.org/x/net/html/charset"
 	// "github.com/golang/net/html/charset"
 	"io"
@@ -27,8 +28,23 @@ func decodeXML(file io.Reader, v interface{}) error {
 	return decoder.Decode(v)
 }
 
+// isTextContent returns whether the media type is of a content document,
+// the html ones are read as well, they are a common mistake
 func isTextContent(mediaType string) bool {
-	return mediaType == "application/xhtml+xml"
+	return docutil.IsTextContent(mediaType)
+}
+
+// resolveHref resolves the relative reference ref found on the file base
+//
+// Both base and the result are relative to the opf file, like the hrefs of
+// the manifest. ref must not have a fragment.
+func resolveHref(base, ref string) string {
+	return docutil.ResolveHref(base, ref)
+}
+
+// cleanHref normalizes an href of the manifest to compare it with resolved ones
+func cleanHref(href string) string {
+	return docutil.CleanHref(href)
 }
 
 // func openFile(file *zip.Reader, path string) (io.ReadCloser, error) {
@@ -0,0 +1,330 @@
+package raw
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Footnote is a reference to a note and the note
+type Footnote struct {
+	// RefHref is the document of the reference, relative to the opf, and
+	// RefSpine its position on the spine
+	RefHref  string
+	RefSpine int
+	// RefID is the id of the reference, "" if it has none
+	RefID string
+	// Label is the text of the reference, like "1" or "[1]"
+	Label string
+	// NoteHref is the document of the note, relative to the opf
+	NoteHref string
+	NoteID   string
+	// Kind is "footnote", "endnote" or "rearnote", "footnote" if the note
+	// doesn't say it
+	Kind string
+	// Text is the content of the note without the links back to the
+	// reference, and HTML the same content sanitized
+	Text string
+	HTML string
+	// Heuristic is set for the references found without the noteref
+	// semantics, like on most EPUB 2 books
+	Heuristic bool
+}
+
+var (
+	// labels of the note references, like "1", "[12]", "*" or "(a)"
+	noteLabel = regexp.MustCompile(`^[\[(]?(\d{1,4}|[*†‡§]{1,3}|[a-z]|[ivxlc]{1,5})[\])]?$`)
+	// names of the notes files
+	notesFile = regexp.MustCompile(`(?i)(foot|end|rear)?notes?|annotations?|注释|注`)
+)
+
+// the elements the notes are made of, when the reference points to an
+// anchor inside them
+var noteBlocks = map[string]bool{
+	"aside":      true,
+	"blockquote": true,
+	"dd":         true,
+	"div":        true,
+	"li":         true,
+	"p":          true,
+	"section":    true,
+	"td":         true,
+}
+
+// Footnotes returns the note references of the documents of the spine with
+// their notes, in reading order
+//
+// The references are the noteref links, and the notes their footnote,
+// endnote or rearnote targets on any document. Without the epub:type
+// semantics, the short links like "[1]" or the superscript ones are taken
+// as references if the note links back to them, if it's on a notes file or
+// if it's later on the same document. The documents that can't be parsed
+// are skipped.
+func (e Epub) Footnotes() ([]Footnote, error) {
+	f := footnoteFinder{e: e, docs: map[string]*html.Node{}, notes: []Footnote{}}
+	for i := 0; i < e.opf.spineLength(); i++ {
+		item := e.opf.spineItem(i)
+		if item == nil || !isTextContent(item.MediaType) {
+			continue
+		}
+		// the documents that can't be parsed have no references to find
+		if doc, err := f.document(item.Href); err == nil && doc != nil {
+			f.findReferences(i, item.Href, doc)
+		}
+	}
+	return f.notes, nil
+}
+
+type footnoteFinder struct {
+	e Epub
+	// parsed documents by their clean href, nil if they can't be parsed
+	docs  map[string]*html.Node
+	notes []Footnote
+}
+
+func (f *footnoteFinder) document(href string) (*html.Node, error) {
+	key := cleanHref(href)
+	if doc, ok := f.docs[key]; ok {
+		return doc, nil
+	}
+	doc, err := f.e.ParseDocument(href)
+	if err != nil {
+		f.docs[key] = nil
+		return nil, err
+	}
+	f.docs[key] = doc
+	return doc, nil
+}
+
+func (f *footnoteFinder) findReferences(spineIndex int, href string, doc *html.Node) {
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "a" {
+			if note, ok := f.reference(href, doc, n); ok {
+				note.RefSpine = spineIndex
+				f.notes = append(f.notes, note)
+			}
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+}
+
+// reference returns the note of the link a, found on the document href, if
+// it's a note reference
+func (f *footnoteFinder) reference(href string, doc, a *html.Node) (Footnote, bool) {
+	file, fragment := splitFragment(strings.TrimSpace(attr(a, "href")))
+	if fragment == "" || urlScheme.MatchString(file) {
+		return Footnote{}, false
+	}
+	noteHref := href
+	if file != "" {
+		noteHref = resolveHref(href, file)
+	}
+	if f.e.FileManifest(noteHref) == nil {
+		return Footnote{}, false
+	}
+	noteDoc, err := f.document(noteHref)
+	if err != nil || noteDoc == nil {
+		return Footnote{}, false
+	}
+	target := elementByID(noteDoc, fragment)
+	if target == nil {
+		return Footnote{}, false
+	}
+
+	note := Footnote{
+		RefHref:  href,
+		RefID:    attr(a, "id"),
+		Label:    nodeText(a),
+		NoteHref: noteHref,
+		NoteID:   fragment,
+	}
+	content := noteContent(target)
+	note.Kind = noteKind(content)
+	if !hasType(a, "noteref", "doc-noteref") {
+		superscript := findElement(a, "sup") != nil || (a.Parent != nil && a.Parent.Data == "sup")
+		if !superscript && !noteLabel.MatchString(strings.ToLower(note.Label)) {
+			return Footnote{}, false
+		}
+		if note.Kind == "" && !looksLikeReference(note, doc, a, content) {
+			return Footnote{}, false
+		}
+		note.Heuristic = true
+	}
+	if note.Kind == "" {
+		note.Kind = "footnote"
+	}
+
+	clone := cloneNode(content)
+	removeBacklinks(clone, note)
+	SanitizeDocument(clone, nil)
+	note.Text = nodeText(clone)
+	var b bytes.Buffer
+	for c := clone.FirstChild; c != nil; c = c.NextSibling {
+		html.Render(&b, c)
+	}
+	note.HTML = strings.TrimSpace(b.String())
+	return note, true
+}
+
+// looksLikeReference returns whether the link a, that looks like a note
+// reference, points to a note without epub:type
+func looksLikeReference(note Footnote, doc, a, content *html.Node) bool {
+	if len([]rune(nodeText(content))) <= len([]rune(note.Label)) {
+		// the target has nothing but a label
+		return false
+	}
+	if block := noteContent(a); block != a && strings.HasPrefix(nodeText(block), note.Label) {
+		// it's the label of a note linking back to its reference
+		return false
+	}
+
+	// the note links back to the reference
+	backlink := false
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "a" && isBacklink(n, note) {
+			backlink = true
+		}
+		for c := n.FirstChild; c != nil && !backlink; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(content)
+	switch {
+	case backlink:
+		return true
+	case cleanHref(note.NoteHref) != cleanHref(note.RefHref):
+		return notesFile.MatchString(note.NoteHref)
+	}
+	return isBefore(doc, a, content)
+}
+
+// noteContent returns the element with the content of the note whose id is
+// on target, the block around it if target is only the label
+func noteContent(target *html.Node) *html.Node {
+	if noteKind(target) != "" {
+		return target
+	}
+	switch target.Data {
+	case "a", "span", "sup", "sub", "b", "strong", "em", "i", "small":
+	default:
+		return target
+	}
+	for n := target.Parent; n != nil && n.Type == html.ElementNode; n = n.Parent {
+		if noteBlocks[n.Data] {
+			return n
+		}
+	}
+	return target
+}
+
+// noteKind returns the kind of note of the element from its epub:type or
+// role, "" if it's not a note
+func noteKind(n *html.Node) string {
+	for _, kind := range []string{"footnote", "endnote", "rearnote"} {
+		if hasType(n, kind, "doc-"+kind) {
+			return kind
+		}
+	}
+	if hasType(n, "note", "note") {
+		return "footnote"
+	}
+	return ""
+}
+
+// hasType returns whether the element has the epub:type or the role
+func hasType(n *html.Node, epubType, role string) bool {
+	for _, t := range strings.Fields(attr(n, "epub:type")) {
+		if t == epubType {
+			return true
+		}
+	}
+	for _, r := range strings.Fields(attr(n, "role")) {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// isBacklink returns whether the link a of the note goes back to its
+// reference
+func isBacklink(a *html.Node, note Footnote) bool {
+	if hasType(a, "backlink", "doc-backlink") {
+		return true
+	}
+	file, fragment := splitFragment(strings.TrimSpace(attr(a, "href")))
+	if note.RefID == "" || fragment != note.RefID {
+		return false
+	}
+	return file == "" && cleanHref(note.NoteHref) == cleanHref(note.RefHref) ||
+		file != "" && cleanHref(resolveHref(note.NoteHref, file)) == cleanHref(note.RefHref)
+}
+
+// removeBacklinks removes the links back to the reference from the note,
+// with the label of the note if it's the content of the link
+func removeBacklinks(n *html.Node, note Footnote) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.ElementNode && c.Data == "a" && (isBacklink(c, note) || attr(c, "id") == note.NoteID) &&
+			len([]rune(nodeText(c))) <= 8 {
+			n.RemoveChild(c)
+		} else {
+			removeBacklinks(c, note)
+		}
+		c = next
+	}
+}
+
+// isBefore returns whether a comes before b on the document, false if a is
+// inside b
+func isBefore(doc, a, b *html.Node) bool {
+	var first *html.Node
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n == a || n == b {
+			first = n
+			return
+		}
+		for c := n.FirstChild; c != nil && first == nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return first == a
+}
+
+// elementByID returns the element with the id on the tree of n
+func elementByID(n *html.Node, id string) *html.Node {
+	if n.Type == html.ElementNode && attr(n, "id") == id {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := elementByID(c, id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// cloneNode returns a deep copy of n without parent nor siblings
+func cloneNode(n *html.Node) *html.Node {
+	clone := &html.Node{
+		Type:      n.Type,
+		DataAtom:  n.DataAtom,
+		Data:      n.Data,
+		Namespace: n.Namespace,
+		Attr:      append([]html.Attribute{}, n.Attr...),
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		clone.AppendChild(cloneNode(c))
+	}
+	return clone
+}
@@ -0,0 +1,159 @@
+package raw
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFootnotesSemantic(t *testing.T) {
+	const chapter = `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops"><body>
+<p>A footnote<a epub:type="noteref" id="r1" href="#n1">1</a> and an endnote<a epub:type="noteref" id="r2" href="notes.xhtml#n2">2</a>.</p>
+<p>A link to <a href="#n1">the aside</a>.</p>
+<aside epub:type="footnote" id="n1"><p>The <em>footnote</em> <script>alert(1)</script></p></aside>
+</body></html>`
+	const notes = `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops"><body>
+<section epub:type="endnotes"><ol>
+<li epub:type="endnote" id="n2"><p><a epub:type="backlink" href="chapter.xhtml#r2">2.</a> The endnote.</p></li>
+</ol></section>
+</body></html>`
+
+	const opf = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+<metadata/>
+<manifest>
+<item id="c" href="text/chapter.xhtml" media-type="application/xhtml+xml"/>
+<item id="n" href="text/notes.xhtml" media-type="application/xhtml+xml"/>
+<item id="m" href="text/missing.xhtml" media-type="application/xhtml+xml"/>
+</manifest>
+<spine><itemref idref="c"/><itemref idref="n"/><itemref idref="m"/></spine>
+</package>`
+
+	e := newTestEpub(t, map[string]string{
+		"content.opf":        opf,
+		"text/chapter.xhtml": chapter,
+		"text/notes.xhtml":   notes,
+	})
+	footnotes, err := e.Footnotes()
+	if err != nil {
+		t.Fatalf("Footnotes() return an error: %v", err)
+	}
+	if len(footnotes) != 2 {
+		t.Fatalf("The footnotes are %+v", footnotes)
+	}
+
+	n := footnotes[0]
+	if n.RefHref != "text/chapter.xhtml" || n.RefID != "r1" || n.Label != "1" || n.NoteID != "n1" ||
+		n.Kind != "footnote" || n.Heuristic {
+		t.Errorf("The footnote is %+v", n)
+	}
+	if n.Text != "The footnote" || n.HTML != "<p>The <em>footnote</em> </p>" {
+		t.Errorf("The content of the footnote is %q %q", n.Text, n.HTML)
+	}
+
+	n = footnotes[1]
+	if n.NoteHref != "text/notes.xhtml" || n.NoteID != "n2" || n.Kind != "endnote" || n.RefSpine != 0 {
+		t.Errorf("The endnote is %+v", n)
+	}
+	if n.Text != "The endnote." || strings.Contains(n.HTML, "backlink") {
+		t.Errorf("The content of the endnote is %q %q", n.Text, n.HTML)
+	}
+}
+
+func TestFootnotesHeuristic(t *testing.T) {
+	const chapter = `<html><body>
+<p>Superscript<sup><a id="ref1" href="notes.html#note1">*</a></sup> and <a href="#later">[2]</a>, not <a href="#later">a note</a>.</p>
+<p>Neither is <a href="other.html#x">[3]</a>.</p>
+<p id="later">Later on the chapter.</p>
+</body></html>`
+	const notes = `<html><body>
+<p><a id="note1" href="chapter.html#ref1">*</a> The first note.</p>
+</body></html>`
+	const other = `<html><body><p id="x">Not a note.</p></body></html>`
+
+	const opf = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="2.0">
+<metadata/>
+<manifest>
+<item id="c" href="chapter.html" media-type="application/xhtml+xml"/>
+<item id="n" href="notes.html" media-type="application/xhtml+xml"/>
+<item id="o" href="other.html" media-type="application/xhtml+xml"/>
+</manifest>
+<spine><itemref idref="c"/><itemref idref="n"/><itemref idref="o"/></spine>
+</package>`
+
+	e := newTestEpub(t, map[string]string{
+		"content.opf":  opf,
+		"chapter.html": chapter,
+		"notes.html":   notes,
+		"other.html":   other,
+	})
+	footnotes, err := e.Footnotes()
+	if err != nil {
+		t.Fatalf("Footnotes() return an error: %v", err)
+	}
+	if len(footnotes) != 2 {
+		t.Fatalf("The footnotes are %+v", footnotes)
+	}
+	if n := footnotes[0]; n.Label != "*" || n.NoteHref != "notes.html" || n.Text != "The first note." || !n.Heuristic {
+		t.Errorf("The first note is %+v", n)
+	}
+	if n := footnotes[1]; n.Label != "[2]" || n.NoteID != "later" || n.Text != "Later on the chapter." {
+		t.Errorf("The second note is %+v", n)
+	}
+}
+
+func TestFootnotesBook(t *testing.T) {
+	f, err := NewEpub("../testdata/gcdxy.epub")
+	if err != nil {
+		t.Fatalf("Can't open gcdxy: %v", err)
+	}
+	defer f.Close()
+	footnotes, err := f.Footnotes()
+	if err != nil {
+		t.Fatalf("Footnotes() return an error: %v", err)
+	}
+	// 38 notes of the editors and 44 of the authors
+	if len(footnotes) != 82 {
+		t.Fatalf("%d footnotes found", len(footnotes))
+	}
+	n := footnotes[2]
+	if n.RefHref != "chapter_00003.xhtml" || n.RefID != "wzyy_3_13" || n.Label != "[3]" || n.NoteID != "wz_3_13" || !n.Heuristic {
+		t.Errorf("The third footnote is %+v", n)
+	}
+	if text := "指1848年2月22日至24日在巴黎发生的法国资产阶级革命。——第3页"; n.Text != text || n.HTML != text {
+		t.Errorf("The content of the third footnote is %q %q", n.Text, n.HTML)
+	}
+	for _, n := range footnotes {
+		if !strings.HasPrefix(n.RefID, "wzyy_") && !strings.HasPrefix(n.RefID, "jzyy_") {
+			t.Errorf("The backlink %s was taken as a reference", n.RefID)
+		}
+	}
+}
+
+func TestFootnotesSanitized(t *testing.T) {
+	const chapter = `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops"><body>
+<p>Note<a epub:type="noteref" href="#n1">1</a></p>
+<aside epub:type="footnote" id="n1"><p>The note
+<svg xmlns="http://www.w3.org/2000/svg"><a><animate attributeName="href" values="javascript:alert(1)"/><text>x</text></a></svg></p></aside>
+</body></html>`
+
+	e := newTestEpub(t, map[string]string{
+		"content.opf": `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+<metadata/>
+<manifest><item id="c" href="chapter.xhtml" media-type="application/xhtml+xml"/></manifest>
+<spine><itemref idref="c"/></spine>
+</package>`,
+		"chapter.xhtml": chapter,
+	})
+	footnotes, err := e.Footnotes()
+	if err != nil || len(footnotes) != 1 {
+		t.Fatalf("Footnotes() = %+v, %v", footnotes, err)
+	}
+	if html := footnotes[0].HTML; strings.Contains(html, "javascript") || strings.Contains(html, "animate") {
+		t.Errorf("The note was not sanitized: %s", html)
+	}
+}
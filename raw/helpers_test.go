@@ -0,0 +1,86 @@
+package raw
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// memReader is an epub on memory, the files by their path on the zip
+type memReader map[string][]byte
+
+func (r memReader) OpenFile(name string) (io.ReadCloser, error) {
+	data, ok := r[name]
+	if !ok {
+		return nil, errors.New("File " + name + " not found")
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (r memReader) FileNames() []string {
+	names := []string{}
+	for name := range r {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (r memReader) Close() {}
+
+// newTestEpub writes the files, by their path on the zip, as an epub and
+// opens it with NewEpub
+//
+// The mimetype is added, and the container if it's missing, pointing to the
+// opf file of the files.
+func newTestEpub(t *testing.T, files map[string]string) *Epub {
+	t.Helper()
+	if _, ok := files["META-INF/container.xml"]; !ok {
+		opfPath := ""
+		for name := range files {
+			if strings.HasSuffix(name, ".opf") {
+				opfPath = name
+			}
+		}
+		files["META-INF/container.xml"] = `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<rootfiles><rootfile full-path="` + opfPath + `" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`
+	}
+
+	name := filepath.Join(t.TempDir(), "test.epub")
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	w, _ := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	io.WriteString(w, "application/epub+zip")
+	names := []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		w, _ := zw.Create(name)
+		io.WriteString(w, files[name])
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	e, err := NewEpub(name)
+	if err != nil {
+		t.Fatalf("NewEpub() return an error: %v", err)
+	}
+	t.Cleanup(e.Close)
+	return e
+}
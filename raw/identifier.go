@@ -0,0 +1,243 @@
+package raw
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Identifier is an identifier of the book classified and normalized
+type Identifier struct {
+	// ID is the id attribute of the dc:identifier
+	ID string
+	// Raw is the identifier as it's written and Scheme the declared one,
+	// from the opf:scheme attribute or the identifier-type refinement
+	Raw    string
+	Scheme string
+	// Type is "isbn", "uuid", "doi", "asin", "urn", "url",
+	// "chinese-book-number" or "" if it's not recognized
+	Type string
+	// Value is the identifier normalized: the ISBN-13 without hyphens for
+	// the ISBNs, the lowercase uuid, the DOI without prefix, ...
+	Value string
+	// Valid is set if the check digit of the ISBNs is right, or if the
+	// identifier has the format of its type
+	Valid bool
+	// Unique is set on the identifier referenced by the unique-identifier
+	// of the package
+	Unique bool
+}
+
+var (
+	uuidPattern    = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	doiPattern     = regexp.MustCompile(`^10\.\d{4,9}/\S+$`)
+	asinPattern    = regexp.MustCompile(`^B[0-9A-Z]{9}$`)
+	urnPattern     = regexp.MustCompile(`^(?i)urn:[a-z0-9][a-z0-9-]{0,31}:\S+$`)
+	chinesePattern = regexp.MustCompile(`^\d{4,5}[·・]\d+$`)
+	// the isbn prefixes and labels, like "urn:isbn:", "ISBN " or "ISBN-13: "
+	isbnPrefix = regexp.MustCompile(`^(?i)(urn:)?isbn(-1[03])?(:\s*|\s+)`)
+)
+
+// ONIX code list 5 values used on the identifier-type refinements
+var onixIdentifierTypes = map[string]string{
+	"02": "isbn",
+	"06": "doi",
+	"15": "isbn",
+	"22": "urn",
+}
+
+// ParseIdentifier classifies and normalizes the identifier value with the
+// declared scheme, "" if there is none
+//
+// Explicit prefixes, like urn:isbn: or doi:, and the format of the value
+// take precedence over the scheme, which only helps with the ambiguous
+// ones.
+func ParseIdentifier(value, scheme string) Identifier {
+	id := Identifier{Raw: value, Scheme: scheme, Value: strings.TrimSpace(value)}
+	v := id.Value
+	lower := strings.ToLower(v)
+	scheme = strings.ToLower(strings.TrimSpace(scheme))
+
+	switch {
+	case isbnPrefix.MatchString(v):
+		id.setISBN(v[len(isbnPrefix.FindString(v)):])
+	case strings.HasPrefix(lower, "urn:uuid:"):
+		id.setUUID(v[len("urn:uuid:"):])
+	case strings.HasPrefix(lower, "urn:doi:"):
+		id.setDOI(v[len("urn:doi:"):])
+	case strings.HasPrefix(lower, "doi:"):
+		id.setDOI(v[len("doi:"):])
+	case strings.HasPrefix(lower, "https://doi.org/"), strings.HasPrefix(lower, "http://dx.doi.org/"), strings.HasPrefix(lower, "https://dx.doi.org/"):
+		id.setDOI(v[strings.Index(lower, "doi.org/")+len("doi.org/"):])
+	case strings.HasPrefix(lower, "urn:asin:"):
+		id.setASIN(v[len("urn:asin:"):])
+	case uuidPattern.MatchString(v):
+		id.setUUID(v)
+	case doiPattern.MatchString(v):
+		id.setDOI(v)
+	case urnPattern.MatchString(v):
+		id.Type, id.Valid = "urn", true
+		// the namespace identifier is case insensitive
+		parts := strings.SplitN(v, ":", 3)
+		id.Value = "urn:" + strings.ToLower(parts[1]) + ":" + parts[2]
+	case strings.HasPrefix(lower, "http://"), strings.HasPrefix(lower, "https://"):
+		id.Type, id.Valid = "url", true
+	case chinesePattern.MatchString(v):
+		id.Type, id.Valid = "chinese-book-number", true
+	case strings.Contains(scheme, "isbn") || onixIdentifierTypes[scheme] == "isbn":
+		id.setISBN(v)
+	case strings.Contains(scheme, "asin") || asinPattern.MatchString(v):
+		id.setASIN(v)
+	case scheme == "doi" || onixIdentifierTypes[scheme] == "doi":
+		id.setDOI(v)
+	default:
+		// a bare ISBN is only recognized if its check digit is right
+		if isbn, err := NormalizeISBN(v); err == nil {
+			id.Type, id.Value, id.Valid = "isbn", isbn, true
+		}
+	}
+	return id
+}
+
+func (id *Identifier) setISBN(value string) {
+	id.Type = "isbn"
+	isbn, err := NormalizeISBN(value)
+	if err != nil {
+		id.Value = strings.TrimSpace(value)
+		return
+	}
+	id.Value, id.Valid = isbn, true
+}
+
+func (id *Identifier) setUUID(value string) {
+	id.Type = "uuid"
+	id.Value = strings.ToLower(strings.TrimSpace(value))
+	id.Valid = uuidPattern.MatchString(id.Value)
+}
+
+func (id *Identifier) setDOI(value string) {
+	id.Type = "doi"
+	id.Value = strings.TrimSpace(value)
+	id.Valid = doiPattern.MatchString(id.Value)
+}
+
+func (id *Identifier) setASIN(value string) {
+	id.Type = "asin"
+	id.Value = strings.ToUpper(strings.TrimSpace(value))
+	_, err := NormalizeISBN(id.Value)
+	id.Valid = asinPattern.MatchString(id.Value) || err == nil
+}
+
+// NormalizeISBN validates the check digit of an ISBN-10 or ISBN-13, with or
+// without hyphens and spaces, and returns it as ISBN-13 without them
+func NormalizeISBN(isbn string) (string, error) {
+	digits := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' || r == '‐' || r == '‑' {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(isbn))
+	digits = strings.ToUpper(digits)
+
+	switch len(digits) {
+	case 10:
+		sum := 0
+		for i, r := range digits {
+			var d int
+			switch {
+			case r >= '0' && r <= '9':
+				d = int(r - '0')
+			case r == 'X' && i == 9:
+				d = 10
+			default:
+				return "", errors.New("Invalid ISBN " + isbn)
+			}
+			sum += d * (10 - i)
+		}
+		if sum%11 != 0 {
+			return "", errors.New("Wrong check digit on the ISBN " + isbn)
+		}
+		isbn13 := "978" + digits[:9]
+		return isbn13 + isbn13CheckDigit(isbn13), nil
+	case 13:
+		for _, r := range digits {
+			if r < '0' || r > '9' {
+				return "", errors.New("Invalid ISBN " + isbn)
+			}
+		}
+		if !strings.HasPrefix(digits, "978") && !strings.HasPrefix(digits, "979") {
+			return "", errors.New("Invalid ISBN prefix " + isbn)
+		}
+		if isbn13CheckDigit(digits[:12]) != digits[12:] {
+			return "", errors.New("Wrong check digit on the ISBN " + isbn)
+		}
+		return digits, nil
+	}
+	return "", errors.New("Invalid ISBN length " + isbn)
+}
+
+// isbn13CheckDigit returns the check digit of the first 12 digits of an
+// ISBN-13
+func isbn13CheckDigit(digits string) string {
+	sum := 0
+	for i, r := range digits[:12] {
+		d := int(r - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return strconv.Itoa((10 - sum%10) % 10)
+}
+
+// Identifiers returns the identifiers of the book classified and
+// normalized, in the order of the metadata
+func (e Epub) Identifiers() []Identifier {
+	identifiers := []Identifier{}
+	uniqueFound := false
+	for _, ident := range e.opf.Metadata.Identifier {
+		scheme := ident.Scheme
+		if ident.ID != "" {
+			for _, m := range e.opf.Metadata.Meta {
+				if m.Refines == "#"+ident.ID && m.Property == "identifier-type" {
+					scheme = strings.TrimSpace(m.Data)
+				}
+			}
+		}
+		id := ParseIdentifier(ident.Data, scheme)
+		id.ID = ident.ID
+		if !uniqueFound && ident.ID != "" && ident.ID == e.opf.UniqueIdentifier {
+			id.Unique = true
+			uniqueFound = true
+		}
+		identifiers = append(identifiers, id)
+	}
+	if !uniqueFound && len(identifiers) > 0 {
+		// like uniqueIdentifier(), the first one if the reference is broken
+		identifiers[0].Unique = true
+	}
+	return identifiers
+}
+
+// PackageIdentifier returns the identifier referenced by the
+// unique-identifier of the package
+func (e Epub) PackageIdentifier() (Identifier, error) {
+	for _, id := range e.Identifiers() {
+		if id.Unique {
+			return id, nil
+		}
+	}
+	return Identifier{}, errors.New("The book has no identifier")
+}
+
+// ISBN returns the first valid ISBN of the book as ISBN-13, "" if it has
+// none
+func (e Epub) ISBN() string {
+	for _, id := range e.Identifiers() {
+		if id.Type == "isbn" && id.Valid {
+			return id.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,97 @@
+package raw
+
+import (
+	"testing"
+)
+
+func TestParseIdentifier(t *testing.T) {
+	for _, test := range []struct {
+		value, scheme string
+		typ, norm     string
+		valid         bool
+	}{
+		{"0-306-40615-2", "ISBN", "isbn", "9780306406157", true},
+		{"urn:isbn:978-0-306-40615-7", "", "isbn", "9780306406157", true},
+		{"ISBN-13: 978-0-306-40615-7", "", "isbn", "9780306406157", true},
+		{"isbn-10 0-306-40615-2", "", "isbn", "9780306406157", true},
+		{"ISBN:0306406152", "", "isbn", "9780306406157", true},
+		{"080442957x", "", "isbn", "9780804429573", true},
+		{"9780306406158", "", "", "9780306406158", false},
+		{"978-0-306-40615-8", "ISBN", "isbn", "978-0-306-40615-8", false},
+		{"9780306406158", "15", "isbn", "9780306406158", false},
+		{"urn:uuid:D1314A2B-DA11-4D93-A340-6A639AB6B8CE", "", "uuid", "d1314a2b-da11-4d93-a340-6a639ab6b8ce", true},
+		{"851f00dc-f8df-491c-95e8-323f94090dc6", "MOBI-ASIN", "uuid", "851f00dc-f8df-491c-95e8-323f94090dc6", true},
+		{"doi:10.1000/182", "", "doi", "10.1000/182", true},
+		{"urn:doi:10.1000/182", "", "doi", "10.1000/182", true},
+		{"https://doi.org/10.1000/182", "", "doi", "10.1000/182", true},
+		{"B00ABCDEFG", "", "asin", "B00ABCDEFG", true},
+		{"b00abcdefg", "ASIN", "asin", "B00ABCDEFG", true},
+		{"12345", "MOBI-ASIN", "asin", "12345", false},
+		{"URN:NBN:de:101:1-2011", "", "urn", "urn:nbn:de:101:1-2011", true},
+		{"http://www.gutenberg.org/ebooks/3174", "URI", "url", "http://www.gutenberg.org/ebooks/3174", true},
+		{"1001·1165", "", "chinese-book-number", "1001·1165", true},
+		{"calibre-1234", "", "", "calibre-1234", false},
+	} {
+		id := ParseIdentifier(test.value, test.scheme)
+		if id.Type != test.typ || id.Value != test.norm || id.Valid != test.valid {
+			t.Errorf("ParseIdentifier(%q, %q) = %q, %q, %v", test.value, test.scheme, id.Type, id.Value, id.Valid)
+		}
+	}
+}
+
+func TestNormalizeISBN(t *testing.T) {
+	if isbn, err := NormalizeISBN("0 8044 2957 X"); err != nil || isbn != "9780804429573" {
+		t.Errorf("NormalizeISBN() = %q, %v", isbn, err)
+	}
+	for _, wrong := range []string{"0-306-40615-3", "X306406152", "1234567890123", "12345"} {
+		if _, err := NormalizeISBN(wrong); err == nil {
+			t.Errorf("NormalizeISBN(%q) didn't fail", wrong)
+		}
+	}
+}
+
+func TestIdentifiers(t *testing.T) {
+	e := newTestEpub(t, map[string]string{"content.opf": `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="pub-id">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier>urn:uuid:d1314a2b-da11-4d93-a340-6a639ab6b8ce</dc:identifier>
+    <dc:identifier id="pub-id">0-306-40615-2</dc:identifier>
+    <meta property="identifier-type" refines="#pub-id">02</meta>
+  </metadata>
+</package>`})
+	ids := e.Identifiers()
+	if len(ids) != 2 || ids[0].Unique || !ids[1].Unique || ids[1].Scheme != "02" {
+		t.Fatalf("The identifiers are %+v", ids)
+	}
+	if id, err := e.PackageIdentifier(); err != nil || id.ID != "pub-id" || id.Value != "9780306406157" {
+		t.Errorf("PackageIdentifier() = %+v, %v", id, err)
+	}
+	if isbn := e.ISBN(); isbn != "9780306406157" {
+		t.Errorf("ISBN() = %q", isbn)
+	}
+}
+
+func TestPackageIdentifierBooks(t *testing.T) {
+	for _, test := range []struct {
+		book, id, typ, value string
+	}{
+		{bookPath, "id", "url", "http://www.gutenberg.org/ebooks/3174"},
+		{"../testdata/gcdxy.epub", "uuid", "chinese-book-number", "1001·1165"},
+		{"../testdata/the_art_of_learning.epub", "uuid_id", "uuid", "d1314a2b-da11-4d93-a340-6a639ab6b8ce"},
+	} {
+		f, err := NewEpub(test.book)
+		if err != nil {
+			t.Fatalf("Can't open %v: %v", test.book, err)
+		}
+		id, err := f.PackageIdentifier()
+		f.Close()
+		if err != nil || id.ID != test.id || id.Type != test.typ || id.Value != test.value || !id.Unique {
+			t.Errorf("The package identifier of %v is %+v (%v)", test.book, id, err)
+		}
+	}
+	e := newTestEpub(t, map[string]string{"content.opf": `<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+<metadata/></package>`})
+	if _, err := e.PackageIdentifier(); err == nil {
+		t.Errorf("PackageIdentifier() without identifiers didn't fail")
+	}
+}
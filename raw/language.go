@@ -0,0 +1,382 @@
+package raw
+
+import (
+	"errors"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/text/language"
+)
+
+// TextLayout is the language and direction of the text of the book, or of a
+// page of it
+type TextLayout struct {
+	// Language is the BCP 47 tag, like "zh-Hans-CN", "" if it's unknown
+	Language string
+	// Direction is "ltr" or "rtl"
+	Direction string
+	// WritingMode is "horizontal-tb", "vertical-rl" or "vertical-lr"
+	WritingMode string
+}
+
+// IsVertical returns whether the text goes from top to bottom
+func (l TextLayout) IsVertical() bool {
+	return strings.HasPrefix(l.WritingMode, "vertical-")
+}
+
+// languages written with more than one script, the script is added to their
+// tags
+var multiScriptLanguages = map[string]bool{
+	"az": true,
+	"bs": true,
+	"mn": true,
+	"pa": true,
+	"sr": true,
+	"uz": true,
+	"zh": true,
+}
+
+// scripts written from right to left
+var rtlScripts = map[string]bool{
+	"Adlm": true,
+	"Arab": true,
+	"Hebr": true,
+	"Mand": true,
+	"Nkoo": true,
+	"Rohg": true,
+	"Samr": true,
+	"Syrc": true,
+	"Thaa": true,
+}
+
+// language names found instead of the codes
+var languageNames = map[string]string{
+	"arabic":   "ar",
+	"chinese":  "zh",
+	"english":  "en",
+	"french":   "fr",
+	"german":   "de",
+	"hebrew":   "he",
+	"italian":  "it",
+	"japanese": "ja",
+	"korean":   "ko",
+	"persian":  "fa",
+	"russian":  "ru",
+	"spanish":  "es",
+}
+
+// NormalizeLanguage returns the BCP 47 tag of the language lang
+//
+// The case is fixed, the deprecated codes replaced and the languages with
+// more than one script get the likely one, so "zh-cn" is "zh-Hans-CN".
+func NormalizeLanguage(lang string) (string, error) {
+	s := strings.Replace(strings.TrimSpace(lang), "_", "-", -1)
+	if code, ok := languageNames[strings.ToLower(s)]; ok {
+		s = code
+	}
+	tag, err := language.Parse(s)
+	if err != nil {
+		return "", err
+	}
+	base, conf := tag.Base()
+	if conf == language.No || tag == language.Und {
+		return "", errors.New("Undefined language " + lang)
+	}
+	if !multiScriptLanguages[base.String()] {
+		return tag.String(), nil
+	}
+	script, _ := tag.Script()
+	normalized := base.String() + "-" + script.String()
+	if region, conf := tag.Region(); conf == language.Exact {
+		normalized += "-" + region.String()
+	}
+	return normalized, nil
+}
+
+// languageDirection returns the direction of the script of the language
+func languageDirection(lang string) string {
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return "ltr"
+	}
+	if script, _ := tag.Script(); rtlScripts[script.String()] {
+		return "rtl"
+	}
+	return "ltr"
+}
+
+// Languages returns the languages of the metadata as BCP 47 tags, the ones
+// that can't be parsed are left out
+func (e Epub) Languages() []string {
+	languages := []string{}
+	for _, lang := range e.opf.Metadata.Language {
+		if normalized, err := NormalizeLanguage(lang); err == nil {
+			languages = append(languages, normalized)
+		}
+	}
+	return languages
+}
+
+// TextLayout returns the text layout of the book
+//
+// The language is the first one of the metadata, the direction and writing
+// mode the most common on the documents of the spine. If the metadata has
+// no valid language it's the most common on the documents as well.
+func (e Epub) TextLayout() TextLayout {
+	declared := ""
+	if languages := e.Languages(); len(languages) > 0 {
+		declared = languages[0]
+	}
+
+	languages := map[string]int{}
+	directions := map[string]int{}
+	modes := map[string]int{}
+	for i := 0; i < e.opf.spineLength(); i++ {
+		l, err := e.pageTextLayout(i, declared)
+		if err != nil {
+			continue
+		}
+		languages[l.Language]++
+		directions[l.Direction]++
+		modes[l.WritingMode]++
+	}
+
+	l := TextLayout{
+		Language:    declared,
+		Direction:   mostCommon(directions, languageDirection(declared)),
+		WritingMode: mostCommon(modes, "horizontal-tb"),
+	}
+	if l.Language == "" {
+		delete(languages, "")
+		l.Language = mostCommon(languages, "")
+	}
+	return l
+}
+
+// mostCommon returns the key with the biggest count, or def if there is a
+// tie with it or counts is empty
+func mostCommon(counts map[string]int, def string) string {
+	best, bestCount := def, counts[def]
+	for key, count := range counts {
+		switch {
+		case count > bestCount:
+			best, bestCount = key, count
+		case count == bestCount && best != def && key < best:
+			// keep the result stable on ties
+			best = key
+		}
+	}
+	return best
+}
+
+// PageTextLayout returns the text layout of the document on the position
+// index of the spine
+//
+// The language and direction come from the lang and dir attributes of the
+// body or the html element, or from the language of the book. The direction
+// and the writing mode can be set by the style sheets as well.
+func (e Epub) PageTextLayout(index int) (TextLayout, error) {
+	declared := ""
+	if languages := e.Languages(); len(languages) > 0 {
+		declared = languages[0]
+	}
+	return e.pageTextLayout(index, declared)
+}
+
+func (e Epub) pageTextLayout(index int, bookLanguage string) (TextLayout, error) {
+	if index < 0 || index >= e.opf.spineLength() {
+		return TextLayout{}, errors.New("Spine index " + strconv.Itoa(index) + " out of range")
+	}
+	l := TextLayout{Language: bookLanguage, WritingMode: "horizontal-tb"}
+	item := e.opf.spineItem(index)
+	if item == nil {
+		return TextLayout{}, errors.New("The spine item " + strconv.Itoa(index) + " is not in the manifest")
+	}
+	if !isTextContent(item.MediaType) {
+		l.Direction = languageDirection(l.Language)
+		return l, nil
+	}
+	doc, err := e.ParseDocument(item.Href)
+	if err != nil {
+		return TextLayout{}, err
+	}
+
+	dir := ""
+	rules := e.documentStyleRules(item.Href, doc)
+	for _, tag := range []string{"html", "body"} {
+		n := findElement(doc, tag)
+		if n == nil {
+			continue
+		}
+		lang := attr(n, "xml:lang")
+		if lang == "" {
+			lang = attr(n, "lang")
+		}
+		if normalized, err := NormalizeLanguage(lang); err == nil {
+			l.Language = normalized
+		}
+		switch d := strings.ToLower(strings.TrimSpace(attr(n, "dir"))); d {
+		case "ltr", "rtl":
+			dir = d
+		}
+
+		declarations := []string{}
+		for _, rule := range rules {
+			if selectorsMatch(rule.selectors, n) {
+				declarations = append(declarations, rule.declarations)
+			}
+		}
+		declarations = append(declarations, attr(n, "style"))
+		for _, block := range declarations {
+			for _, declaration := range strings.Split(block, ";") {
+				parts := strings.SplitN(declaration, ":", 2)
+				if len(parts) != 2 {
+					continue
+				}
+				property := strings.ToLower(strings.TrimSpace(parts[0]))
+				value := strings.ToLower(strings.TrimSpace(strings.Replace(parts[1], "!important", "", -1)))
+				switch property {
+				case "writing-mode", "-epub-writing-mode", "-webkit-writing-mode":
+					if mode := normalizeWritingMode(value); mode != "" {
+						l.WritingMode = mode
+					}
+				case "direction":
+					if value == "ltr" || value == "rtl" {
+						dir = value
+					}
+				}
+			}
+		}
+	}
+
+	l.Direction = dir
+	if l.Direction == "" {
+		l.Direction = languageDirection(l.Language)
+	}
+	return l, nil
+}
+
+// normalizeWritingMode returns the css 3 writing mode of value, the older
+// svg values like "tb-rl" are still used on the books
+func normalizeWritingMode(value string) string {
+	switch value {
+	case "horizontal-tb", "lr-tb", "lr", "rl-tb", "rl":
+		return "horizontal-tb"
+	case "vertical-rl", "tb-rl", "tb":
+		return "vertical-rl"
+	case "vertical-lr", "tb-lr":
+		return "vertical-lr"
+	}
+	return ""
+}
+
+type styleRule struct {
+	selectors    string
+	declarations string
+}
+
+// the at-rules without block, like @charset or @import, that would be taken
+// as part of the selector of the next rule
+var cssStatement = regexp.MustCompile(`@[\w-]+(?:"[^"]*"|'[^']*'|[^{};"'])*;`)
+
+// documentStyleRules returns the css rules of the linked style sheets and
+// the style elements of the document href, in order
+func (e Epub) documentStyleRules(href string, doc *html.Node) []styleRule {
+	rules := []styleRule{}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			css := ""
+			switch {
+			case n.Data == "link" && strings.Contains(strings.ToLower(attr(n, "rel")), "stylesheet"):
+				src, _ := splitFragment(attr(n, "href"))
+				if f, err := e.OpenResource(resolveHref(href, src)); err == nil {
+					data, _ := ioutil.ReadAll(f)
+					f.Close()
+					css = string(data)
+				}
+			case n.Data == "style":
+				css = nodeText(n)
+			}
+			css = cssComment.ReplaceAllString(css, "")
+			css = cssStatement.ReplaceAllString(css, "")
+			rules = append(rules, cssRules(css)...)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return rules
+}
+
+// cssRules returns the top level rules of css; the blocks of the at-rules,
+// like @media or @supports, are skipped as their rules only apply on some
+// conditions
+func cssRules(css string) []styleRule {
+	rules := []styleRule{}
+	depth, start, open := 0, 0, 0
+	for i, c := range css {
+		switch c {
+		case '{':
+			if depth == 0 {
+				open = i
+			}
+			depth++
+		case '}':
+			if depth == 0 {
+				start = i + 1
+				continue
+			}
+			depth--
+			if depth > 0 {
+				continue
+			}
+			selectors := strings.TrimSpace(css[start:open])
+			if selectors != "" && !strings.HasPrefix(selectors, "@") && !strings.Contains(css[open+1:i], "{") {
+				rules = append(rules, styleRule{selectors, css[open+1 : i]})
+			}
+			start = i + 1
+		}
+	}
+	return rules
+}
+
+var simpleSelector = regexp.MustCompile(`^([a-zA-Z]+|\*|:root)?((\.[\w-]+)*)$`)
+
+// selectorsMatch returns whether any of the comma separated selectors
+// matches the element n, only the simple selectors of a type and classes
+// are understood
+func selectorsMatch(selectors string, n *html.Node) bool {
+	classes := strings.Fields(attr(n, "class"))
+	for _, selector := range strings.Split(selectors, ",") {
+		match := simpleSelector.FindStringSubmatch(strings.TrimSpace(selector))
+		if match == nil || (match[1] == "" && match[2] == "") {
+			continue
+		}
+		switch strings.ToLower(match[1]) {
+		case "", "*", n.Data:
+		case ":root":
+			if n.Data != "html" {
+				continue
+			}
+		default:
+			continue
+		}
+		matches := true
+		for _, class := range strings.Split(match[2], ".")[1:] {
+			found := false
+			for _, c := range classes {
+				found = found || c == class
+			}
+			matches = matches && found
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package raw
+
+import "testing"
+
+func TestNormalizeLanguage(t *testing.T) {
+	for _, test := range []struct {
+		lang, tag string
+	}{
+		{"zh-cn", "zh-Hans-CN"},
+		{"zh_TW", "zh-Hant-TW"},
+		{"zh", "zh-Hans"},
+		{"EN-us", "en-US"},
+		{"en", "en"},
+		{"iw", "he"},
+		{"Japanese", "ja"},
+		{" ar ", "ar"},
+	} {
+		if tag, err := NormalizeLanguage(test.lang); err != nil || tag != test.tag {
+			t.Errorf("NormalizeLanguage(%q) = %q, %v", test.lang, tag, err)
+		}
+	}
+	for _, wrong := range []string{"", "und", "not a language"} {
+		if tag, err := NormalizeLanguage(wrong); err == nil {
+			t.Errorf("NormalizeLanguage(%q) = %q didn't fail", wrong, tag)
+		}
+	}
+}
+
+func TestPageTextLayout(t *testing.T) {
+	const vertical = `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="ja" class="vrtl"><head>
+<link rel="stylesheet" type="text/css" href="../css/book.css"/>
+</head><body><p>縦書き</p></body></html>`
+	const css = `/* the writing mode of the book */
+.vrtl { -epub-writing-mode: vertical-rl; writing-mode: tb-rl !important }
+p { writing-mode: horizontal-tb }`
+	const arabic = `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml"><head>
+<style>body { direction: ltr }</style>
+</head><body lang="ar" dir="rtl"><p>مرحبا</p></body></html>`
+	const charset = `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="ja"><head>
+<style>@charset "UTF-8";
+@import url("../css/book.css");
+html{-epub-writing-mode:vertical-rl}</style>
+</head><body><p>縦書き</p></body></html>`
+	const inline = `<html><body style="writing-mode: vertical-lr; direction: rtl"><p>text</p></body></html>`
+
+	const opf = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:language>ja-jp</dc:language></metadata>
+  <manifest>
+    <item id="p1" href="text/p1.xhtml" media-type="application/xhtml+xml"/>
+    <item id="p2" href="text/p2.xhtml" media-type="application/xhtml+xml"/>
+    <item id="p3" href="text/p3.xhtml" media-type="application/xhtml+xml"/>
+    <item id="p4" href="text/p4.xhtml" media-type="application/xhtml+xml"/>
+    <item id="p5" href="text/p5.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine><itemref idref="p1"/><itemref idref="p2"/><itemref idref="p3"/><itemref idref="p4"/><itemref idref="p5"/></spine>
+</package>`
+
+	e := newTestEpub(t, map[string]string{
+		"content.opf":   opf,
+		"text/p1.xhtml": vertical,
+		"css/book.css":  css,
+		"text/p2.xhtml": arabic,
+		"text/p3.xhtml": inline,
+		"text/p4.xhtml": vertical,
+		"text/p5.xhtml": charset,
+	})
+
+	for i, expected := range []TextLayout{
+		{"ja", "ltr", "vertical-rl"},
+		{"ar", "ltr", "horizontal-tb"},
+		{"ja-JP", "rtl", "vertical-lr"},
+		{"ja", "ltr", "vertical-rl"},
+		{"ja", "ltr", "vertical-rl"},
+	} {
+		l, err := e.PageTextLayout(i)
+		if err != nil {
+			t.Fatalf("PageTextLayout(%d) return an error: %v", i, err)
+		}
+		if l != expected {
+			t.Errorf("The text layout of the page %d is %+v", i, l)
+		}
+	}
+	if l := e.TextLayout(); l != (TextLayout{"ja-JP", "ltr", "vertical-rl"}) || !l.IsVertical() {
+		t.Errorf("The text layout of the book is %+v", l)
+	}
+	if _, err := e.PageTextLayout(5); err == nil {
+		t.Errorf("PageTextLayout(5) didn't fail")
+	}
+}
+
+func TestTextLayoutBook(t *testing.T) {
+	f, err := NewEpub("../testdata/gcdxy.epub")
+	if err != nil {
+		t.Fatalf("Can't open gcdxy: %v", err)
+	}
+	defer f.Close()
+	if languages := f.Languages(); len(languages) != 1 || languages[0] != "zh-Hans-CN" {
+		t.Errorf("The languages are %v", languages)
+	}
+	if l := f.TextLayout(); l != (TextLayout{"zh-Hans-CN", "ltr", "horizontal-tb"}) || l.IsVertical() {
+		t.Errorf("The text layout is %+v", l)
+	}
+}
+
+func TestCSSRules(t *testing.T) {
+	const css = `body { direction: ltr }
+@media (orientation: portrait) { html { writing-mode: vertical-rl } p { direction: rtl } }
+@supports (writing-mode: vertical-rl) { @media screen { body { writing-mode: vertical-rl } } }
+@font-face { font-family: x }
+p.note{ direction: rtl }`
+	rules := cssRules(css)
+	if len(rules) != 2 {
+		t.Fatalf("cssRules = %q", rules)
+	}
+	if rules[0].selectors != "body" || rules[0].declarations != " direction: ltr " {
+		t.Errorf("rules[0] = %q", rules[0])
+	}
+	if rules[1].selectors != "p.note" || rules[1].declarations != " direction: rtl " {
+		t.Errorf("rules[1] = %q", rules[1])
+	}
+}
@@ -0,0 +1,408 @@
+package raw
+
+import (
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+// Reference is a link, or a reference to a resource, from a file of the book
+type Reference struct {
+	// From is the href of the referencing file, relative to the opf, ""
+	// for the references of the package document
+	From string
+	// Kind is "href", "src", "xlink:href", "url" or "import" for the
+	// references found on the content, or "spine", "nav", "ncx", "cover",
+	// "fallback" or "media-overlay" for the ones of the package document
+	Kind string
+	// Value is the reference as it's written
+	Value string
+	// Target is the referenced file relative to the opf and Fragment the
+	// part after the '#', Target is "" for the external references
+	Target   string
+	Fragment string
+
+	// MissingFile is set if the target is not on the zip
+	MissingFile bool
+	// NotInManifest is set if the target is not on the manifest
+	NotInManifest bool
+	// MissingFragment is set if there is no element with the id of the
+	// fragment on the target document
+	MissingFragment bool
+}
+
+// IsExternal returns whether the reference points outside the book
+func (r Reference) IsExternal() bool {
+	return r.Target == ""
+}
+
+// IsBroken returns whether the reference can't be followed
+func (r Reference) IsBroken() bool {
+	return r.MissingFile || r.NotInManifest || r.MissingFragment
+}
+
+// LinkGraph is the graph of references between the files of the book
+type LinkGraph struct {
+	// Files are the hrefs of the manifest
+	Files      []string
+	References []Reference
+	// Unreachable are the files of the manifest that can't be reached from
+	// the spine, the navigation or the cover
+	Unreachable []string
+}
+
+// Broken returns the references that can't be followed
+func (g LinkGraph) Broken() []Reference {
+	broken := []Reference{}
+	for _, ref := range g.References {
+		if ref.IsBroken() {
+			broken = append(broken, ref)
+		}
+	}
+	return broken
+}
+
+// Dot returns the graph on the graphviz dot language, with the broken
+// references and the missing files in red and the unreachable files dashed
+func (g LinkGraph) Dot() string {
+	var b strings.Builder
+	b.WriteString("digraph epub {\n")
+	b.WriteString("  \"\" [label=\"package\" shape=box];\n")
+	unreachable := make(map[string]bool)
+	for _, file := range g.Unreachable {
+		unreachable[file] = true
+	}
+	for _, file := range g.Files {
+		b.WriteString("  " + strconv.Quote(file))
+		if unreachable[file] {
+			b.WriteString(" [style=dashed]")
+		}
+		b.WriteString(";\n")
+	}
+
+	written := make(map[string]bool)
+	for _, ref := range g.References {
+		if ref.IsExternal() {
+			continue
+		}
+		if ref.MissingFile || ref.NotInManifest {
+			if !written[ref.Target] {
+				b.WriteString("  " + strconv.Quote(ref.Target) + " [color=red fontcolor=red];\n")
+				written[ref.Target] = true
+			}
+		}
+		label := ref.Kind
+		if ref.Fragment != "" {
+			label += " #" + ref.Fragment
+		}
+		b.WriteString("  " + strconv.Quote(ref.From) + " -> " + strconv.Quote(ref.Target) +
+			" [label=" + strconv.Quote(label))
+		if ref.IsBroken() {
+			b.WriteString(" color=red fontcolor=red")
+		}
+		b.WriteString("];\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+var (
+	cssComment = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	urlScheme  = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+	cssURL     = regexp.MustCompile(`(@import\s+)?url\(\s*("[^"]*"|'[^']*'|[^)\s]*)\s*\)|@import\s+("[^"]*"|'[^']*')`)
+)
+
+// CheckLinks collects the references of the XHTML, SVG, CSS and SMIL files
+// of the manifest, and of the package document, and checks that their
+// targets exist
+//
+// The references are resolved relative to the referencing file, and the
+// fragments are checked on the XHTML documents.
+func (e Epub) CheckLinks() (*LinkGraph, error) {
+	c := linkChecker{
+		e:     e,
+		zip:   make(map[string]bool),
+		ids:   make(map[string]map[string]bool),
+		graph: &LinkGraph{Files: []string{}, References: []Reference{}, Unreachable: []string{}},
+	}
+	for _, name := range e.fileNames() {
+		c.zip[name] = true
+	}
+
+	c.packageReferences()
+	for _, item := range e.opf.Manifest {
+		c.graph.Files = append(c.graph.Files, item.Href)
+		if !c.zip[path.Join(e.rootPath, cleanHref(item.Href))] {
+			continue
+		}
+		if err := c.fileReferences(item); err != nil {
+			return nil, err
+		}
+	}
+	for i := range c.graph.References {
+		c.check(&c.graph.References[i])
+	}
+	c.graph.Unreachable = c.unreachable()
+	return c.graph, nil
+}
+
+type linkChecker struct {
+	e   Epub
+	zip map[string]bool
+	// ids of the elements of each XHTML document, by its clean href
+	ids   map[string]map[string]bool
+	graph *LinkGraph
+}
+
+// add adds the reference value found on the file from
+func (c *linkChecker) add(from, kind, value string) {
+	ref := Reference{From: from, Kind: kind, Value: value}
+	value = strings.TrimSpace(value)
+	if urlScheme.MatchString(value) || strings.HasPrefix(value, "//") {
+		c.graph.References = append(c.graph.References, ref)
+		return
+	}
+	file, fragment := splitFragment(value)
+	if i := strings.Index(file, "?"); i >= 0 {
+		file = file[:i]
+	}
+	if file == "" {
+		ref.Target = from
+	} else {
+		ref.Target = resolveHref(from, file)
+	}
+	ref.Fragment = fragment
+	if unescaped, err := url.PathUnescape(fragment); err == nil {
+		ref.Fragment = unescaped
+	}
+	c.graph.References = append(c.graph.References, ref)
+}
+
+// addItem adds the reference of the package document from the file to
+// the manifest item id
+func (c *linkChecker) addItem(from, kind, id string) {
+	ref := Reference{From: from, Kind: kind, Value: id, Target: c.e.opf.filePath(id)}
+	if ref.Target == "" {
+		// not on the manifest, check() will flag it
+		ref.Target = id
+	}
+	c.graph.References = append(c.graph.References, ref)
+}
+
+func (c *linkChecker) packageReferences() {
+	opf := c.e.opf
+	for i := 0; i < opf.spineLength(); i++ {
+		if href := opf.spineURL(i); href != "" {
+			c.add("", "spine", href)
+		}
+	}
+	if href := c.e.navHref(); href != "" {
+		c.add("", "nav", href)
+	}
+	if c.e.ncxPath != "" {
+		c.add("", "ncx", c.e.ncxPath)
+	}
+	for _, m := range opf.Metadata.Meta {
+		if m.Name == "cover" && m.Content != "" {
+			c.addItem("", "cover", m.Content)
+		}
+	}
+	for _, item := range opf.Manifest {
+		for _, property := range strings.Fields(item.Properties) {
+			if property == "cover-image" {
+				c.add("", "cover", item.Href)
+			}
+		}
+		if item.ItemFallback != "" {
+			c.addItem(item.Href, "fallback", item.ItemFallback)
+		}
+		if item.Fallback != "" {
+			c.addItem(item.Href, "fallback", item.Fallback)
+		}
+		if item.FallbackStyle != "" {
+			c.addItem(item.Href, "fallback", item.FallbackStyle)
+		}
+		if item.MediaOverlay != "" {
+			c.addItem(item.Href, "media-overlay", item.MediaOverlay)
+		}
+	}
+
+	if c.e.NCX != nil {
+		for _, point := range c.e.NCX.navMap().flatten() {
+			c.add(c.e.ncxPath, "src", point.Content.Src)
+		}
+		for _, page := range c.e.NCX.PageList {
+			c.add(c.e.ncxPath, "src", page.Content.Src)
+		}
+		for _, list := range c.e.NCX.NavLists {
+			for _, target := range list.Targets {
+				c.add(c.e.ncxPath, "src", target.Content.Src)
+			}
+		}
+	}
+}
+
+// fileReferences adds the references found on the content of the item
+func (c *linkChecker) fileReferences(item *manifest) error {
+	switch {
+	case isTextContent(item.MediaType):
+	case item.MediaType == "image/svg+xml", item.MediaType == "text/css", item.MediaType == "application/smil+xml":
+	default:
+		return nil
+	}
+	f, err := c.e.OpenFile(item.Href)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case item.MediaType == "text/css":
+		c.cssReferences(item.Href, string(data))
+	case isTextContent(item.MediaType):
+		doc, err := parseDocument(strings.NewReader(string(data)))
+		if err != nil {
+			return err
+		}
+		c.documentReferences(item.Href, doc)
+	default:
+		c.xmlReferences(item.Href, data)
+	}
+	return nil
+}
+
+func (c *linkChecker) cssReferences(from, css string) {
+	css = cssComment.ReplaceAllString(css, "")
+	for _, match := range cssURL.FindAllStringSubmatch(css, -1) {
+		kind, value := "url", match[2]
+		if match[1] != "" || match[3] != "" {
+			kind = "import"
+		}
+		if match[3] != "" {
+			value = match[3]
+		}
+		value = strings.Trim(value, `"'`)
+		if value != "" {
+			c.add(from, kind, value)
+		}
+	}
+}
+
+func (c *linkChecker) documentReferences(from string, doc *html.Node) {
+	ids := make(map[string]bool)
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			for _, a := range n.Attr {
+				switch a.Key {
+				case "id":
+					ids[a.Val] = true
+				case "href", "src", "xlink:href":
+					if a.Val != "" {
+						c.add(from, a.Key, a.Val)
+					}
+				case "style":
+					c.cssReferences(from, a.Val)
+				}
+			}
+			if n.Data == "style" && n.FirstChild != nil {
+				c.cssReferences(from, nodeText(n))
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	c.ids[cleanHref(from)] = ids
+}
+
+// xmlReferences adds the src, href and xlink:href of the SVG and SMIL files
+func (c *linkChecker) xmlReferences(from string, data []byte) {
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	decoder.CharsetReader = charset.NewReaderLabel
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF || err != nil {
+			return
+		}
+		el, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		for _, a := range el.Attr {
+			kind := a.Name.Local
+			if a.Name.Space == "http://www.w3.org/1999/xlink" || a.Name.Space == "xlink" {
+				kind = "xlink:" + kind
+			} else if a.Name.Space != "" {
+				continue
+			}
+			if (kind == "src" || kind == "href" || kind == "xlink:href") && a.Value != "" {
+				c.add(from, kind, a.Value)
+			}
+		}
+	}
+}
+
+// check sets the problems of the reference
+func (c *linkChecker) check(ref *Reference) {
+	if ref.IsExternal() {
+		return
+	}
+	ref.MissingFile = !c.zip[path.Join(c.e.rootPath, cleanHref(ref.Target))]
+	ref.NotInManifest = c.e.FileManifest(ref.Target) == nil
+	if ids, ok := c.ids[cleanHref(ref.Target)]; ok && ref.Fragment != "" && !strings.HasPrefix(ref.Fragment, "epubcfi(") {
+		ref.MissingFragment = !ids[ref.Fragment]
+	}
+}
+
+// unreachable returns the files of the manifest that can't be reached
+// following the references from the package document
+func (c *linkChecker) unreachable() []string {
+	edges := make(map[string][]string)
+	for _, ref := range c.graph.References {
+		if ref.IsExternal() {
+			continue
+		}
+		from := ref.From
+		if from != "" {
+			from = cleanHref(from)
+		}
+		edges[from] = append(edges[from], cleanHref(ref.Target))
+	}
+	reached := make(map[string]bool)
+	var visit func(file string)
+	visit = func(file string) {
+		for _, target := range edges[file] {
+			if !reached[target] {
+				reached[target] = true
+				visit(target)
+			}
+		}
+	}
+	visit("")
+
+	unreachable := []string{}
+	for _, item := range c.e.opf.Manifest {
+		if !reached[cleanHref(item.Href)] {
+			unreachable = append(unreachable, item.Href)
+		}
+	}
+	sort.Strings(unreachable)
+	return unreachable
+}
@@ -0,0 +1,153 @@
+package raw
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckLinks(t *testing.T) {
+	const ch1 = `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml"><head>
+<link rel="stylesheet" href="css/style.css"/>
+</head><body id="top">
+<p><a href="ch2.xhtml#ok">ok</a> <a href="ch2.xhtml#bad">bad</a> <a href="#top">top</a>
+<a href="missing.xhtml">missing</a> <a href="http://example.com/">external</a></p>
+<p style="background: url('images/b.png')"><img src="images/a.png"/></p>
+</body></html>`
+	const ch2 = `<html><body><h1 id="ok">Two</h1></body></html>`
+	const css = `@import "other.css";
+/* url(ignored.png) */
+@font-face { src: url(../fonts/f.ttf); }`
+
+	const opf = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+<metadata/>
+<manifest>
+<item id="ch1" href="ch1.xhtml" media-type="application/xhtml+xml"/>
+<item id="ch2" href="ch2.xhtml" media-type="application/xhtml+xml"/>
+<item id="css" href="css/style.css" media-type="text/css"/>
+<item id="b" href="images/b.png" media-type="image/png"/>
+<item id="font" href="fonts/f.ttf" media-type="application/x-font-ttf"/>
+<item id="orphan" href="orphan.xhtml" media-type="application/xhtml+xml"/>
+</manifest>
+<spine><itemref idref="ch1"/><itemref idref="ch2"/></spine>
+</package>`
+
+	e := newTestEpub(t, map[string]string{
+		"OEBPS/content.opf":   opf,
+		"OEBPS/ch1.xhtml":     ch1,
+		"OEBPS/ch2.xhtml":     ch2,
+		"OEBPS/css/style.css": css,
+		"OEBPS/images/a.png":  "",
+		"OEBPS/images/b.png":  "",
+		"OEBPS/fonts/f.ttf":   "",
+		"OEBPS/orphan.xhtml":  ch2,
+	})
+
+	g, err := e.CheckLinks()
+	if err != nil {
+		t.Fatalf("CheckLinks() return an error: %v", err)
+	}
+	if len(g.Files) != 6 {
+		t.Errorf("The files are %v", g.Files)
+	}
+	if len(g.Unreachable) != 1 || g.Unreachable[0] != "orphan.xhtml" {
+		t.Errorf("The unreachable files are %v", g.Unreachable)
+	}
+
+	refs := make(map[string]Reference)
+	for _, ref := range g.References {
+		refs[ref.From+" "+ref.Value] = ref
+	}
+	tests := []struct {
+		key      string
+		expected Reference
+	}{
+		{" ch1.xhtml", Reference{Kind: "spine", Target: "ch1.xhtml"}},
+		{"ch1.xhtml css/style.css", Reference{Kind: "href", Target: "css/style.css"}},
+		{"ch1.xhtml ch2.xhtml#ok", Reference{Kind: "href", Target: "ch2.xhtml", Fragment: "ok"}},
+		{"ch1.xhtml ch2.xhtml#bad", Reference{Kind: "href", Target: "ch2.xhtml", Fragment: "bad", MissingFragment: true}},
+		{"ch1.xhtml #top", Reference{Kind: "href", Target: "ch1.xhtml", Fragment: "top"}},
+		{"ch1.xhtml missing.xhtml", Reference{Kind: "href", Target: "missing.xhtml", MissingFile: true, NotInManifest: true}},
+		{"ch1.xhtml http://example.com/", Reference{Kind: "href"}},
+		{"ch1.xhtml images/b.png", Reference{Kind: "url", Target: "images/b.png"}},
+		{"ch1.xhtml images/a.png", Reference{Kind: "src", Target: "images/a.png", NotInManifest: true}},
+		{"css/style.css other.css", Reference{Kind: "import", Target: "css/other.css", MissingFile: true, NotInManifest: true}},
+		{"css/style.css ../fonts/f.ttf", Reference{Kind: "url", Target: "fonts/f.ttf"}},
+	}
+	for _, test := range tests {
+		ref, ok := refs[test.key]
+		if !ok {
+			t.Errorf("The reference %q is missing", test.key)
+			continue
+		}
+		ref.From, ref.Value = "", ""
+		if ref != test.expected {
+			t.Errorf("The reference %q is %+v, the expected was %+v", test.key, ref, test.expected)
+		}
+	}
+	if _, ok := refs["css/style.css ignored.png"]; ok {
+		t.Errorf("The reference of a css comment was collected")
+	}
+	if len(g.References) != 12 || len(g.Broken()) != 4 {
+		t.Errorf("There are %d references and %d broken", len(g.References), len(g.Broken()))
+	}
+
+	dot := g.Dot()
+	if !strings.Contains(dot, `"ch1.xhtml" -> "missing.xhtml" [label="href" color=red fontcolor=red];`) ||
+		!strings.Contains(dot, `"orphan.xhtml" [style=dashed];`) {
+		t.Errorf("Unexpected dot graph:\n%s", dot)
+	}
+}
+
+func TestCheckLinksBook(t *testing.T) {
+	f, _ := NewEpub(bookPath)
+	defer f.Close()
+
+	g, err := f.CheckLinks()
+	if err != nil {
+		t.Fatalf("CheckLinks() return an error: %v", err)
+	}
+	if broken := g.Broken(); len(broken) != 0 {
+		t.Errorf("The book has broken references: %+v", broken)
+	}
+	if len(g.Unreachable) != 0 {
+		t.Errorf("The book has unreachable files: %v", g.Unreachable)
+	}
+}
+
+func TestCheckLinksHTML(t *testing.T) {
+	const ch1 = `<html><body><p><a href="ch2.html#note">note</a> <a href="ch2.html#bad">bad</a></p>
+<img src="images/a.png"></body></html>`
+	const ch2 = `<html><body><p id="note">Note</p></body></html>`
+
+	const opf = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+<metadata/>
+<manifest>
+<item id="ch1" href="ch1.html" media-type="text/html"/>
+<item id="ch2" href="ch2.html" media-type="text/html; charset=utf-8"/>
+<item id="a" href="images/a.png" media-type="image/png"/>
+</manifest>
+<spine><itemref idref="ch1"/></spine>
+</package>`
+
+	e := newTestEpub(t, map[string]string{
+		"content.opf":  opf,
+		"ch1.html":     ch1,
+		"ch2.html":     ch2,
+		"images/a.png": "",
+	})
+
+	g, err := e.CheckLinks()
+	if err != nil {
+		t.Fatalf("CheckLinks() return an error: %v", err)
+	}
+	if len(g.Unreachable) != 0 {
+		t.Errorf("The unreachable files are %v", g.Unreachable)
+	}
+	broken := g.Broken()
+	if len(broken) != 1 || broken[0].Value != "ch2.html#bad" || !broken[0].MissingFragment {
+		t.Errorf("The broken references are %+v", broken)
+	}
+}
@@ -0,0 +1,355 @@
+package raw
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// the core media types of EPUB 3, the ones reading systems must support
+var coreMediaTypes = map[string]bool{
+	"image/gif":                   true,
+	"image/jpeg":                  true,
+	"image/png":                   true,
+	"image/svg+xml":               true,
+	"image/webp":                  true,
+	"audio/mpeg":                  true,
+	"audio/mp4":                   true,
+	"audio/ogg":                   true,
+	"text/css":                    true,
+	"font/ttf":                    true,
+	"application/font-sfnt":       true,
+	"font/otf":                    true,
+	"application/vnd.ms-opentype": true,
+	"font/woff":                   true,
+	"application/font-woff":       true,
+	"font/woff2":                  true,
+	"application/xhtml+xml":       true,
+	"application/javascript":      true,
+	"application/ecmascript":      true,
+	"text/javascript":             true,
+	"application/x-dtbncx+xml":    true,
+	"application/smil+xml":        true,
+	"application/pls+xml":         true,
+}
+
+// wrong media types found in the wild and the right ones
+var mediaTypeAliases = map[string]string{
+	"image/jpg":                   "image/jpeg",
+	"image/pjpeg":                 "image/jpeg",
+	"image/svg":                   "image/svg+xml",
+	"audio/mp3":                   "audio/mpeg",
+	"audio/x-m4a":                 "audio/mp4",
+	"application/x-font-ttf":      "font/ttf",
+	"application/x-font-truetype": "font/ttf",
+	"application/x-truetype-font": "font/ttf",
+	"application/x-font-otf":      "font/otf",
+	"application/x-font-opentype": "font/otf",
+	"application/font-woff2":      "font/woff2",
+	"application/x-font-woff":     "font/woff",
+	"application/x-javascript":    "application/javascript",
+	"text/x-css":                  "text/css",
+}
+
+// media types by file extension, for the files that can't be sniffed
+var extensionMediaTypes = map[string]string{
+	".css":   "text/css",
+	".js":    "application/javascript",
+	".xhtml": "application/xhtml+xml",
+	".html":  "application/xhtml+xml",
+	".htm":   "application/xhtml+xml",
+	".svg":   "image/svg+xml",
+	".ncx":   "application/x-dtbncx+xml",
+	".smil":  "application/smil+xml",
+	".pls":   "application/pls+xml",
+	".jpg":   "image/jpeg",
+	".jpeg":  "image/jpeg",
+	".png":   "image/png",
+	".gif":   "image/gif",
+	".webp":  "image/webp",
+	".ttf":   "font/ttf",
+	".otf":   "font/otf",
+	".woff":  "font/woff",
+	".woff2": "font/woff2",
+	".mp3":   "audio/mpeg",
+	".m4a":   "audio/mp4",
+	".ogg":   "audio/ogg",
+	".opus":  "audio/ogg",
+	".mp4":   "video/mp4",
+	".webm":  "video/webm",
+}
+
+// media types of the XML files by their root element
+var xmlRootMediaTypes = map[string]string{
+	"html":    "application/xhtml+xml",
+	"svg":     "image/svg+xml",
+	"ncx":     "application/x-dtbncx+xml",
+	"smil":    "application/smil+xml",
+	"package": "application/oebps-package+xml",
+	"lexicon": "application/pls+xml",
+}
+
+// magic numbers at the start of the files
+var magicNumbers = []struct {
+	prefix    string
+	mediaType string
+}{
+	{"\xff\xd8\xff", "image/jpeg"},
+	{"\x89PNG\r\n\x1a\n", "image/png"},
+	{"GIF87a", "image/gif"},
+	{"GIF89a", "image/gif"},
+	{"wOFF", "font/woff"},
+	{"wOF2", "font/woff2"},
+	{"OTTO", "font/otf"},
+	{"\x00\x01\x00\x00", "font/ttf"},
+	{"ttcf", "font/collection"},
+	{"ID3", "audio/mpeg"},
+	{"OggS", "audio/ogg"},
+	{"\x1a\x45\xdf\xa3", "video/webm"},
+}
+
+// sniffLength is how much of a file is read to sniff its media type
+const sniffLength = 4096
+
+// SniffMediaType returns the media type of the content of a file from its
+// magic number or, for the XML files, from its root element
+//
+// Returns "" if it's not recognized, like for the css and javascript files.
+func SniffMediaType(data []byte) string {
+	s := string(data)
+	if strings.HasPrefix(s, "\xff\xfe") || strings.HasPrefix(s, "\xfe\xff") {
+		// the UTF-16 text would look like a mpeg audio frame
+		return sniffXML(data)
+	}
+	for _, magic := range magicNumbers {
+		if strings.HasPrefix(s, magic.prefix) {
+			return magic.mediaType
+		}
+	}
+	switch {
+	case len(s) >= 12 && s[:4] == "RIFF" && s[8:12] == "WEBP":
+		return "image/webp"
+	case len(s) >= 6 && s[:4] == "true" && s[4] == 0 && s[5] >= 1 && s[5] <= 64:
+		// old apple truetype, with the number of tables after the tag so
+		// the text starting with "true" isn't taken as a font
+		return "font/ttf"
+	case len(s) >= 12 && s[4:8] == "ftyp":
+		switch s[8:11] {
+		case "M4A", "M4B":
+			return "audio/mp4"
+		}
+		return "video/mp4"
+	case len(s) >= 2 && s[0] == 0xff && s[1]&0xe0 == 0xe0 && s[1]&0x06 != 0:
+		// mpeg audio frame, the layer bits are 0 on the aac ones
+		return "audio/mpeg"
+	}
+	return sniffXML(data)
+}
+
+// sniffXML returns the media type of an XML file from its root element,
+// "text/html" for the html documents without the XHTML namespace
+func sniffXML(data []byte) string {
+	utf16 := bytes.HasPrefix(data, []byte("\xff\xfe")) || bytes.HasPrefix(data, []byte("\xfe\xff"))
+	if utf16 {
+		decoded, err := unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM).NewDecoder().Bytes(data)
+		if err != nil {
+			return ""
+		}
+		data = decoded
+	}
+	trimmed := bytes.TrimLeft(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), " \t\r\n")
+	if !bytes.HasPrefix(trimmed, []byte("<")) {
+		return ""
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Entity = xml.HTMLEntity
+	decoder.CharsetReader = charset.NewReaderLabel
+	if utf16 {
+		// already decoded, whatever the declaration says
+		decoder.CharsetReader = func(label string, r io.Reader) (io.Reader, error) {
+			return r, nil
+		}
+	}
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		if el, ok := tok.(xml.StartElement); ok {
+			if el.Name.Local == "html" && el.Name.Space != "http://www.w3.org/1999/xhtml" {
+				// without namespace it's plain html
+				break
+			}
+			return xmlRootMediaTypes[el.Name.Local]
+		}
+	}
+
+	if len(trimmed) > 512 {
+		trimmed = trimmed[:512]
+	}
+	lower := strings.ToLower(string(trimmed))
+	if strings.HasPrefix(lower, "<!doctype html") || strings.Contains(lower, "<html") {
+		return "text/html"
+	}
+	return ""
+}
+
+// normalizeMediaType lowercases the media type, removes its parameters
+// and fixes the known wrong aliases
+func normalizeMediaType(mediaType string) string {
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if i := strings.Index(mediaType, ";"); i >= 0 {
+		mediaType = strings.TrimSpace(mediaType[:i])
+	}
+	if alias, ok := mediaTypeAliases[mediaType]; ok {
+		return alias
+	}
+	return mediaType
+}
+
+// IsCoreMediaType returns whether the media type is one of the core media
+// types of EPUB 3, the others need a fallback
+func IsCoreMediaType(mediaType string) bool {
+	return coreMediaTypes[normalizeMediaType(mediaType)]
+}
+
+// EffectiveMediaType returns the media type of the file href, relative to
+// the opf, from its content
+//
+// The sniffed media type wins over the one of the manifest, with the
+// known aliases like image/jpg fixed. The files that can't be sniffed
+// keep the media type of the manifest, or the one of their extension if
+// it's missing or generic.
+func (e Epub) EffectiveMediaType(href string) string {
+	declared := ""
+	if item := e.FileManifest(href); item != nil {
+		declared = normalizeMediaType(item.MediaType)
+	}
+
+	sniffed := ""
+	if f, err := e.OpenResource(href); err == nil {
+		data, _ := ioutil.ReadAll(io.LimitReader(f, sniffLength))
+		f.Close()
+		sniffed = SniffMediaType(data)
+	}
+
+	switch {
+	case sniffed == "text/html" && declared == "application/xhtml+xml":
+		// the html parser can read it, leave it as it's declared
+		return declared
+	case sniffed == "text/html":
+		return "application/xhtml+xml"
+	case sniffed == "video/mp4" && declared == "audio/mp4":
+		return declared
+	case sniffed == "font/ttf" && declared == "application/font-sfnt",
+		sniffed == "font/otf" && declared == "application/vnd.ms-opentype",
+		sniffed == "font/woff" && declared == "application/font-woff":
+		// the older names of the font types are still valid
+		return declared
+	case sniffed != "":
+		return sniffed
+	case declared != "" && declared != "application/octet-stream" && declared != "application/xml" && declared != "text/plain":
+		return declared
+	}
+	if mediaType, ok := extensionMediaTypes[strings.ToLower(path.Ext(href))]; ok {
+		return mediaType
+	}
+	return declared
+}
+
+// MediaTypeFix is a media type of the manifest that doesn't match the
+// content of the file
+type MediaTypeFix struct {
+	Href      string
+	Declared  string
+	Effective string
+}
+
+// MediaTypeFixes returns the items of the manifest whose media type is not
+// their effective one
+func (e Epub) MediaTypeFixes() []MediaTypeFix {
+	fixes := []MediaTypeFix{}
+	for _, item := range e.opf.Manifest {
+		effective := e.EffectiveMediaType(item.Href)
+		if effective != "" && effective != strings.TrimSpace(item.MediaType) {
+			fixes = append(fixes, MediaTypeFix{Href: item.Href, Declared: item.MediaType, Effective: effective})
+		}
+	}
+	return fixes
+}
+
+// SaveOptions are the changes applied to the epub when it's saved
+type SaveOptions struct {
+	// FixMediaTypes sets the effective media types on the manifest
+	FixMediaTypes bool
+}
+
+// SaveFile writes the epub on the file path
+func (e Epub) SaveFile(path string, opts SaveOptions) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := e.Save(f, opts); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Save writes the epub on w, with the changes of the options
+func (e Epub) Save(w io.Writer, opts SaveOptions) error {
+	changes := map[string][]byte{}
+	if opts.FixMediaTypes {
+		fixes := e.MediaTypeFixes()
+		if len(fixes) > 0 {
+			opfPath, opf, err := e.fixMediaTypes(fixes)
+			if err != nil {
+				return err
+			}
+			changes[opfPath] = opf
+		}
+	}
+	return e.writeEpub(w, changes)
+}
+
+// fixMediaTypes returns the path of the opf and its content with the media
+// types of the fixes
+func (e Epub) fixMediaTypes(fixes []MediaTypeFix) (string, []byte, error) {
+	opfPath, err := e.getOpfPath()
+	if err != nil {
+		return "", nil, err
+	}
+	f, err := e.reader.OpenFile(opfPath)
+	if err != nil {
+		return "", nil, err
+	}
+	doc, err := parseXMLTree(f)
+	f.Close()
+	if err != nil {
+		return "", nil, err
+	}
+	pkg := doc.root()
+	if pkg == nil || pkg.element("manifest") == nil {
+		return "", nil, errors.New("The opf file has no manifest")
+	}
+
+	effective := make(map[string]string)
+	for _, fix := range fixes {
+		effective[fix.Href] = fix.Effective
+	}
+	for _, item := range pkg.element("manifest").elements("item") {
+		if mediaType, ok := effective[item.attr("href")]; ok {
+			item.setAttr("media-type", mediaType)
+		}
+	}
+	return opfPath, doc.bytes(), nil
+}
@@ -0,0 +1,162 @@
+package raw
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestSniffMediaType(t *testing.T) {
+	tests := []struct {
+		data      string
+		mediaType string
+	}{
+		{"\xff\xd8\xff\xe0\x00\x10JFIF", "image/jpeg"},
+		{"\x89PNG\r\n\x1a\n\x00\x00", "image/png"},
+		{"GIF89a\x01\x00", "image/gif"},
+		{"RIFF\x00\x00\x00\x00WEBPVP8 ", "image/webp"},
+		{"OTTO\x00\x0a", "font/otf"},
+		{"\x00\x01\x00\x00\x00\x0f", "font/ttf"},
+		{"wOF2\x00\x01", "font/woff2"},
+		{"ID3\x03\x00", "audio/mpeg"},
+		{"\xff\xfb\x90\x00", "audio/mpeg"},
+		{"\x00\x00\x00\x20ftypM4A \x00", "audio/mp4"},
+		{"\x00\x00\x00\x20ftypisom\x00", "video/mp4"},
+		{"OggS\x00\x02", "audio/ogg"},
+		{`<?xml version="1.0"?><!DOCTYPE html><html xmlns="http://www.w3.org/1999/xhtml"><body/></html>`, "application/xhtml+xml"},
+		{"\xef\xbb\xbf<?xml version=\"1.0\"?>\n<!-- cover -->\n<svg xmlns=\"http://www.w3.org/2000/svg\"/>", "image/svg+xml"},
+		{`<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1"></ncx>`, "application/x-dtbncx+xml"},
+		{`<smil xmlns="http://www.w3.org/ns/SMIL" version="3.0"></smil>`, "application/smil+xml"},
+		{"<!DOCTYPE html>\n<html><body><p>unclosed<br></body></html>", "text/html"},
+		{encodeUTF16("\xff\xfe", `<?xml version="1.0" encoding="UTF-16"?><html xmlns="http://www.w3.org/1999/xhtml"><body/></html>`), "application/xhtml+xml"},
+		{encodeUTF16("\xfe\xff", `<svg xmlns="http://www.w3.org/2000/svg"/>`), "image/svg+xml"},
+		{"\xff\xfeb\x00o\x00d\x00y\x00", ""},
+		{"true\x00\x0f\x00\x80", "font/ttf"},
+		{"true story", ""},
+		{"body { margin: 0 }", ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if mediaType := SniffMediaType([]byte(test.data)); mediaType != test.mediaType {
+			t.Errorf("SniffMediaType(%q) is %q, the expected was %q", test.data, mediaType, test.mediaType)
+		}
+	}
+}
+
+func TestIsCoreMediaType(t *testing.T) {
+	for mediaType, core := range map[string]bool{
+		"image/jpeg":                 true,
+		"image/jpg":                  true,
+		"audio/ogg; codecs=opus":     true,
+		"application/x-font-ttf":     true,
+		"Application/XHTML+XML":      true,
+		"text/html":                  false,
+		"image/tiff":                 false,
+		"application/octet-stream":   false,
+		"application/x-shockwave-fl": false,
+	} {
+		if IsCoreMediaType(mediaType) != core {
+			t.Errorf("IsCoreMediaType(%q) is not %v", mediaType, core)
+		}
+	}
+}
+
+func wrongMediaTypesBook(t *testing.T) *Epub {
+	const opf = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="2.0">
+  <manifest>
+    <item id="ch1" href="ch1.html" media-type="text/html"/>
+    <item id="img" href="cover.jpg" media-type="image/jpg"/>
+    <item id="png" href="fake.jpg" media-type="image/jpeg"/>
+    <item id="font" href="font.otf" media-type="application/octet-stream"/>
+    <item id="css" href="style.css" media-type="text/plain"/>
+    <item id="ok" href="ch2.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+</package>`
+	return newTestEpub(t, map[string]string{
+		"content.opf": opf,
+		"ch1.html":    `<html xmlns="http://www.w3.org/1999/xhtml"><body/></html>`,
+		"cover.jpg":   "\xff\xd8\xff\xe0",
+		"fake.jpg":    "\x89PNG\r\n\x1a\n",
+		"font.otf":    "OTTO\x00\x0a",
+		"style.css":   "p { margin: 0 }",
+		"ch2.xhtml":   `<html><body><p>not xml<br></body></html>`,
+	})
+}
+
+func TestEffectiveMediaType(t *testing.T) {
+	e := wrongMediaTypesBook(t)
+	for href, mediaType := range map[string]string{
+		"ch1.html":  "application/xhtml+xml",
+		"cover.jpg": "image/jpeg",
+		"fake.jpg":  "image/png",
+		"font.otf":  "font/otf",
+		"style.css": "text/css",
+		"ch2.xhtml": "application/xhtml+xml",
+	} {
+		if effective := e.EffectiveMediaType(href); effective != mediaType {
+			t.Errorf("EffectiveMediaType(%q) is %q, the expected was %q", href, effective, mediaType)
+		}
+	}
+	if !isTextContent("text/html") || !isTextContent("application/xhtml+xml; charset=utf-8") {
+		t.Errorf("isTextContent() doesn't accept the html media types")
+	}
+}
+
+func TestSaveFixMediaTypes(t *testing.T) {
+	e := wrongMediaTypesBook(t)
+
+	fixes := e.MediaTypeFixes()
+	if len(fixes) != 5 {
+		t.Errorf("The fixes are %+v", fixes)
+	}
+
+	var b bytes.Buffer
+	if err := e.Save(&b, SaveOptions{FixMediaTypes: true}); err != nil {
+		t.Fatalf("Save() return an error: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatalf("The saved epub is not a zip: %v", err)
+	}
+	for _, f := range zr.File {
+		if f.Name != "content.opf" {
+			continue
+		}
+		r, _ := f.Open()
+		data, _ := ioutil.ReadAll(r)
+		r.Close()
+		saved, err := parseOPF(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Can't parse the saved opf: %v", err)
+		}
+		for i, expected := range []string{"application/xhtml+xml", "image/jpeg", "image/png", "font/otf", "text/css", "application/xhtml+xml"} {
+			if saved.Manifest[i].MediaType != expected {
+				t.Errorf("The media type of %v is %v, the expected was %v", saved.Manifest[i].Href, saved.Manifest[i].MediaType, expected)
+			}
+		}
+		if !strings.Contains(string(data), `<item id="ok" href="ch2.xhtml" media-type="application/xhtml+xml"/>`) {
+			t.Errorf("The opf was not preserved:\n%s", data)
+		}
+		return
+	}
+	t.Errorf("The saved epub has no opf")
+}
+
+// encodeUTF16 encodes the text as UTF-16 with the byte order mark bom
+func encodeUTF16(bom, text string) string {
+	var b strings.Builder
+	b.WriteString(bom)
+	for _, r := range text {
+		if bom == "\xff\xfe" {
+			b.WriteByte(byte(r))
+			b.WriteByte(byte(r >> 8))
+		} else {
+			b.WriteByte(byte(r >> 8))
+			b.WriteByte(byte(r))
+		}
+	}
+	return b.String()
+}
@@ -1,17 +1,81 @@
 package raw
 
 import (
+	"errors"
 	"io"
+	"strings"
 )
 
+// XmlNCX is the NCX file, the table of contents of EPUB 2
 type XmlNCX struct {
-	NavMap NavPointArray `xml:"navMap>navPoint"`
+	Version    string        `xml:"version,attr"`
+	Lang       string        `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	Head       []NCXMeta     `xml:"head>meta"`
+	DocTitle   NavLabel      `xml:"docTitle"`
+	DocAuthors []NavLabel    `xml:"docAuthor"`
+	NavMap     NavPointArray `xml:"navMap>navPoint"`
+	NavInfo    []NavLabel    `xml:"navMap>navInfo"`
+	PageList   []PageTarget  `xml:"pageList>pageTarget"`
+	NavLists   []NavList     `xml:"navList"`
 }
+
+// NCXMeta is a meta of the head of the NCX, like dtb:uid or dtb:depth
+type NCXMeta struct {
+	Name    string `xml:"name,attr"`
+	Content string `xml:"content,attr"`
+	Scheme  string `xml:"scheme,attr"`
+}
+
+// NavLabel is a label of the NCX on one language
+//
+// It's used for navLabel, navInfo, docTitle and docAuthor.
+type NavLabel struct {
+	Text string `xml:"text"`
+	Lang string `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	Dir  string `xml:"dir,attr"`
+}
+
 type NavPoint struct {
-	Text      string        `xml:"navLabel>text"`
+	ID        string        `xml:"id,attr"`
+	Class     string        `xml:"class,attr"`
+	PlayOrder string        `xml:"playOrder,attr"`
+	Labels    []NavLabel    `xml:"navLabel"`
 	Content   content       `xml:"content"`
 	NavPoints NavPointArray `xml:"navPoint"`
-	// Level     int
+	// Text is the first label
+	Text string `xml:"-"`
+	// Level is the depth of the point on the navMap, 1 for the top ones
+	Level int `xml:"-"`
+}
+
+// PageTarget is a page of the print edition on the pageList of the NCX
+type PageTarget struct {
+	ID        string     `xml:"id,attr"`
+	Class     string     `xml:"class,attr"`
+	Value     string     `xml:"value,attr"`
+	Type      string     `xml:"type,attr"`
+	PlayOrder string     `xml:"playOrder,attr"`
+	Labels    []NavLabel `xml:"navLabel"`
+	Content   content    `xml:"content"`
+}
+
+// NavList is a list of targets of the NCX, like the illustrations or the notes
+type NavList struct {
+	ID      string      `xml:"id,attr"`
+	Class   string      `xml:"class,attr"`
+	Labels  []NavLabel  `xml:"navLabel"`
+	NavInfo []NavLabel  `xml:"navInfo"`
+	Targets []NavTarget `xml:"navTarget"`
+}
+
+// NavTarget is a target of a navList
+type NavTarget struct {
+	ID        string     `xml:"id,attr"`
+	Class     string     `xml:"class,attr"`
+	Value     string     `xml:"value,attr"`
+	PlayOrder string     `xml:"playOrder,attr"`
+	Labels    []NavLabel `xml:"navLabel"`
+	Content   content    `xml:"content"`
 }
 
 type content struct {
@@ -79,9 +143,42 @@ func parseNCX(ncx io.Reader) (*XmlNCX, error) {
 		return nil, err
 	}
 
+	n.NavMap.setLevel(1)
 	return &n, nil
 }
 
+// setLevel sets the level of the points and the text of their first label
+func (nps NavPointArray) setLevel(level int) {
+	for _, np := range nps {
+		np.Level = level
+		if len(np.Labels) > 0 {
+			np.Text = np.Labels[0].Text
+		}
+		np.NavPoints.setLevel(level + 1)
+	}
+}
+
+// Meta returns the content of the meta name of the head, like "dtb:uid"
+func (ncx XmlNCX) Meta(name string) string {
+	for _, m := range ncx.Head {
+		if m.Name == name {
+			return strings.TrimSpace(m.Content)
+		}
+	}
+	return ""
+}
+
+// Depth returns the depth of the navMap
+func (ncx XmlNCX) Depth() int {
+	depth := 0
+	for _, np := range ncx.NavMap.flatten() {
+		if np.Level > depth {
+			depth = np.Level
+		}
+	}
+	return depth
+}
+
 func (ncx XmlNCX) navMap() NavPointArray {
 	return ncx.NavMap
 }
@@ -90,6 +187,39 @@ func (point *NavPoint) Title() string {
 	return point.Text
 }
 
+// Label returns the label of the point on the language lang, like "en" or
+// "pt-BR"
+//
+// If there is no label on the language it returns the first one.
+func (point NavPoint) Label(lang string) string {
+	return labelFor(point.Labels, lang)
+}
+
+// Title returns the label of the page
+func (page PageTarget) Title() string {
+	return labelFor(page.Labels, "")
+}
+
+// Title returns the label of the target
+func (target NavTarget) Title() string {
+	return labelFor(target.Labels, "")
+}
+
+// labelFor returns the text of the label on lang, or the first one
+func labelFor(labels []NavLabel, lang string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	lang = strings.ToLower(lang)
+	for _, label := range labels {
+		labelLang := strings.ToLower(label.Lang)
+		if lang != "" && (labelLang == lang || strings.HasPrefix(labelLang, lang+"-")) {
+			return label.Text
+		}
+	}
+	return labels[0].Text
+}
+
 // func (np *NavPoint) LevelTitle() string {
 // 	return strings.Repeat(" ", np.Level*4) + np.Text
 // }
@@ -101,3 +231,38 @@ func (point NavPoint) URL() string {
 func (point NavPoint) Children() NavPointArray {
 	return point.NavPoints
 }
+
+// flatten returns the navigation points of the tree in reading order
+func (nps NavPointArray) flatten() NavPointArray {
+	flat := NavPointArray{}
+	for _, np := range nps {
+		flat = append(flat, np)
+		flat = append(flat, np.NavPoints.flatten()...)
+	}
+	return flat
+}
+
+// NavPointHref returns the file and the fragment the navigation point links to
+//
+// The src of the NCX is relative to the NCX file, the returned file is
+// relative to the opf like the hrefs of the manifest.
+func (e Epub) NavPointHref(point *NavPoint) (string, string) {
+	file, fragment := splitFragment(point.URL())
+	if file == "" {
+		return "", fragment
+	}
+	return resolveHref(e.ncxPath, file), fragment
+}
+
+// CheckNCX checks that the NCX belongs to the package, the dtb:uid has to
+// match the unique identifier of the opf
+func (e Epub) CheckNCX() error {
+	if e.NCX == nil {
+		return errors.New("There is no NCX file on the epub")
+	}
+	uid := e.NCX.Meta("dtb:uid")
+	if uid != e.UniqueIdentifier() {
+		return errors.New("The dtb:uid of the NCX '" + uid + "' doesn't match the unique identifier '" + e.UniqueIdentifier() + "'")
+	}
+	return nil
+}
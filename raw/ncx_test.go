@@ -8,6 +8,7 @@ import "testing"
 
 import (
 	"os"
+	"strings"
 )
 
 const (
@@ -23,3 +24,81 @@ func TestNbspNcx(t *testing.T) {
 		t.Errorf("parseNCX(%v) with encoding problems return an error: %v", nbspNCX, err)
 	}
 }
+
+func TestParseNCX(t *testing.T) {
+	const ncx = `<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1" xml:lang="en">
+  <head>
+    <meta name="dtb:uid" content=" urn:isbn:123 "/>
+    <meta name="dtb:depth" content="2"/>
+  </head>
+  <docTitle><text>The title</text></docTitle>
+  <docAuthor><text>Author, The</text></docAuthor>
+  <navMap>
+    <navInfo><text>Contents</text></navInfo>
+    <navPoint id="np1" class="chapter" playOrder="1">
+      <navLabel><text>One</text></navLabel>
+      <navLabel xml:lang="es"><text>Uno</text></navLabel>
+      <content src="one.html"/>
+      <navPoint id="np2" playOrder="2">
+        <navLabel><text>One dot one</text></navLabel>
+        <content src="one.html#s1"/>
+      </navPoint>
+    </navPoint>
+  </navMap>
+  <pageList>
+    <pageTarget id="p1" type="normal" value="1" playOrder="3">
+      <navLabel><text>1</text></navLabel>
+      <content src="one.html#page1"/>
+    </pageTarget>
+  </pageList>
+  <navList class="lot">
+    <navLabel><text>Tables</text></navLabel>
+    <navTarget id="t1" playOrder="4">
+      <navLabel><text>Table 1</text></navLabel>
+      <content src="one.html#t1"/>
+    </navTarget>
+  </navList>
+</ncx>`
+
+	n, err := parseNCX(strings.NewReader(ncx))
+	if err != nil {
+		t.Fatalf("parseNCX() return an error: %v", err)
+	}
+	if n.Meta("dtb:uid") != "urn:isbn:123" || n.Lang != "en" || n.Depth() != 2 {
+		t.Errorf("uid: '%v', lang: '%v', depth: %d", n.Meta("dtb:uid"), n.Lang, n.Depth())
+	}
+	if n.DocTitle.Text != "The title" || len(n.DocAuthors) != 1 || n.NavInfo[0].Text != "Contents" {
+		t.Errorf("docTitle: %v, docAuthors: %v, navInfo: %v", n.DocTitle, n.DocAuthors, n.NavInfo)
+	}
+
+	point := n.NavMap[0]
+	if point.ID != "np1" || point.Class != "chapter" || point.PlayOrder != "1" || point.Level != 1 {
+		t.Errorf("The navPoint is %+v", point)
+	}
+	if point.Title() != "One" || point.Label("es") != "Uno" || point.Label("fr") != "One" {
+		t.Errorf("The labels are %v", point.Labels)
+	}
+	if child := point.NavPoints[0]; child.Level != 2 || child.Title() != "One dot one" {
+		t.Errorf("The child navPoint is %+v", child)
+	}
+	if len(n.PageList) != 1 || n.PageList[0].Title() != "1" || n.PageList[0].Value != "1" {
+		t.Errorf("The pageList is %+v", n.PageList)
+	}
+	if len(n.NavLists) != 1 || n.NavLists[0].Targets[0].Title() != "Table 1" || n.NavLists[0].Targets[0].Content.Src != "one.html#t1" {
+		t.Errorf("The navLists are %+v", n.NavLists)
+	}
+}
+
+func TestCheckNCX(t *testing.T) {
+	f, _ := NewEpub(bookPath)
+	defer f.Close()
+	if err := f.CheckNCX(); err != nil {
+		t.Errorf("CheckNCX() return an error: %v", err)
+	}
+
+	f.NCX.Head = []NCXMeta{{Name: "dtb:uid", Content: "other"}}
+	if err := f.CheckNCX(); err == nil {
+		t.Errorf("CheckNCX() didn't detect the wrong dtb:uid")
+	}
+}
@@ -0,0 +1,379 @@
+package raw
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// navEntry is an entry of a list of the EPUB 3 navigation document
+type navEntry struct {
+	label string
+	// file is relative to the opf, like the hrefs of the manifest
+	file      string
+	fragment  string
+	children  []*navEntry
+	playOrder int
+}
+
+// navHref returns the href of the EPUB 3 navigation document, or "" if
+// there is none
+func (e Epub) navHref() string {
+	for _, item := range e.opf.Manifest {
+		for _, property := range strings.Fields(item.Properties) {
+			if property == "nav" {
+				return item.Href
+			}
+		}
+	}
+	return ""
+}
+
+// parseNav parses the toc and page-list of the EPUB 3 navigation document
+func (e Epub) parseNav() (toc, pages []*navEntry, err error) {
+	href := e.navHref()
+	if href == "" {
+		return nil, nil, errors.New("There is no navigation document on the epub")
+	}
+	doc, err := e.ParseDocument(href)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "nav" {
+			list := findElement(n, "ol")
+			for _, navType := range strings.Fields(attr(n, "epub:type")) {
+				switch {
+				case navType == "toc" && toc == nil && list != nil:
+					toc = parseNavList(list, href)
+				case navType == "page-list" && pages == nil && list != nil:
+					pages = parseNavList(list, href)
+				}
+			}
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	if toc == nil {
+		return nil, nil, errors.New("The navigation document has no toc")
+	}
+	return toc, pages, nil
+}
+
+// parseNavList parses the items of an ol of the navigation document base
+func parseNavList(list *html.Node, base string) []*navEntry {
+	entries := []*navEntry{}
+	for li := list.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type != html.ElementNode || li.Data != "li" {
+			continue
+		}
+		entry := &navEntry{}
+		for c := li.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			switch c.Data {
+			case "a", "span":
+				entry.label = nodeText(c)
+				if href := attr(c, "href"); href != "" {
+					file, fragment := splitFragment(href)
+					if file == "" {
+						file = base
+					} else {
+						file = resolveHref(base, file)
+					}
+					entry.file, entry.fragment = file, fragment
+				}
+			case "ol":
+				entry.children = parseNavList(c, base)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// nodeText returns the text of the node with the whitespace collapsed
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// GenerateNCX generates an NCX 2005-1 from the EPUB 3 navigation document
+//
+// The playOrder follows the spine and ncxHref, relative to the opf, is
+// where the NCX is going to be placed.
+func (e Epub) GenerateNCX(ncxHref string) ([]byte, error) {
+	toc, pages, err := e.parseNav()
+	if err != nil {
+		return nil, err
+	}
+	toc = linkedEntries(toc)
+	if len(toc) == 0 {
+		return nil, errors.New("The toc of the navigation document has no links")
+	}
+	e.setPlayOrder(toc, pages)
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	b.WriteString(`<!DOCTYPE ncx PUBLIC "-//NISO//DTD ncx 2005-1//EN" "http://www.daisy.org/z3986/2005/ncx-2005-1.dtd">` + "\n")
+	b.WriteString(`<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1"`)
+	if len(e.opf.Metadata.Language) > 0 {
+		b.WriteString(` xml:lang="` + html.EscapeString(strings.TrimSpace(e.opf.Metadata.Language[0])) + `"`)
+	}
+	b.WriteString(">\n<head>\n")
+	writeNCXMeta(&b, "dtb:uid", e.UniqueIdentifier())
+	writeNCXMeta(&b, "dtb:depth", strconv.Itoa(navDepth(toc)))
+	maxPage := 0
+	for _, page := range pages {
+		if n, err := strconv.Atoi(page.label); err == nil && n > maxPage {
+			maxPage = n
+		}
+	}
+	writeNCXMeta(&b, "dtb:totalPageCount", strconv.Itoa(len(pages)))
+	writeNCXMeta(&b, "dtb:maxPageNumber", strconv.Itoa(maxPage))
+	b.WriteString("</head>\n")
+
+	title := ""
+	if len(e.opf.Metadata.Title) > 0 {
+		title = strings.TrimSpace(e.opf.Metadata.Title[0])
+	}
+	b.WriteString("<docTitle><text>" + html.EscapeString(title) + "</text></docTitle>\n")
+
+	count := 0
+	b.WriteString("<navMap>\n")
+	writeNavPoints(&b, toc, ncxHref, &count)
+	b.WriteString("</navMap>\n")
+
+	if len(pages) > 0 {
+		b.WriteString("<pageList>\n<navLabel><text>Pages</text></navLabel>\n")
+		for i, page := range pages {
+			if page.file == "" {
+				continue
+			}
+			pageType := "normal"
+			if _, err := strconv.Atoi(page.label); err != nil {
+				pageType = "front"
+			}
+			b.WriteString(`<pageTarget id="page-` + strconv.Itoa(i+1) + `" type="` + pageType + `"`)
+			if pageType == "normal" {
+				b.WriteString(` value="` + html.EscapeString(page.label) + `"`)
+			}
+			b.WriteString(` playOrder="` + strconv.Itoa(page.playOrder) + `">`)
+			b.WriteString("<navLabel><text>" + html.EscapeString(page.label) + "</text></navLabel>")
+			b.WriteString(`<content src="` + html.EscapeString(ncxSrc(ncxHref, page)) + `"/></pageTarget>` + "\n")
+		}
+		b.WriteString("</pageList>\n")
+	}
+	b.WriteString("</ncx>\n")
+	return []byte(b.String()), nil
+}
+
+func writeNCXMeta(b *strings.Builder, name, content string) {
+	b.WriteString(`<meta name="` + name + `" content="` + html.EscapeString(content) + `"/>` + "\n")
+}
+
+func writeNavPoints(b *strings.Builder, entries []*navEntry, ncxHref string, count *int) {
+	for _, entry := range entries {
+		*count++
+		b.WriteString(`<navPoint id="navPoint-` + strconv.Itoa(*count) + `" playOrder="` + strconv.Itoa(entry.playOrder) + `">` + "\n")
+		b.WriteString("<navLabel><text>" + html.EscapeString(entry.label) + "</text></navLabel>\n")
+		b.WriteString(`<content src="` + html.EscapeString(ncxSrc(ncxHref, entry)) + `"/>` + "\n")
+		writeNavPoints(b, entry.children, ncxHref, count)
+		b.WriteString("</navPoint>\n")
+	}
+}
+
+// ncxSrc returns the src of the entry relative to the NCX
+func ncxSrc(ncxHref string, entry *navEntry) string {
+	return escapeHref(relativeHref(cleanHref(ncxHref), entry.file), entry.fragment)
+}
+
+// relativeHref returns the path of target relative to the folder of base,
+// both relative to the same folder
+func relativeHref(base, target string) string {
+	dir := strings.Split(path.Dir(base), "/")
+	if dir[0] == "." {
+		dir = nil
+	}
+	parts := strings.Split(path.Clean(target), "/")
+	common := 0
+	for common < len(dir) && common < len(parts)-1 && dir[common] == parts[common] {
+		common++
+	}
+	rel := strings.Repeat("../", len(dir)-common)
+	return rel + strings.Join(parts[common:], "/")
+}
+
+// linkedEntries removes the entries without link, the NCX needs a content
+// on each navPoint
+//
+// The entries without link take the link of their first child, if there
+// are no children they are dropped.
+func linkedEntries(entries []*navEntry) []*navEntry {
+	linked := []*navEntry{}
+	for _, entry := range entries {
+		entry.children = linkedEntries(entry.children)
+		if entry.file == "" {
+			if len(entry.children) == 0 {
+				continue
+			}
+			entry.file = entry.children[0].file
+			entry.fragment = entry.children[0].fragment
+		}
+		linked = append(linked, entry)
+	}
+	return linked
+}
+
+func navDepth(entries []*navEntry) int {
+	depth := 0
+	for _, entry := range entries {
+		if d := navDepth(entry.children) + 1; d > depth {
+			depth = d
+		}
+	}
+	return depth
+}
+
+// setPlayOrder numbers the entries on the reading order of the spine
+//
+// Entries pointing to the same place share the playOrder.
+func (e Epub) setPlayOrder(toc, pages []*navEntry) {
+	type target struct {
+		entry  *navEntry
+		spine  int
+		offset int
+	}
+	texts := make(map[string]*DocumentText)
+	targets := []target{}
+	var add func(entries []*navEntry)
+	add = func(entries []*navEntry) {
+		for _, entry := range entries {
+			if entry.file != "" {
+				t := target{entry: entry, spine: e.opf.spineIndex(entry.file)}
+				if t.spine < 0 {
+					t.spine = e.opf.spineLength()
+				}
+				if entry.fragment != "" {
+					text, ok := texts[entry.file]
+					if !ok {
+						text, _ = e.DocumentText(entry.file)
+						texts[entry.file] = text
+					}
+					if text != nil {
+						t.offset, _ = text.AnchorOffset(entry.fragment)
+					}
+				}
+				targets = append(targets, t)
+			}
+			add(entry.children)
+		}
+	}
+	add(toc)
+	add(pages)
+
+	sort.SliceStable(targets, func(i, j int) bool {
+		if targets[i].spine != targets[j].spine {
+			return targets[i].spine < targets[j].spine
+		}
+		return targets[i].offset < targets[j].offset
+	})
+	playOrder := 0
+	for i, t := range targets {
+		prev := targets[max(i-1, 0)].entry
+		if i == 0 || cleanHref(prev.file) != cleanHref(t.entry.file) || prev.fragment != t.entry.fragment {
+			playOrder++
+		}
+		t.entry.playOrder = playOrder
+	}
+}
+
+// AddNCXFile writes on the file path the epub with an NCX generated from
+// the EPUB 3 navigation document
+func (e Epub) AddNCXFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := e.AddNCX(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// AddNCX writes on w the epub with an NCX generated from the EPUB 3
+// navigation document
+//
+// The NCX is registered on the manifest and the toc attribute of the
+// spine. If the epub has already an NCX it gets replaced.
+func (e Epub) AddNCX(w io.Writer) error {
+	opfPath, err := e.getOpfPath()
+	if err != nil {
+		return err
+	}
+	f, err := e.reader.OpenFile(opfPath)
+	if err != nil {
+		return err
+	}
+	doc, err := parseXMLTree(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	pkg := doc.root()
+	if pkg == nil || pkg.element("manifest") == nil || pkg.element("spine") == nil {
+		return errors.New("The opf file has no manifest or spine")
+	}
+
+	ncxHref := e.ncxPath
+	ncxID := ""
+	for _, item := range pkg.element("manifest").elements("item") {
+		if ncxHref != "" && item.attr("href") == ncxHref {
+			ncxID = item.attr("id")
+		}
+	}
+	if ncxID == "" {
+		ncxHref = e.newFileName("toc.ncx")
+		ncxID = newIDSet(doc).unique("ncx")
+		item := newXMLElement(qualifiedName(xml.Name{Space: pkg.name.Space, Local: "item"}),
+			"id", ncxID,
+			"href", ncxHref,
+			"media-type", "application/x-dtbncx+xml")
+		pkg.element("manifest").appendIndented(item)
+	}
+	pkg.element("spine").setAttr("toc", ncxID)
+
+	ncx, err := e.GenerateNCX(ncxHref)
+	if err != nil {
+		return err
+	}
+	changes := map[string][]byte{
+		opfPath:                        doc.bytes(),
+		path.Join(e.rootPath, ncxHref): ncx,
+	}
+	return e.writeEpub(w, changes)
+}
@@ -0,0 +1,117 @@
+package raw
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAddNCX(t *testing.T) {
+	f, _ := NewEpub(bookPath)
+	defer f.Close()
+
+	dir := t.TempDir()
+	upgradedPath := filepath.Join(dir, "upgraded.epub")
+	if err := f.UpgradeFile(upgradedPath); err != nil {
+		t.Fatalf("UpgradeFile() return an error: %v", err)
+	}
+	upgraded, _ := NewEpub(upgradedPath)
+	defer upgraded.Close()
+
+	ncx, err := upgraded.GenerateNCX("toc.ncx")
+	if err != nil {
+		t.Fatalf("GenerateNCX() return an error: %v", err)
+	}
+	for _, meta := range []string{
+		`<meta name="dtb:uid" content="http://www.gutenberg.org/ebooks/3174"/>`,
+		`<meta name="dtb:depth" content="3"/>`,
+	} {
+		if !strings.Contains(string(ncx), meta) {
+			t.Errorf("The NCX has no %v", meta)
+		}
+	}
+
+	withNCX := filepath.Join(dir, "ncx.epub")
+	if err := upgraded.AddNCXFile(withNCX); err != nil {
+		t.Fatalf("AddNCXFile() return an error: %v", err)
+	}
+	e, err := NewEpub(withNCX)
+	if err != nil {
+		t.Fatalf("Can't open the epub with the NCX: %v", err)
+	}
+	defer e.Close()
+
+	expected := f.NavPoints().flatten()
+	points := e.NavPoints().flatten()
+	if len(points) != len(expected) {
+		t.Fatalf("The NCX has %d navPoints, the expected was %d", len(points), len(expected))
+	}
+	for i, point := range points {
+		file, fragment := e.NavPointHref(point)
+		expectedFile, expectedFragment := f.NavPointHref(expected[i])
+		if point.Title() != expected[i].Title() || file != expectedFile || fragment != expectedFragment {
+			t.Errorf("navPoint %d is '%v' %v#%v", i, point.Title(), file, fragment)
+		}
+	}
+}
+
+func TestGenerateNCXPageList(t *testing.T) {
+	const nav = `<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops"><body>
+<nav epub:type="toc"><ol>
+  <li><span>Part</span><ol>
+    <li><a href="../text/b.xhtml#s1">Second</a></li>
+    <li><a href="../text/a.xhtml">First</a></li>
+  </ol></li>
+</ol></nav>
+<nav epub:type="page-list"><ol>
+  <li><a href="../text/a.xhtml#p1">1</a></li>
+  <li><a href="../text/b.xhtml#p2">2</a></li>
+</ol></nav>
+</body></html>`
+	const chapter = `<html xmlns="http://www.w3.org/1999/xhtml"><body><p id="p1">one</p><p id="s1">two</p><p id="p2">three</p></body></html>`
+
+	e := Epub{
+		rootPath: "OEBPS/",
+		opf: &xmlOPF{
+			Metadata: meta{Title: []string{"Book"}, Identifier: []identifier{{Data: "uid"}}},
+			Manifest: []*manifest{
+				{ID: "nav", Href: "nav/nav.xhtml", MediaType: "application/xhtml+xml", Properties: "nav"},
+				{ID: "a", Href: "text/a.xhtml", MediaType: "application/xhtml+xml"},
+				{ID: "b", Href: "text/b.xhtml", MediaType: "application/xhtml+xml"},
+			},
+			Spine: spine{Items: []spineItem{{IDref: "a"}, {IDref: "b"}}},
+		},
+		reader: memReader{
+			"OEBPS/nav/nav.xhtml": []byte(nav),
+			"OEBPS/text/a.xhtml":  []byte(chapter),
+			"OEBPS/text/b.xhtml":  []byte(chapter),
+		},
+	}
+
+	ncx, err := e.GenerateNCX("toc.ncx")
+	if err != nil {
+		t.Fatalf("GenerateNCX() return an error: %v", err)
+	}
+	parsed, err := parseNCX(strings.NewReader(string(ncx)))
+	if err != nil {
+		t.Fatalf("The NCX can't be parsed: %v", err)
+	}
+	if len(parsed.NavMap) != 1 || parsed.NavMap[0].URL() != "text/b.xhtml#s1" || len(parsed.NavMap[0].NavPoints) != 2 {
+		t.Errorf("The navMap is %+v", parsed.NavMap)
+	}
+	if len(parsed.PageList) != 2 || parsed.PageList[1].Value != "2" || parsed.PageList[1].Content.Src != "text/b.xhtml#p2" {
+		t.Errorf("The pageList is %+v", parsed.PageList)
+	}
+
+	for _, playOrder := range []string{
+		`<navPoint id="navPoint-1" playOrder="3">`,
+		`<navPoint id="navPoint-2" playOrder="3">`,
+		`<navPoint id="navPoint-3" playOrder="1">`,
+		`<pageTarget id="page-1" type="normal" value="1" playOrder="2">`,
+		`<pageTarget id="page-2" type="normal" value="2" playOrder="4">`,
+	} {
+		if !strings.Contains(string(ncx), playOrder) {
+			t.Errorf("The NCX has no %v:\n%s", playOrder, ncx)
+		}
+	}
+}
@@ -18,9 +18,12 @@ Give correct mime-type in media-type attribute. ids are required and must be uni
 */
 
 type xmlOPF struct {
-	Metadata meta        `xml:"metadata"`
-	Manifest []*manifest `xml:"manifest>item"`
-	Spine    spine       `xml:"spine"`
+	Version          string      `xml:"version,attr"`
+	UniqueIdentifier string      `xml:"unique-identifier,attr"`
+	Metadata         meta        `xml:"metadata"`
+	Manifest         []*manifest `xml:"manifest>item"`
+	Spine            spine       `xml:"spine"`
+	Guide            []reference `xml:"guide>reference"`
 }
 type meta struct {
 	Title       []string     `xml:"title"`
@@ -39,6 +42,7 @@ type meta struct {
 	Coverage    []string     `xml:"coverage"`
 	Rights      []string     `xml:"rights"`
 	Meta        []metafield  `xml:"meta"`
+	Link        []metalink   `xml:"link"`
 }
 type identifier struct {
 	Data   string `xml:",chardata"`
@@ -49,23 +53,39 @@ type author struct {
 	Data   string `xml:",chardata"`
 	FileAs string `xml:"file-as,attr"`
 	Role   string `xml:"role,attr"`
+	ID     string `xml:"id,attr"`
 }
 type date struct {
-	// TODO: convert date to date type?
 	Data  string `xml:",chardata"`
 	Event string `xml:"event,attr"`
+	ID    string `xml:"id,attr"`
 }
 type metafield struct {
 	Name    string `xml:"name,attr"`
 	Content string `xml:"content,attr"`
+	// EPUB 3 metas have the value as text
+	Data     string `xml:",chardata"`
+	Property string `xml:"property,attr"`
+	Refines  string `xml:"refines,attr"`
+	ID       string `xml:"id,attr"`
+	Scheme   string `xml:"scheme,attr"`
+}
+type metalink struct {
+	Rel        string `xml:"rel,attr"`
+	Href       string `xml:"href,attr"`
+	Refines    string `xml:"refines,attr"`
+	MediaType  string `xml:"media-type,attr"`
+	Properties string `xml:"properties,attr"`
 }
 type manifest struct {
-	ID           string `xml:"id,attr"`
-	Href         string `xml:"href,attr"`
-	MediaType    string `xml:"media-type,attr"`
-	Fallback     string `xml:"media-fallback,attr"`
-	Properties   string `xml:"properties,attr"`
-	MediaOverlay string `xml:"media-overlay,attr"`
+	ID            string `xml:"id,attr"`
+	Href          string `xml:"href,attr"`
+	MediaType     string `xml:"media-type,attr"`
+	Fallback      string `xml:"media-fallback,attr"`
+	ItemFallback  string `xml:"fallback,attr"` // Fallback holds media-fallback
+	FallbackStyle string `xml:"fallback-style,attr"`
+	Properties    string `xml:"properties,attr"`
+	MediaOverlay  string `xml:"media-overlay,attr"`
 }
 type spine struct {
 	ID              string      `xml:"id,attr"`
@@ -73,6 +93,11 @@ type spine struct {
 	PageProgression string      `xml:"page-progression-direction,attr"`
 	Items           []spineItem `xml:"itemref"`
 }
+type reference struct {
+	Type  string `xml:"type,attr"`
+	Title string `xml:"title,attr"`
+	Href  string `xml:"href,attr"`
+}
 type spineItem struct {
 	IDref      string `xml:"idref,attr"`
 	Linear     string `xml:"linear,attr"`
@@ -156,8 +181,21 @@ func elementToMData(element interface{}) (result MdataElement) {
 	case metafield:
 		m, _ := element.(metafield)
 		result.content = m.Content
+		if m.Property != "" {
+			result.attr["property"] = m.Property
+			result.attr["refines"] = m.Refines
+			result.attr["id"] = m.ID
+			result.attr["scheme"] = m.Scheme
+		}
 		result.attr["name"] = m.Name
 		result.attr["content"] = m.Content
+	case metalink:
+		l, _ := element.(metalink)
+		result.content = l.Href
+		result.attr["rel"] = l.Rel
+		result.attr["refines"] = l.Refines
+		result.attr["media-type"] = l.MediaType
+		result.attr["properties"] = l.Properties
 	}
 	return
 }
@@ -180,3 +218,39 @@ func (opf xmlOPF) getURL(id string) (string, error) {
 	}
 	return "", errors.New("ID " + id + " not in the manifest")
 }
+
+// spineIndex returns the position on the spine of the file href, or -1
+func (opf xmlOPF) spineIndex(href string) int {
+	href = cleanHref(href)
+	for i := range opf.Spine.Items {
+		if cleanHref(opf.spineURL(i)) == href {
+			return i
+		}
+	}
+	return -1
+}
+
+// spineItem returns the manifest item on the position index of the spine
+func (opf xmlOPF) spineItem(index int) *manifest {
+	idref := opf.Spine.Items[index].IDref
+	for _, item := range opf.Manifest {
+		if item.ID == idref {
+			return item
+		}
+	}
+	return nil
+}
+
+// uniqueIdentifier returns the identifier referenced by the unique-identifier
+// attribute of the package, or the first identifier if it's missing
+func (opf xmlOPF) uniqueIdentifier() string {
+	for _, ident := range opf.Metadata.Identifier {
+		if ident.ID == opf.UniqueIdentifier {
+			return strings.TrimSpace(ident.Data)
+		}
+	}
+	if len(opf.Metadata.Identifier) > 0 {
+		return strings.TrimSpace(opf.Metadata.Identifier[0].Data)
+	}
+	return ""
+}
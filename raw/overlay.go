@@ -0,0 +1,250 @@
+package raw
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+// MediaOverlay is the EPUB 3 media overlay of a document of the spine, the
+// audio clips that read aloud its text
+type MediaOverlay struct {
+	SpineIndex int
+	// Document is the href of the content document and Href the one of the
+	// SMIL file, both relative to the opf
+	Document string
+	Href     string
+	// Duration is the media:duration of the overlay, 0 if it's missing
+	Duration time.Duration
+	Clips    []Clip
+}
+
+// Clip is a fragment of the text synchronized with a clip of audio
+type Clip struct {
+	// Fragment is the id of the element on the text document
+	Fragment string
+	// Audio is the href of the audio file relative to the opf
+	Audio string
+	Begin time.Duration
+	// End is 0 if the clip goes until the end of the audio file
+	End time.Duration
+}
+
+// MediaOverlays returns the media overlays of the documents of the spine,
+// in the order of the spine
+func (e Epub) MediaOverlays() ([]*MediaOverlay, error) {
+	overlays := []*MediaOverlay{}
+	for i := 0; i < e.opf.spineLength(); i++ {
+		overlay, err := e.MediaOverlay(i)
+		if err != nil {
+			return nil, err
+		}
+		if overlay != nil {
+			overlays = append(overlays, overlay)
+		}
+	}
+	return overlays, nil
+}
+
+// MediaOverlay returns the media overlay of the document on the position
+// index of the spine, or nil if it has none
+func (e Epub) MediaOverlay(index int) (*MediaOverlay, error) {
+	if index < 0 || index >= e.opf.spineLength() {
+		return nil, errors.New("Spine index " + strconv.Itoa(index) + " out of range")
+	}
+	item := e.opf.spineItem(index)
+	if item == nil || item.MediaOverlay == "" {
+		return nil, nil
+	}
+	smilHref := e.opf.filePath(item.MediaOverlay)
+	if smilHref == "" {
+		return nil, errors.New("Media overlay " + item.MediaOverlay + " not in the manifest")
+	}
+
+	f, err := e.OpenFile(smilHref)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	clips, err := parseSMIL(f, smilHref, item.Href)
+	if err != nil {
+		return nil, err
+	}
+	overlay := &MediaOverlay{
+		SpineIndex: index,
+		Document:   item.Href,
+		Href:       smilHref,
+		Clips:      clips,
+	}
+	if duration := e.mediaMeta("media:duration", "#"+item.MediaOverlay); duration != "" {
+		overlay.Duration, _ = parseClockValue(duration)
+	}
+	return overlay, nil
+}
+
+// MediaDuration returns the media:duration of the whole publication, 0 if
+// it's missing
+func (e Epub) MediaDuration() time.Duration {
+	duration, _ := parseClockValue(e.mediaMeta("media:duration", ""))
+	return duration
+}
+
+// MediaActiveClass returns the media:active-class of the metadata, the css
+// class for the element being read aloud
+func (e Epub) MediaActiveClass() string {
+	return e.mediaMeta("media:active-class", "")
+}
+
+// mediaMeta returns the value of the meta with the property that refines
+// the element refines, "" for the ones about the publication
+func (e Epub) mediaMeta(property, refines string) string {
+	for _, m := range e.opf.Metadata.Meta {
+		if m.Property == property && m.Refines == refines {
+			return strings.TrimSpace(m.Data)
+		}
+	}
+	return ""
+}
+
+// ClipAt returns the clip of the audio file playing at the timestamp t
+func (o MediaOverlay) ClipAt(audio string, t time.Duration) (Clip, bool) {
+	audio = cleanHref(audio)
+	for _, clip := range o.Clips {
+		if cleanHref(clip.Audio) != audio || t < clip.Begin {
+			continue
+		}
+		if clip.End == 0 || t < clip.End {
+			return clip, true
+		}
+	}
+	return Clip{}, false
+}
+
+// ClipFor returns the first clip that reads the element of the document
+// with the id fragment
+func (o MediaOverlay) ClipFor(fragment string) (Clip, bool) {
+	for _, clip := range o.Clips {
+		if clip.Fragment == fragment {
+			return clip, true
+		}
+	}
+	return Clip{}, false
+}
+
+// parseSMIL returns the clips of the par elements of the SMIL file href
+// that point to the document
+func parseSMIL(r io.Reader, href, document string) ([]Clip, error) {
+	decoder := xml.NewDecoder(r)
+	decoder.Entity = xml.HTMLEntity
+	decoder.CharsetReader = charset.NewReaderLabel
+
+	clips := []Clip{}
+	var clip *Clip
+	var textFile string
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "par":
+				clip = &Clip{}
+				textFile = ""
+			case "text":
+				if clip == nil {
+					continue
+				}
+				file, fragment := splitFragment(xmlAttr(t, "src"))
+				if file != "" {
+					textFile = resolveHref(href, file)
+				}
+				clip.Fragment = fragment
+			case "audio":
+				if clip == nil {
+					continue
+				}
+				if src := xmlAttr(t, "src"); src != "" {
+					clip.Audio = resolveHref(href, src)
+				}
+				if clip.Begin, err = parseClockValue(xmlAttr(t, "clipBegin")); err != nil {
+					return nil, err
+				}
+				if clip.End, err = parseClockValue(xmlAttr(t, "clipEnd")); err != nil {
+					return nil, err
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Local != "par" || clip == nil {
+				continue
+			}
+			if textFile == "" || cleanHref(textFile) == cleanHref(document) {
+				clips = append(clips, *clip)
+			}
+			clip = nil
+		}
+	}
+	return clips, nil
+}
+
+func xmlAttr(el xml.StartElement, name string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// parseClockValue parses a SMIL clock value, like "0:01:02.5", "01:02.5",
+// "2.5s", "300ms", "1.5min", "1h" or "62.5"
+//
+// An empty value is 0.
+func parseClockValue(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	if strings.Contains(value, ":") {
+		parts := strings.Split(value, ":")
+		if len(parts) > 3 {
+			return 0, errors.New("Invalid clock value " + value)
+		}
+		seconds := 0.0
+		for _, part := range parts {
+			n, err := strconv.ParseFloat(part, 64)
+			if err != nil || n < 0 {
+				return 0, errors.New("Invalid clock value " + value)
+			}
+			seconds = seconds*60 + n
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+
+	unit := time.Second
+	for _, suffix := range []struct {
+		name string
+		unit time.Duration
+	}{{"ms", time.Millisecond}, {"min", time.Minute}, {"h", time.Hour}, {"s", time.Second}} {
+		if strings.HasSuffix(value, suffix.name) {
+			value = strings.TrimSuffix(value, suffix.name)
+			unit = suffix.unit
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, errors.New("Invalid clock value " + value)
+	}
+	return time.Duration(n * float64(unit)), nil
+}
@@ -0,0 +1,112 @@
+package raw
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMediaOverlay(t *testing.T) {
+	const smil = `<?xml version="1.0" encoding="UTF-8"?>
+<smil xmlns="http://www.w3.org/ns/SMIL" xmlns:epub="http://www.idpf.org/2007/ops" version="3.0">
+  <body>
+    <seq epub:textref="../text/ch1.xhtml">
+      <par id="p1">
+        <text src="../text/ch1.xhtml#s1"/>
+        <audio src="../audio/ch1.mp3" clipBegin="0:00:00.000" clipEnd="0:00:02.500"/>
+      </par>
+      <seq>
+        <par id="p2">
+          <text src="../text/ch1.xhtml#s2"/>
+          <audio src="../audio/ch1.mp3" clipBegin="2.5s" clipEnd="00:04.25"/>
+        </par>
+      </seq>
+      <par id="p3">
+        <text src="../text/ch1.xhtml#s3"/>
+        <audio src="../audio/ch1.mp3" clipBegin="4250ms"/>
+      </par>
+    </seq>
+  </body>
+</smil>`
+
+	e := Epub{
+		opf: &xmlOPF{
+			Metadata: meta{Meta: []metafield{
+				{Property: "media:duration", Data: "0:00:10"},
+				{Property: "media:duration", Refines: "#ch1_overlay", Data: "0:00:06.5"},
+				{Property: "media:active-class", Data: "-epub-media-overlay-active"},
+			}},
+			Manifest: []*manifest{
+				{ID: "ch1", Href: "text/ch1.xhtml", MediaType: "application/xhtml+xml", MediaOverlay: "ch1_overlay"},
+				{ID: "ch1_overlay", Href: "smil/ch1.smil", MediaType: "application/smil+xml"},
+				{ID: "ch2", Href: "text/ch2.xhtml", MediaType: "application/xhtml+xml"},
+			},
+			Spine: spine{Items: []spineItem{{IDref: "ch1"}, {IDref: "ch2"}}},
+		},
+		reader: memReader{"smil/ch1.smil": []byte(smil)},
+	}
+
+	if e.MediaDuration() != 10*time.Second || e.MediaActiveClass() != "-epub-media-overlay-active" {
+		t.Errorf("MediaDuration() = %v, MediaActiveClass() = '%v'", e.MediaDuration(), e.MediaActiveClass())
+	}
+	overlays, err := e.MediaOverlays()
+	if err != nil {
+		t.Fatalf("MediaOverlays() return an error: %v", err)
+	}
+	if len(overlays) != 1 {
+		t.Fatalf("MediaOverlays() returned %d overlays", len(overlays))
+	}
+	overlay := overlays[0]
+	if overlay.SpineIndex != 0 || overlay.Document != "text/ch1.xhtml" || overlay.Duration != 6500*time.Millisecond {
+		t.Errorf("The overlay is %+v", overlay)
+	}
+
+	expected := []Clip{
+		{"s1", "audio/ch1.mp3", 0, 2500 * time.Millisecond},
+		{"s2", "audio/ch1.mp3", 2500 * time.Millisecond, 4250 * time.Millisecond},
+		{"s3", "audio/ch1.mp3", 4250 * time.Millisecond, 0},
+	}
+	if len(overlay.Clips) != len(expected) {
+		t.Fatalf("The clips are %+v", overlay.Clips)
+	}
+	for i, clip := range overlay.Clips {
+		if clip != expected[i] {
+			t.Errorf("Clip %d is %+v, the expected was %+v", i, clip, expected[i])
+		}
+	}
+
+	if clip, ok := overlay.ClipAt("audio/ch1.mp3", 3*time.Second); !ok || clip.Fragment != "s2" {
+		t.Errorf("ClipAt(3s) returned %+v", clip)
+	}
+	if clip, ok := overlay.ClipAt("audio/ch1.mp3", time.Minute); !ok || clip.Fragment != "s3" {
+		t.Errorf("ClipAt(1min) returned %+v", clip)
+	}
+	if _, ok := overlay.ClipAt("audio/ch2.mp3", 0); ok {
+		t.Errorf("ClipAt() found a clip on other audio file")
+	}
+	if clip, ok := overlay.ClipFor("s2"); !ok || clip.Begin != 2500*time.Millisecond {
+		t.Errorf("ClipFor(s2) returned %+v", clip)
+	}
+}
+
+func TestParseClockValue(t *testing.T) {
+	tests := map[string]time.Duration{
+		"":          0,
+		"1:02:03.5": time.Hour + 2*time.Minute + 3500*time.Millisecond,
+		"02:03":     2*time.Minute + 3*time.Second,
+		"3.2s":      3200 * time.Millisecond,
+		"45ms":      45 * time.Millisecond,
+		"1.5min":    90 * time.Second,
+		"2h":        2 * time.Hour,
+		"12.25":     12250 * time.Millisecond,
+	}
+	for value, expected := range tests {
+		if d, err := parseClockValue(value); err != nil || d != expected {
+			t.Errorf("parseClockValue(%q) = %v, %v, the expected was %v", value, d, err, expected)
+		}
+	}
+	for _, value := range []string{"abc", "1:2:3:4", "-2s"} {
+		if _, err := parseClockValue(value); err == nil {
+			t.Errorf("parseClockValue(%q) didn't fail", value)
+		}
+	}
+}
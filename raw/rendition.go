@@ -0,0 +1,178 @@
+package raw
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Rendition are the properties of how the book, or a page of it, is laid out
+type Rendition struct {
+	// Layout is "reflowable" or "pre-paginated" for fixed layout
+	Layout string
+	// Orientation is "auto", "landscape" or "portrait"
+	Orientation string
+	// Spread is "auto", "none", "landscape", "portrait" or "both"
+	Spread string
+	// Flow is "auto", "paginated", "scrolled-continuous" or "scrolled-doc"
+	Flow string
+	// PageSpread is "left", "right", "center" or "" on the pages, and
+	// always "" on the book
+	PageSpread string
+	// Viewport is the size of the page from its viewport meta, 0 if unknown
+	Viewport Viewport
+}
+
+// Viewport is the size in css pixels of a fixed layout page
+type Viewport struct {
+	Width  int
+	Height int
+}
+
+// IsFixedLayout returns whether the layout is pre-paginated
+func (r Rendition) IsFixedLayout() bool {
+	return r.Layout == "pre-paginated"
+}
+
+type displayOptionsXML struct {
+	Platforms []struct {
+		Name    string `xml:"name,attr"`
+		Options []struct {
+			Name  string `xml:"name,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"option"`
+	} `xml:"platform"`
+}
+
+// Rendition returns the rendition properties of the book
+//
+// They come from the rendition metas of EPUB 3, or from the Apple display
+// options and the fixed-layout metas used by Kobo and Amazon on the older
+// books.
+func (e Epub) Rendition() Rendition {
+	r := Rendition{Layout: "reflowable", Orientation: "auto", Spread: "auto", Flow: "auto"}
+
+	// Apple iBooks
+	if f, err := e.reader.OpenFile("META-INF/com.apple.ibooks.display-options.xml"); err == nil {
+		var options displayOptionsXML
+		if decodeXML(f, &options) == nil {
+			for _, platform := range options.Platforms {
+				for _, option := range platform.Options {
+					setLegacyRendition(&r, option.Name, option.Value)
+				}
+			}
+		}
+		f.Close()
+	}
+
+	// Kobo and Amazon
+	for _, m := range e.opf.Metadata.Meta {
+		if m.Name != "" {
+			setLegacyRendition(&r, m.Name, m.Content)
+		}
+	}
+
+	for _, m := range e.opf.Metadata.Meta {
+		if m.Refines != "" || !strings.HasPrefix(m.Property, "rendition:") {
+			continue
+		}
+		value := strings.TrimSpace(m.Data)
+		switch m.Property {
+		case "rendition:layout":
+			r.Layout = value
+		case "rendition:orientation":
+			r.Orientation = value
+		case "rendition:spread":
+			if value == "portrait" {
+				// deprecated, it's the same than both
+				value = "both"
+			}
+			r.Spread = value
+		case "rendition:flow":
+			r.Flow = value
+		}
+	}
+	return r
+}
+
+// setLegacyRendition sets the rendition from the option name of the Apple
+// display options or a meta name of Kobo or Amazon
+func setLegacyRendition(r *Rendition, name, value string) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch name {
+	case "fixed-layout":
+		if value == "true" {
+			r.Layout = "pre-paginated"
+		}
+	case "orientation-lock":
+		switch value {
+		case "landscape-only", "landscape":
+			r.Orientation = "landscape"
+		case "portrait-only", "portrait":
+			r.Orientation = "portrait"
+		}
+	}
+}
+
+// PageRendition returns the rendition properties of the document on the
+// position index of the spine
+//
+// The properties of the itemref override the ones of the book, and the
+// viewport comes from the meta of the document.
+func (e Epub) PageRendition(index int) (Rendition, error) {
+	if index < 0 || index >= e.opf.spineLength() {
+		return Rendition{}, errors.New("Spine index " + strconv.Itoa(index) + " out of range")
+	}
+	r := e.Rendition()
+	for _, property := range strings.Fields(e.opf.Spine.Items[index].Properties) {
+		property = strings.TrimPrefix(property, "rendition:")
+		switch {
+		case strings.HasPrefix(property, "layout-"):
+			r.Layout = strings.TrimPrefix(property, "layout-")
+		case strings.HasPrefix(property, "orientation-"):
+			r.Orientation = strings.TrimPrefix(property, "orientation-")
+		case strings.HasPrefix(property, "spread-"):
+			r.Spread = strings.TrimPrefix(property, "spread-")
+		case strings.HasPrefix(property, "flow-"):
+			r.Flow = strings.TrimPrefix(property, "flow-")
+		case strings.HasPrefix(property, "page-spread-"):
+			r.PageSpread = strings.TrimPrefix(property, "page-spread-")
+		}
+	}
+
+	if item := e.opf.spineItem(index); item != nil && isTextContent(item.MediaType) {
+		if doc, err := e.ParseDocument(item.Href); err == nil {
+			if head := findElement(doc, "head"); head != nil {
+				for c := head.FirstChild; c != nil; c = c.NextSibling {
+					if c.Data == "meta" && strings.ToLower(attr(c, "name")) == "viewport" {
+						r.Viewport = parseViewport(attr(c, "content"))
+					}
+				}
+			}
+		}
+	}
+	return r, nil
+}
+
+// parseViewport parses the content of a viewport meta, like
+// "width=1024, height=768"
+func parseViewport(content string) Viewport {
+	var v Viewport
+	for _, field := range strings.FieldsFunc(content, func(r rune) bool { return r == ',' || r == ';' }) {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(kv[1]), "px"))
+		if err != nil {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(kv[0])) {
+		case "width":
+			v.Width = n
+		case "height":
+			v.Height = n
+		}
+	}
+	return v
+}
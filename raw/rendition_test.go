@@ -0,0 +1,76 @@
+package raw
+
+import "testing"
+
+func TestRenditionReflowable(t *testing.T) {
+	f, _ := NewEpub(bookPath)
+	defer f.Close()
+
+	r := f.Rendition()
+	if r.IsFixedLayout() || r.Orientation != "auto" || r.Spread != "auto" || r.Flow != "auto" {
+		t.Errorf("The rendition is %+v", r)
+	}
+}
+
+func TestRenditionFixedLayout(t *testing.T) {
+	const page = `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml"><head>
+<meta name="viewport" content="width=1200, height=1600"/>
+</head><body><img src="p1.jpg"/></body></html>`
+
+	e := Epub{
+		opf: &xmlOPF{
+			Metadata: meta{Meta: []metafield{
+				{Property: "rendition:layout", Data: "pre-paginated"},
+				{Property: "rendition:spread", Data: "landscape"},
+				{Name: "orientation-lock", Content: "portrait"},
+			}},
+			Manifest: []*manifest{
+				{ID: "p1", Href: "p1.xhtml", MediaType: "application/xhtml+xml"},
+				{ID: "p2", Href: "p2.xhtml", MediaType: "application/xhtml+xml"},
+			},
+			Spine: spine{Items: []spineItem{
+				{IDref: "p1", Properties: "rendition:page-spread-center"},
+				{IDref: "p2", Properties: "page-spread-left rendition:layout-reflowable"},
+			}},
+		},
+		reader: memReader{"p1.xhtml": []byte(page)},
+	}
+
+	r := e.Rendition()
+	if !r.IsFixedLayout() || r.Spread != "landscape" || r.Orientation != "portrait" || r.PageSpread != "" {
+		t.Errorf("The rendition of the book is %+v", r)
+	}
+	r, err := e.PageRendition(0)
+	if err != nil {
+		t.Fatalf("PageRendition() return an error: %v", err)
+	}
+	if !r.IsFixedLayout() || r.PageSpread != "center" || r.Viewport != (Viewport{1200, 1600}) {
+		t.Errorf("The rendition of the first page is %+v", r)
+	}
+	r, _ = e.PageRendition(1)
+	if r.IsFixedLayout() || r.PageSpread != "left" || r.Viewport != (Viewport{}) {
+		t.Errorf("The rendition of the second page is %+v", r)
+	}
+	if _, err := e.PageRendition(2); err == nil {
+		t.Errorf("PageRendition() didn't fail out of the spine")
+	}
+}
+
+func TestRenditionAppleOptions(t *testing.T) {
+	const options = `<?xml version="1.0" encoding="UTF-8"?>
+<display_options>
+  <platform name="*">
+    <option name="fixed-layout">true</option>
+    <option name="orientation-lock">landscape-only</option>
+  </platform>
+</display_options>`
+
+	e := Epub{
+		opf:    &xmlOPF{},
+		reader: memReader{"META-INF/com.apple.ibooks.display-options.xml": []byte(options)},
+	}
+	if r := e.Rendition(); !r.IsFixedLayout() || r.Orientation != "landscape" {
+		t.Errorf("The rendition is %+v", r)
+	}
+}
@@ -0,0 +1,161 @@
+package raw
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Resource is a file of the manifest
+type Resource struct {
+	ID string
+	// Href is relative to the opf
+	Href       string
+	MediaType  string
+	Properties []string
+}
+
+// ResourceFilter selects resources of the manifest, the empty fields
+// select all of them
+type ResourceFilter struct {
+	// Families are the media type families selected, like "image" or "font"
+	Families []string
+	// Property is a property the manifest item needs to have, like
+	// "cover-image"
+	Property string
+}
+
+// the media types of each family that are not just "family/*"
+var mediaTypeFamilies = map[string]string{
+	"application/vnd.ms-opentype":   "font",
+	"application/font-sfnt":         "font",
+	"application/font-woff":         "font",
+	"application/x-font-ttf":        "font",
+	"application/x-font-otf":        "font",
+	"application/x-font-truetype":   "font",
+	"application/x-font-opentype":   "font",
+	"text/css":                      "css",
+	"application/javascript":        "script",
+	"application/ecmascript":        "script",
+	"application/x-javascript":      "script",
+	"text/javascript":               "script",
+	"application/xhtml+xml":         "document",
+	"text/html":                     "document",
+	"application/x-dtbncx+xml":      "navigation",
+	"application/smil+xml":          "overlay",
+	"application/pls+xml":           "other",
+	"application/oebps-package+xml": "other",
+}
+
+// fonts declared with a generic media type are found by their extension
+var fontExtensions = map[string]bool{".ttf": true, ".otf": true, ".woff": true, ".woff2": true}
+
+// Family returns the family of the media type of the resource: "image",
+// "font", "css", "audio", "video", "script", "document", "navigation",
+// "overlay" or "other"
+func (r Resource) Family() string {
+	mediaType := strings.ToLower(strings.TrimSpace(r.MediaType))
+	if i := strings.Index(mediaType, ";"); i >= 0 {
+		mediaType = strings.TrimSpace(mediaType[:i])
+	}
+	if family, ok := mediaTypeFamilies[mediaType]; ok {
+		return family
+	}
+	switch prefix := strings.SplitN(mediaType, "/", 2)[0]; prefix {
+	case "image", "font", "audio", "video":
+		return prefix
+	}
+	if fontExtensions[strings.ToLower(path.Ext(r.Href))] {
+		return "font"
+	}
+	return "other"
+}
+
+// HasProperty returns whether the manifest item has the property
+func (r Resource) HasProperty(property string) bool {
+	for _, p := range r.Properties {
+		if p == property {
+			return true
+		}
+	}
+	return false
+}
+
+func (filter ResourceFilter) match(r Resource) bool {
+	if filter.Property != "" && !r.HasProperty(filter.Property) {
+		return false
+	}
+	if len(filter.Families) == 0 {
+		return true
+	}
+	family := r.Family()
+	for _, f := range filter.Families {
+		if f == family {
+			return true
+		}
+	}
+	return false
+}
+
+// Resources returns the files of the manifest selected by the filter, in
+// the order of the manifest
+func (e Epub) Resources(filter ResourceFilter) []Resource {
+	resources := []Resource{}
+	for _, item := range e.opf.Manifest {
+		r := Resource{
+			ID:         item.ID,
+			Href:       item.Href,
+			MediaType:  item.MediaType,
+			Properties: strings.Fields(item.Properties),
+		}
+		if filter.match(r) {
+			resources = append(resources, r)
+		}
+	}
+	return resources
+}
+
+// ExtractResources writes the resources selected by the filter on the
+// directory dir, keeping their paths relative to the opf
+//
+// The obfuscated fonts are written deobfuscated. Returns the paths of the
+// written files.
+func (e Epub) ExtractResources(dir string, filter ResourceFilter) ([]string, error) {
+	written := []string{}
+	for _, r := range e.Resources(filter) {
+		name := cleanHref(r.Href)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return written, errors.New("The resource " + r.Href + " is outside of the book")
+		}
+		dst := filepath.Join(dir, filepath.FromSlash(name))
+		if err := e.extractResource(r.Href, dst); err != nil {
+			return written, err
+		}
+		written = append(written, dst)
+	}
+	return written, nil
+}
+
+func (e Epub) extractResource(href, dst string) error {
+	src, err := e.OpenResource(href)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, src); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,114 @@
+package raw
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResourceFamily(t *testing.T) {
+	tests := []struct {
+		href, mediaType, family string
+	}{
+		{"a.jpg", "image/jpeg", "image"},
+		{"a.svg", "image/svg+xml", "image"},
+		{"a.otf", "application/vnd.ms-opentype", "font"},
+		{"a.woff2", "font/woff2", "font"},
+		{"a.ttf", "application/octet-stream", "font"},
+		{"a.css", "text/css", "css"},
+		{"a.mp3", "audio/mpeg", "audio"},
+		{"a.mp4", "video/mp4", "video"},
+		{"a.js", "text/javascript", "script"},
+		{"a.xhtml", "application/xhtml+xml", "document"},
+		{"toc.ncx", "application/x-dtbncx+xml", "navigation"},
+		{"a.smil", "application/smil+xml", "overlay"},
+		{"a.bin", "application/octet-stream", "other"},
+	}
+	for _, test := range tests {
+		r := Resource{Href: test.href, MediaType: test.mediaType}
+		if family := r.Family(); family != test.family {
+			t.Errorf("The family of %v (%v) is %v, the expected was %v", test.href, test.mediaType, family, test.family)
+		}
+	}
+}
+
+func TestResources(t *testing.T) {
+	f, _ := NewEpub(bookPath)
+	defer f.Close()
+
+	all := f.Resources(ResourceFilter{})
+	if len(all) != len(f.Files()) {
+		t.Errorf("Resources() return %d files, but the manifest has %d", len(all), len(f.Files()))
+	}
+	images := f.Resources(ResourceFilter{Families: []string{"image"}})
+	if len(images) != 5 {
+		t.Errorf("The book has %d images: %+v", len(images), images)
+	}
+	styles := f.Resources(ResourceFilter{Families: []string{"css", "font"}})
+	if len(styles) != 3 {
+		t.Errorf("The book has %d styles: %+v", len(styles), styles)
+	}
+	if nav := f.Resources(ResourceFilter{Property: "nav"}); len(nav) != 0 {
+		t.Errorf("The book has a nav: %+v", nav)
+	}
+}
+
+// resourcesBook returns an epub with an obfuscated font, an svg cover and
+// the extra items on the manifest
+func resourcesBook(t *testing.T, font []byte, items string) *Epub {
+	key := sha1.Sum([]byte("urn:uuid:1234"))
+	obfuscated := append([]byte{}, font...)
+	deobfuscate(obfuscated, key[:], 1040)
+	return newTestEpub(t, map[string]string{
+		"OEBPS/content.opf": `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:identifier id="uid">urn:uuid:1234</dc:identifier></metadata>
+<manifest>
+<item id="font" href="fonts/a.otf" media-type="application/vnd.ms-opentype"/>
+<item id="cover" href="images/cover.svg" media-type="image/svg+xml" properties="cover-image"/>
+<item id="ch1" href="ch1.xhtml" media-type="application/xhtml+xml"/>
+` + items + `</manifest>
+<spine><itemref idref="ch1"/></spine>
+</package>`,
+		"META-INF/encryption.xml": `<encryption xmlns="urn:oasis:names:tc:opendocument:xmlns:container" xmlns:enc="http://www.w3.org/2001/04/xmlenc#">
+<enc:EncryptedData><enc:EncryptionMethod Algorithm="http://www.idpf.org/2008/embedding"/>
+<enc:CipherData><enc:CipherReference URI="OEBPS/fonts/a.otf"/></enc:CipherData></enc:EncryptedData>
+</encryption>`,
+		"OEBPS/fonts/a.otf":      string(obfuscated),
+		"OEBPS/images/cover.svg": "<svg/>",
+		"OEBPS/ch1.xhtml":        "<html/>",
+	})
+}
+
+func TestExtractResources(t *testing.T) {
+	font := bytes.Repeat([]byte("OTTO font data "), 100)
+	e := resourcesBook(t, font, "")
+
+	dir := t.TempDir()
+	written, err := e.ExtractResources(dir, ResourceFilter{Families: []string{"font", "image"}})
+	if err != nil {
+		t.Fatalf("ExtractResources() return an error: %v", err)
+	}
+	if len(written) != 2 {
+		t.Fatalf("The written files are %v", written)
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(dir, "fonts", "a.otf")); !bytes.Equal(data, font) {
+		t.Errorf("The font was not deobfuscated")
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(dir, "images", "cover.svg")); string(data) != "<svg/>" {
+		t.Errorf("The svg is %q", data)
+	}
+
+	covers := e.Resources(ResourceFilter{Property: "cover-image"})
+	if len(covers) != 1 || covers[0].ID != "cover" {
+		t.Errorf("The covers are %+v", covers)
+	}
+
+	e = resourcesBook(t, font, `<item id="out" href="../out.png" media-type="image/png"/>`+"\n")
+	if _, err := e.ExtractResources(t.TempDir(), ResourceFilter{}); err == nil || !strings.Contains(err.Error(), "outside") {
+		t.Errorf("ExtractResources() didn't fail with a file outside of the book: %v", err)
+	}
+}
@@ -0,0 +1,322 @@
+package raw
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// SanitizePolicy is what the sanitizer keeps of the documents
+//
+// The script elements, the event handler attributes and the javascript:
+// urls are always removed. The SVG and MathML elements are kept, without
+// their scripts.
+type SanitizePolicy struct {
+	// Elements are the HTML elements kept. The ones not listed are
+	// removed with their content if they embed other documents or code,
+	// like iframe or object, and replaced by their children otherwise.
+	Elements map[string]bool
+	// Attributes are the attributes kept on the HTML elements, besides
+	// the data-*, aria-*, epub:*, xml:* and xmlns ones
+	Attributes map[string]bool
+	// URLSchemes are the schemes allowed on the links to outside the book
+	URLSchemes map[string]bool
+	// RemoteResources keeps the images, style sheets and other resources
+	// loaded from outside the book
+	RemoteResources bool
+}
+
+// SanitizeRemoval is something removed from a document by the sanitizer
+type SanitizeRemoval struct {
+	// Element is the name of the element removed, or of the element where
+	// the attribute or the style was removed from
+	Element string
+	// Attribute is the attribute removed, "" if it was the element
+	Attribute string
+	// Value is the removed url, or the value of the attribute
+	Value string
+	// Reason is "script", "event-handler", "javascript-url",
+	// "embedded-content", "remote-resource", "url-scheme", "refresh" or
+	// "not-allowed"
+	Reason string
+}
+
+// the elements removed with their content if they are not allowed, they
+// embed other documents or code
+var droppedElements = map[string]bool{
+	"applet":   true,
+	"base":     true,
+	"embed":    true,
+	"frame":    true,
+	"frameset": true,
+	"iframe":   true,
+	"noscript": true,
+	"object":   true,
+	"template": true,
+}
+
+// attributes with urls, and whether they load a resource or are links
+var urlAttributes = map[string]bool{
+	"action":     false,
+	"background": true,
+	"cite":       false,
+	"data":       true,
+	"formaction": false,
+	"href":       false,
+	"longdesc":   false,
+	"poster":     true,
+	"src":        true,
+	"xlink:href": false,
+}
+
+// DefaultSanitizePolicy returns the policy with the elements and attributes
+// of the books, ready to be extended
+func DefaultSanitizePolicy() *SanitizePolicy {
+	p := &SanitizePolicy{
+		Elements:   map[string]bool{},
+		Attributes: map[string]bool{},
+		URLSchemes: map[string]bool{"http": true, "https": true, "mailto": true},
+	}
+	for _, e := range strings.Fields(`html head title meta link style body
+		section nav article aside header footer main address h1 h2 h3 h4 h5 h6
+		hgroup p div span a img br hr ul ol li dl dt dd table thead tbody tfoot
+		tr th td caption colgroup col em strong i b u s sub sup small big code
+		pre blockquote q cite abbr dfn kbd samp var del ins mark ruby rt rp rb
+		rtc figure figcaption audio video source track picture map area time
+		bdi bdo wbr details summary center font strike tt`) {
+		p.Elements[e] = true
+	}
+	for _, a := range strings.Fields(`id class style title lang dir hidden
+		href src alt width height rel type media charset name content
+		http-equiv colspan rowspan span headers scope abbr align valign
+		start reversed value datetime cite controls loop muted preload
+		poster kind srclang label default usemap shape coords ismap
+		border cellpadding cellspacing summary face size color`) {
+		p.Attributes[a] = true
+	}
+	return p
+}
+
+// SanitizeDocument removes from the document what the policy doesn't allow,
+// a nil policy is the default one
+//
+// Returns what was removed, in document order.
+func SanitizeDocument(doc *html.Node, policy *SanitizePolicy) []SanitizeRemoval {
+	if policy == nil {
+		policy = DefaultSanitizePolicy()
+	}
+	s := sanitizer{policy: policy, removed: []SanitizeRemoval{}}
+	s.sanitizeChildren(doc)
+	return s.removed
+}
+
+// SanitizeDocument parses and sanitizes the content document href, relative
+// to the opf, and returns it rendered as XHTML with what was removed
+func (e Epub) SanitizeDocument(href string, policy *SanitizePolicy) ([]byte, []SanitizeRemoval, error) {
+	doc, err := e.ParseDocument(href)
+	if err != nil {
+		return nil, nil, err
+	}
+	removed := SanitizeDocument(doc, policy)
+	var b bytes.Buffer
+	if err := renderXHTML(&b, doc); err != nil {
+		return nil, nil, err
+	}
+	return b.Bytes(), removed, nil
+}
+
+type sanitizer struct {
+	policy  *SanitizePolicy
+	removed []SanitizeRemoval
+}
+
+func (s *sanitizer) remove(element, attribute, value, reason string) {
+	s.removed = append(s.removed, SanitizeRemoval{element, attribute, value, reason})
+}
+
+func (s *sanitizer) sanitizeChildren(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		s.sanitizeNode(c)
+		c = next
+	}
+}
+
+func (s *sanitizer) sanitizeNode(n *html.Node) {
+	if n.Type != html.ElementNode {
+		return
+	}
+	name := strings.ToLower(n.Data)
+	foreign := n.Namespace == "svg" || n.Namespace == "math"
+	switch {
+	case name == "script":
+		s.remove(n.Data, "", attr(n, "src"), "script")
+		n.Parent.RemoveChild(n)
+		return
+	case name == "meta" && strings.EqualFold(attr(n, "http-equiv"), "refresh"):
+		s.remove(n.Data, "http-equiv", attr(n, "content"), "refresh")
+		n.Parent.RemoveChild(n)
+		return
+	case foreign && animatesLink(n):
+		// the animations can set a javascript: url on the href of a link
+		s.remove(n.Data, "attributeName", attr(n, "attributeName"), "script")
+		n.Parent.RemoveChild(n)
+		return
+	case foreign || s.policy.Elements[name]:
+	case droppedElements[name]:
+		s.remove(n.Data, "", "", "embedded-content")
+		n.Parent.RemoveChild(n)
+		return
+	default:
+		// keep the content of the unknown elements
+		s.remove(n.Data, "", "", "not-allowed")
+		s.sanitizeChildren(n)
+		for c := n.FirstChild; c != nil; c = n.FirstChild {
+			n.RemoveChild(c)
+			n.Parent.InsertBefore(c, n)
+		}
+		n.Parent.RemoveChild(n)
+		return
+	}
+
+	attrs := n.Attr[:0]
+	for _, a := range n.Attr {
+		if s.keepAttribute(n, a, foreign) {
+			if a.Key == "style" {
+				a.Val = s.sanitizeCSS(n.Data, a.Val)
+			}
+			attrs = append(attrs, a)
+		}
+	}
+	n.Attr = attrs
+
+	if name == "style" {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.TextNode {
+				c.Data = s.sanitizeCSS(n.Data, c.Data)
+			}
+		}
+	}
+	s.sanitizeChildren(n)
+}
+
+// animatesLink returns whether the SVG element is an animation of an href
+func animatesLink(n *html.Node) bool {
+	switch strings.ToLower(n.Data) {
+	case "animate", "set", "animatemotion", "animatetransform":
+	default:
+		return false
+	}
+	for _, a := range n.Attr {
+		if strings.ToLower(a.Key) != "attributename" {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(a.Val)) {
+		case "href", "xlink:href":
+			return true
+		}
+	}
+	return false
+}
+
+func (s *sanitizer) keepAttribute(n *html.Node, a html.Attribute, foreign bool) bool {
+	name := a.Key
+	if a.Namespace != "" {
+		name = a.Namespace + ":" + a.Key
+	}
+	key := strings.ToLower(name)
+	switch {
+	case strings.HasPrefix(key, "on"):
+		s.remove(n.Data, name, a.Val, "event-handler")
+		return false
+	case !foreign && !s.policy.Attributes[key] && !strings.HasPrefix(key, "data-") &&
+		!strings.HasPrefix(key, "aria-") && !strings.HasPrefix(key, "epub:") &&
+		!strings.HasPrefix(key, "xml:") && !strings.HasPrefix(key, "xmlns"):
+		s.remove(n.Data, name, a.Val, "not-allowed")
+		return false
+	}
+
+	isResource, isURL := urlAttributes[key]
+	if !isURL {
+		return true
+	}
+	if key == "href" && (n.Data == "link" || n.Data == "image" || n.Data == "use") ||
+		key == "xlink:href" && n.Data != "a" {
+		isResource = true
+	}
+	if reason := s.urlReason(a.Val, isResource); reason != "" {
+		s.remove(n.Data, name, a.Val, reason)
+		return false
+	}
+	return true
+}
+
+// urlReason returns why the url has to be removed, "" if it can be kept
+func (s *sanitizer) urlReason(value string, isResource bool) string {
+	// browsers ignore the whitespace and control characters inside the
+	// scheme, like in "java\tscript:"
+	u := strings.ToLower(strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, value))
+	switch {
+	case strings.HasPrefix(u, "javascript:"), strings.HasPrefix(u, "vbscript:"):
+		return "javascript-url"
+	case strings.HasPrefix(u, "data:"):
+		if isResource && (strings.HasPrefix(u, "data:image/") && !strings.HasPrefix(u, "data:image/svg") ||
+			strings.HasPrefix(u, "data:font/") || strings.HasPrefix(u, "data:audio/")) {
+			return ""
+		}
+		return "javascript-url"
+	case strings.HasPrefix(u, "//"):
+		if isResource && !s.policy.RemoteResources {
+			return "remote-resource"
+		}
+		return ""
+	}
+	match := urlScheme.FindString(u)
+	if match == "" {
+		// inside the book
+		return ""
+	}
+	if isResource {
+		if s.policy.RemoteResources {
+			return ""
+		}
+		return "remote-resource"
+	}
+	if !s.policy.URLSchemes[strings.TrimSuffix(match, ":")] {
+		return "url-scheme"
+	}
+	return ""
+}
+
+// sanitizeCSS removes the remote and javascript urls of the css, and the
+// old IE expressions
+func (s *sanitizer) sanitizeCSS(element, css string) string {
+	css = cssURL.ReplaceAllStringFunc(css, func(match string) string {
+		parts := cssURL.FindStringSubmatch(match)
+		value := parts[2]
+		if parts[3] != "" {
+			value = parts[3]
+		}
+		value = strings.Trim(value, `"'`)
+		reason := s.urlReason(value, true)
+		if reason == "" {
+			return match
+		}
+		s.remove(element, "style", value, reason)
+		if parts[1] != "" || parts[3] != "" {
+			return ""
+		}
+		return "none"
+	})
+	if strings.Contains(strings.ToLower(css), "expression(") {
+		s.remove(element, "style", css, "script")
+		return ""
+	}
+	return css
+}
@@ -0,0 +1,169 @@
+package raw
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+const unsafePage = `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="en">
+<head>
+<meta http-equiv="refresh" content="0;url=javascript:alert(1)"/>
+<link rel="stylesheet" href="http://evil.example.com/tracker.css"/>
+<link rel="stylesheet" href="../css/book.css"/>
+<style>@import "https://evil.example.com/a.css"; body { background: url('//evil.example.com/p.png') } p { color: red }</style>
+<script src="book.js"></script>
+</head>
+<body onload="steal()">
+<section epub:type="chapter" id="c1">
+<p style="background: url(javascript:alert(1)); color: blue">Text <a href="JaVa&#x09;Script:alert(1)">bad</a>
+<a href="https://example.com/">good</a> <a href="ftp://example.com/">ftp</a>
+<a epub:type="noteref" href="notes.xhtml#n1">1</a></p>
+<img src="http://evil.example.com/pixel.gif" alt="pixel"/>
+<img src="../images/fig.png" alt="figure" onerror="steal()"/>
+<iframe src="https://example.com/"><p>inside</p></iframe>
+<object data="movie.swf"></object>
+<embed src="movie.swf"/>
+<p data-note="1" marquee="yes"><blink>kept text</blink></p>
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" viewBox="0 0 10 10">
+<script>alert(1)</script>
+<image width="10" height="10" xlink:href="../images/fig.png"/>
+<a xlink:href="javascript:alert(1)"><circle cx="5" cy="5" r="4" onclick="steal()"/></a>
+<a><animate attributeName="href" values="javascript:alert(1)"/><set attributeName="xlink:href" to="javascript:alert(1)"/><text>x</text></a>
+<animate attributeName="opacity" values="0;1" dur="1s"/>
+</svg>
+<math xmlns="http://www.w3.org/1998/Math/MathML"><mi mathvariant="bold">x</mi></math>
+</section>
+</body>
+</html>`
+
+func TestSanitizeDocument(t *testing.T) {
+	doc, err := parseDocument(strings.NewReader(unsafePage))
+	if err != nil {
+		t.Fatalf("parseDocument() return an error: %v", err)
+	}
+	removed := SanitizeDocument(doc, nil)
+	reasons := map[string]int{}
+	for _, r := range removed {
+		reasons[r.Reason]++
+	}
+	for reason, count := range map[string]int{
+		"refresh":          1,
+		"remote-resource":  4,
+		"script":           4,
+		"event-handler":    3,
+		"javascript-url":   3,
+		"url-scheme":       1,
+		"embedded-content": 3,
+		"not-allowed":      2,
+	} {
+		if reasons[reason] != count {
+			t.Errorf("%d removals for %s, expected %d: %+v", reasons[reason], reason, count, removed)
+		}
+	}
+
+	var b strings.Builder
+	html.Render(&b, doc)
+	out := b.String()
+	for _, unsafe := range []string{"<script", "onload", "onerror", "onclick", "javascript", "evil.example.com",
+		"<iframe", "inside", "<object", "<embed", "<blink", "marquee", "refresh", "ftp:"} {
+		if strings.Contains(strings.ToLower(out), strings.ToLower(unsafe)) {
+			t.Errorf("%q was not removed:\n%s", unsafe, out)
+		}
+	}
+	for _, safe := range []string{`epub:type="chapter"`, `epub:type="noteref"`, `href="https://example.com/"`,
+		`href="../css/book.css"`, `src="../images/fig.png"`, `xlink:href="../images/fig.png"`, "<circle",
+		`<mi mathvariant="bold">x</mi>`, "kept text", "<text>x</text>", `attributeName="opacity"`, "color: blue", "p { color: red }", `xml:lang="en"`} {
+		if !strings.Contains(out, safe) {
+			t.Errorf("%q was removed:\n%s", safe, out)
+		}
+	}
+}
+
+func TestSanitizePolicy(t *testing.T) {
+	doc, _ := parseDocument(strings.NewReader(unsafePage))
+	policy := DefaultSanitizePolicy()
+	policy.Elements["iframe"] = true
+	policy.Attributes["marquee"] = true
+	policy.RemoteResources = true
+	removed := SanitizeDocument(doc, policy)
+	for _, r := range removed {
+		if r.Reason == "remote-resource" || r.Element == "iframe" || r.Attribute == "marquee" {
+			t.Errorf("The policy didn't allow %+v", r)
+		}
+	}
+	var b strings.Builder
+	html.Render(&b, doc)
+	if out := b.String(); !strings.Contains(out, "http://evil.example.com/pixel.gif") || !strings.Contains(out, "<iframe") {
+		t.Errorf("The allowed content was removed:\n%s", out)
+	}
+}
+
+func TestSpineSanitize(t *testing.T) {
+	e := newTestEpub(t, map[string]string{
+		"content.opf": `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+<metadata/>
+<manifest><item id="c1" href="text/c1.xhtml" media-type="application/xhtml+xml" properties="scripted svg mathml"/></manifest>
+<spine><itemref idref="c1"/></spine>
+</package>`,
+		"text/c1.xhtml": unsafePage,
+	})
+	it, err := e.Spine()
+	if err != nil {
+		t.Fatalf("Spine() return an error: %v", err)
+	}
+	it.Sanitize(DefaultSanitizePolicy())
+	f, err := it.Open()
+	if err != nil {
+		t.Fatalf("Open() return an error: %v", err)
+	}
+	data, _ := ioutil.ReadAll(f)
+	f.Close()
+	if strings.Contains(string(data), "<script") || !strings.Contains(string(data), "kept text") {
+		t.Errorf("The document was not sanitized:\n%s", data)
+	}
+	if _, err := parseXMLDocument(data); err != nil {
+		t.Errorf("The sanitized document is not XHTML: %v\n%s", err, data)
+	}
+
+	_, removed, err := e.SanitizeDocument("text/c1.xhtml", nil)
+	if err != nil || len(removed) == 0 {
+		t.Errorf("SanitizeDocument() = %v, %v", removed, err)
+	}
+}
+
+func TestRenderXHTML(t *testing.T) {
+	// not well formed, it goes through the HTML parser
+	doc, err := parseDocument(strings.NewReader(`<html><body>
+<p epub:type="note">a<br>b &amp; c<img src="a.png"></p><div></div>
+<svg viewBox="0 0 1 1"><use xlink:href="#c"/><circle id="c" r="1"/></svg>
+</body></html>`))
+	if err != nil {
+		t.Fatalf("parseDocument() return an error: %v", err)
+	}
+	var b strings.Builder
+	if err := renderXHTML(&b, doc); err != nil {
+		t.Fatalf("renderXHTML() return an error: %v", err)
+	}
+	out := b.String()
+	for _, expected := range []string{
+		`<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xmlns:xlink="http://www.w3.org/1999/xlink">`,
+		`a<br/>b &amp; c<img src="a.png"/></p><div></div>`,
+		`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 1 1"><use xlink:href="#c"/>`,
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("%q not found on the document:\n%s", expected, out)
+		}
+	}
+	xml, err := parseXMLDocument([]byte(out))
+	if err != nil {
+		t.Fatalf("The document is not XHTML: %v\n%s", err, out)
+	}
+	if p := findElement(xml, "p"); attr(p, "epub:type") != "note" {
+		t.Errorf("The attributes of the paragraph are %v", p.Attr)
+	}
+}
@@ -0,0 +1,234 @@
+package raw
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ssor/epubgo/internal/docutil"
+	"golang.org/x/text/unicode/norm"
+)
+
+const defaultSnippetLength = 40
+
+// SearchOptions configures Epub.Search
+//
+// The zero value does a case and diacritic insensitive search of the
+// literal query on the whole book.
+type SearchOptions struct {
+	// Context cancels the search, the hits found so far are returned
+	Context context.Context
+	// CaseSensitive distinguishes upper and lower case letters
+	CaseSensitive bool
+	// DiacriticSensitive distinguishes letters with and without accents
+	DiacriticSensitive bool
+	// Regexp interprets the query as a regular expression
+	Regexp bool
+	// WholeWord only matches whole words. CJK text has no word boundaries,
+	// so hits surrounded by Han, Kana or Hangul characters always match.
+	WholeWord bool
+	// SnippetLength is the number of characters of context on each side of
+	// the hit, 40 by default
+	SnippetLength int
+	// MaxHits stops the search after that many hits, 0 means no limit
+	MaxHits int
+}
+
+// SearchHit is an occurrence of the query on the book
+type SearchHit struct {
+	SpineIndex int
+	Href       string
+	// NavPoint is the closest entry of the index before the hit, nil if none
+	NavPoint *NavPoint
+	Match    string
+	Snippet  string
+	Location Location
+}
+
+// Search looks for query on the text of the documents of the spine
+func (e Epub) Search(query string, opts SearchOptions) ([]SearchHit, error) {
+	if query == "" {
+		return nil, errors.New("Empty search query")
+	}
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if opts.SnippetLength <= 0 {
+		opts.SnippetLength = defaultSnippetLength
+	}
+
+	var re *regexp.Regexp
+	if opts.Regexp {
+		pattern := query
+		if !opts.DiacriticSensitive {
+			pattern, _ = foldText(pattern, false, true)
+		}
+		if !opts.CaseSensitive {
+			pattern = "(?i)" + pattern
+		}
+		var err error
+		re, err = regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		query, _ = foldText(query, !opts.CaseSensitive, !opts.DiacriticSensitive)
+	}
+
+	toc := newTocLocator(e)
+	hits := []SearchHit{}
+	for i := 0; i < e.opf.spineLength(); i++ {
+		if err := ctx.Err(); err != nil {
+			return hits, err
+		}
+		item := e.opf.spineItem(i)
+		if item == nil || !isTextContent(item.MediaType) {
+			continue
+		}
+		text, err := e.SpineText(i)
+		if err != nil {
+			return hits, err
+		}
+		toc.addDocument(text)
+
+		var matches [][]int
+		if re != nil {
+			folded, index := foldText(text.Text, false, !opts.DiacriticSensitive)
+			matches = mapMatches(re.FindAllStringIndex(folded, -1), index)
+		} else {
+			folded, index := foldText(text.Text, !opts.CaseSensitive, !opts.DiacriticSensitive)
+			matches = mapMatches(findAll(folded, query), index)
+		}
+
+		for _, m := range matches {
+			if m[0] == m[1] {
+				continue
+			}
+			if opts.WholeWord && !isWholeWord(text.Text, m[0], m[1]) {
+				continue
+			}
+			hits = append(hits, SearchHit{
+				SpineIndex: i,
+				Href:       text.Href,
+				NavPoint:   toc.before(i, m[0]),
+				Match:      text.Text[m[0]:m[1]],
+				Snippet:    snippet(text.Text, m[0], m[1], opts.SnippetLength),
+				Location:   text.Location(m[0]),
+			})
+			if opts.MaxHits > 0 && len(hits) >= opts.MaxHits {
+				return hits, nil
+			}
+		}
+	}
+	return hits, nil
+}
+
+// foldText removes the case and/or the diacritics of s
+//
+// It returns the folded text and the offset on s of each byte of it, with
+// an extra entry for the end of the text.
+func foldText(s string, foldCase, foldDiacritics bool) (string, []int) {
+	var b strings.Builder
+	index := make([]int, 0, len(s)+1)
+	for i, r := range s {
+		repl := string(r)
+		if foldDiacritics && r >= utf8.RuneSelf {
+			repl = stripMarks(repl)
+		}
+		if foldCase {
+			repl = strings.ToLower(repl)
+		}
+		b.WriteString(repl)
+		for j := 0; j < len(repl); j++ {
+			index = append(index, i)
+		}
+	}
+	index = append(index, len(s))
+	return b.String(), index
+}
+
+func stripMarks(s string) string {
+	decomposed := norm.NFD.String(s)
+	var b strings.Builder
+	for _, r := range decomposed {
+		if !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(r)
+		}
+	}
+	return norm.NFC.String(b.String())
+}
+
+func findAll(s, substr string) [][]int {
+	matches := [][]int{}
+	offset := 0
+	for {
+		i := strings.Index(s[offset:], substr)
+		if i < 0 {
+			return matches
+		}
+		start := offset + i
+		matches = append(matches, []int{start, start + len(substr)})
+		offset = start + len(substr)
+	}
+}
+
+// mapMatches translates the matches on a folded text to the original one
+func mapMatches(matches [][]int, index []int) [][]int {
+	for _, m := range matches {
+		start := index[m[0]]
+		end := index[m[1]]
+		// the end can fall in the middle of a folded character
+		for m[1] > 0 && m[1] < len(index)-1 && index[m[1]-1] == end {
+			m[1]++
+			end = index[m[1]]
+		}
+		m[0], m[1] = start, end
+	}
+	return matches
+}
+
+func isWholeWord(text string, start, end int) bool {
+	first, _ := utf8.DecodeRuneInString(text[start:])
+	last, _ := utf8.DecodeLastRuneInString(text[:end])
+	if start > 0 {
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		if isWordRune(before) && isWordRune(first) {
+			return false
+		}
+	}
+	if end < len(text) {
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if isWordRune(after) && isWordRune(last) {
+			return false
+		}
+	}
+	return true
+}
+
+// isWordRune reports whether r is part of a word made of space separated
+// words. CJK characters are not, any of them is a word boundary.
+func isWordRune(r rune) bool {
+	if docutil.IsCJK(r) {
+		return false
+	}
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+}
+
+// snippet returns the text around the match with context characters on each side
+func snippet(text string, start, end, context int) string {
+	from := start
+	for i := 0; i < context && from > 0; i++ {
+		_, size := utf8.DecodeLastRuneInString(text[:from])
+		from -= size
+	}
+	to := end
+	for i := 0; i < context && to < len(text); i++ {
+		_, size := utf8.DecodeRuneInString(text[to:])
+		to += size
+	}
+	return strings.TrimSpace(strings.Replace(text[from:to], "\n", " ", -1))
+}
@@ -0,0 +1,114 @@
+package raw
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+const (
+	gcdxyPath = "../testdata/gcdxy.epub"
+)
+
+func TestSearch(t *testing.T) {
+	f, _ := NewEpub(bookPath)
+	defer f.Close()
+
+	hits, err := f.Search("puppy", SearchOptions{})
+	if err != nil {
+		t.Fatalf("Search() return an error: %v", err)
+	}
+	if len(hits) == 0 {
+		t.Fatalf("Search() didn't find any hit")
+	}
+	hit := hits[0]
+	if strings.ToLower(hit.Match) != "puppy" {
+		t.Errorf("hit.Match is '%v'", hit.Match)
+	}
+	if hit.Href != htmlFile {
+		t.Errorf("hit.Href is '%v', the expected was '%v'", hit.Href, htmlFile)
+	}
+	if hit.SpineIndex != 1 {
+		t.Errorf("hit.SpineIndex is %v, the expected was 1", hit.SpineIndex)
+	}
+	if !strings.Contains(hit.Snippet, hit.Match) {
+		t.Errorf("hit.Snippet '%v' doesn't contain the match", hit.Snippet)
+	}
+	if hit.NavPoint == nil {
+		t.Errorf("hit.NavPoint is nil")
+	}
+	if !strings.HasPrefix(hit.Location.CFI(), "epubcfi(/6/4[item8]!/4/") {
+		t.Errorf("unexpected CFI: %v", hit.Location.CFI())
+	}
+}
+
+func TestSearchCaseSensitive(t *testing.T) {
+	f, _ := NewEpub(bookPath)
+	defer f.Close()
+
+	insensitive, _ := f.Search("DOG'S TALE", SearchOptions{})
+	sensitive, _ := f.Search("DOG'S TALE", SearchOptions{CaseSensitive: true})
+	if len(sensitive) == 0 || len(sensitive) >= len(insensitive) {
+		t.Errorf("case sensitive search found %v hits and insensitive %v", len(sensitive), len(insensitive))
+	}
+}
+
+func TestSearchCJK(t *testing.T) {
+	f, err := NewEpub(gcdxyPath)
+	if err != nil {
+		t.Fatalf("Open(%v) return an error: %v", gcdxyPath, err)
+	}
+	defer f.Close()
+
+	hits, err := f.Search("海伦·麦克法兰", SearchOptions{WholeWord: true})
+	if err != nil {
+		t.Fatalf("Search() return an error: %v", err)
+	}
+	if len(hits) == 0 {
+		t.Fatalf("Search() didn't find any hit")
+	}
+	hit := hits[0]
+	if hit.Href != "chapter_00003.xhtml" {
+		t.Errorf("hit.Href is '%v'", hit.Href)
+	}
+	if hit.NavPoint == nil || hit.NavPoint.Title() != "1872年德文版序言" {
+		t.Errorf("hit.NavPoint is %v", hit.NavPoint)
+	}
+	if cfi := hit.Location.CFI(); cfi != "epubcfi(/6/10[chapter_00003_xhtml]!/4/6/5:3)" {
+		t.Errorf("unexpected CFI: %v", cfi)
+	}
+}
+
+func TestSearchRegexp(t *testing.T) {
+	f, _ := NewEpub(gcdxyPath)
+	defer f.Close()
+
+	hits, err := f.Search(`18[0-9]{2}年`, SearchOptions{Regexp: true, MaxHits: 5})
+	if err != nil {
+		t.Fatalf("Search() return an error: %v", err)
+	}
+	if len(hits) != 5 {
+		t.Errorf("Search() found %v hits, 5 were expected", len(hits))
+	}
+}
+
+func TestSearchCancel(t *testing.T) {
+	f, _ := NewEpub(gcdxyPath)
+	defer f.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := f.Search("宣言", SearchOptions{Context: ctx}); err != context.Canceled {
+		t.Errorf("Search() with a canceled context return: %v", err)
+	}
+}
+
+func TestFoldText(t *testing.T) {
+	folded, index := foldText("Crème Brûlée", true, true)
+	if folded != "creme brulee" {
+		t.Errorf("foldText() return '%v'", folded)
+	}
+	if len(index) != len(folded)+1 {
+		t.Errorf("foldText() index has %v entries for %v bytes", len(index), len(folded))
+	}
+}
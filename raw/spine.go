@@ -5,8 +5,10 @@
 package raw
 
 import (
+	"bytes"
 	"errors"
 	"io"
+	"io/ioutil"
 )
 
 // List all XHTML documents in manifest (using the idref), and not anything else, and with no duplicates. The order is significant. (XHTML documents can be omitted, but then they must not be linked, referenced or reachable from any part of the publication.)
@@ -18,6 +20,12 @@ type SpineIterator struct {
 	opf   *xmlOPF
 	index int
 	epub  *Epub
+	// supported media types to follow the fallbacks, nil to open the items
+	// as they are
+	supported func(mediaType string) bool
+	// policy to sanitize the content documents, nil to open them as they
+	// are
+	policy *SanitizePolicy
 }
 
 func newSpineIterator(epub *Epub) (*SpineIterator, error) {
@@ -63,10 +71,51 @@ func (spine *SpineIterator) Previous() error {
 	return nil
 }
 
+// UseFallbacks makes Open follow the fallback chain of the items with a
+// media type not supported, nil opens the items as they are
+func (spine *SpineIterator) UseFallbacks(supported func(mediaType string) bool) {
+	spine.supported = supported
+}
+
+// Sanitize makes Open sanitize the content documents with the policy, nil
+// opens them as they are
+//
+// Sanitizing is opt-in: by default Open returns the documents as they are on
+// the epub, scripts and event handlers included. Use Epub.SanitizeDocument to
+// know what gets removed.
+func (spine *SpineIterator) Sanitize(policy *SanitizePolicy) {
+	spine.policy = policy
+}
+
 // Open opens the file of the iterator
+//
+// If UseFallbacks was set, it opens the first supported item of the
+// fallback chain. Only if Sanitize was set the content documents are parsed
+// and rendered back sanitized as XHTML.
 func (spine SpineIterator) Open() (io.ReadCloser, error) {
 	url := spine.URL()
-	return spine.epub.OpenFile(url)
+	if spine.supported != nil {
+		var err error
+		url, err = spine.epub.ResolveFallback(spine.opf.Spine.Items[spine.index].IDref, spine.supported)
+		if err != nil {
+			return nil, err
+		}
+	}
+	item := spine.epub.FileManifest(url)
+	if spine.policy == nil || item == nil || !isTextContent(item.MediaType) {
+		return spine.epub.OpenFile(url)
+	}
+
+	doc, err := spine.epub.ParseDocument(url)
+	if err != nil {
+		return nil, err
+	}
+	SanitizeDocument(doc, spine.policy)
+	var b bytes.Buffer
+	if err := renderXHTML(&b, doc); err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(&b), nil
 }
 
 // URL returns the url of the item on the iterator
@@ -0,0 +1,350 @@
+package raw
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/ssor/epubgo/internal/docutil"
+	"golang.org/x/net/html"
+)
+
+// DocumentText is the plain text of a content document
+//
+// It keeps track of where each piece of text comes from, so offsets on Text
+// can be mapped back to a Location on the markup.
+type DocumentText struct {
+	Href       string
+	SpineIndex int // -1 if the document is not on the spine
+	Text       string
+	Blocks     []TextBlock
+
+	idref    string
+	segments []textSegment
+	anchors  map[string]int
+}
+
+// TextBlock is the text of a block level element (paragraph, heading, ...)
+//
+// Start and End are byte offsets on DocumentText.Text.
+type TextBlock struct {
+	Tag   string
+	Start int
+	End   int
+}
+
+// Location is a position inside a content document
+//
+// Path is the element path in the CFI syntax (like /4[body01]/10/3) and
+// Offset the character offset inside the text node, in UTF-16 units as
+// reading systems count them.
+type Location struct {
+	SpineIndex int
+	IDref      string
+	Href       string
+	Path       string
+	Offset     int
+}
+
+// CFI returns the location expressed as an EPUB canonical fragment identifier
+func (l Location) CFI() string {
+	spineStep := "/6/" + strconv.Itoa((l.SpineIndex+1)*2)
+	if l.IDref != "" {
+		spineStep += "[" + l.IDref + "]"
+	}
+	return "epubcfi(" + spineStep + "!" + l.Path + ":" + strconv.Itoa(l.Offset) + ")"
+}
+
+type textSegment struct {
+	start int
+	end   int
+	path  string
+	// utf16 offset inside the text node of each rune written
+	offsets []int
+	// utf16 offset inside the text node after the last rune written
+	endOffset int
+}
+
+// elements whose text is not part of the document content
+var skippedElements = map[string]bool{
+	"head":     true,
+	"script":   true,
+	"style":    true,
+	"template": true,
+}
+
+// DocumentText extracts the text of the content document href
+func (e Epub) DocumentText(href string) (*DocumentText, error) {
+	doc, err := e.ParseDocument(href)
+	if err != nil {
+		return nil, err
+	}
+	text := newDocumentText(doc)
+	text.Href = href
+	text.SpineIndex = e.opf.spineIndex(href)
+	if text.SpineIndex >= 0 {
+		text.idref = e.opf.Spine.Items[text.SpineIndex].IDref
+	}
+	return text, nil
+}
+
+// SpineText extracts the text of the document on the position index of the spine
+func (e Epub) SpineText(index int) (*DocumentText, error) {
+	if index < 0 || index >= e.opf.spineLength() {
+		return nil, errors.New("Spine index out of range")
+	}
+	href := e.opf.spineURL(index)
+	doc, err := e.ParseDocument(href)
+	if err != nil {
+		return nil, err
+	}
+	text := newDocumentText(doc)
+	text.Href = href
+	text.SpineIndex = index
+	text.idref = e.opf.Spine.Items[index].IDref
+	return text, nil
+}
+
+func newDocumentText(doc *html.Node) *DocumentText {
+	b := textBuilder{
+		text:       &DocumentText{anchors: make(map[string]int)},
+		blockStart: -1,
+		blockTag:   "body",
+	}
+	if root := findElement(doc, "html"); root != nil {
+		b.walkChildren(root, "")
+	}
+	b.flushBlock()
+	b.text.Text = b.buf.String()
+	return b.text
+}
+
+// Location maps a byte offset of Text to a position on the markup
+func (d DocumentText) Location(offset int) Location {
+	loc := Location{
+		SpineIndex: d.SpineIndex,
+		IDref:      d.idref,
+		Href:       d.Href,
+	}
+	if len(d.segments) == 0 {
+		loc.Path = "/4"
+		return loc
+	}
+
+	i := sort.Search(len(d.segments), func(i int) bool {
+		return d.segments[i].end > offset
+	})
+	if i == len(d.segments) {
+		seg := d.segments[i-1]
+		loc.Path = seg.path
+		loc.Offset = seg.endOffset
+		return loc
+	}
+
+	seg := d.segments[i]
+	loc.Path = seg.path
+	if offset <= seg.start {
+		loc.Offset = seg.offsets[0]
+		return loc
+	}
+	loc.Offset = seg.offset(d.Text, offset)
+	return loc
+}
+
+// EndLocation maps a byte offset of Text ending a range to a position on the
+// markup
+//
+// Unlike Location an offset at the end of a text node is resolved inside
+// that node and not at the start of the next one.
+func (d DocumentText) EndLocation(offset int) Location {
+	loc := Location{
+		SpineIndex: d.SpineIndex,
+		IDref:      d.idref,
+		Href:       d.Href,
+	}
+	if len(d.segments) == 0 {
+		loc.Path = "/4"
+		return loc
+	}
+
+	i := sort.Search(len(d.segments), func(i int) bool {
+		return d.segments[i].start >= offset
+	}) - 1
+	if i < 0 {
+		seg := d.segments[0]
+		loc.Path = seg.path
+		loc.Offset = seg.offsets[0]
+		return loc
+	}
+
+	seg := d.segments[i]
+	loc.Path = seg.path
+	if offset >= seg.end {
+		loc.Offset = seg.endOffset
+		return loc
+	}
+	loc.Offset = seg.offset(d.Text, offset)
+	return loc
+}
+
+// offset returns the utf16 offset inside the text node of the byte offset of
+// text, it must be inside the segment
+func (s textSegment) offset(text string, offset int) int {
+	runeIndex := utf8.RuneCountInString(text[s.start:offset])
+	return s.offsets[runeIndex]
+}
+
+// AnchorOffset returns the offset on Text where the element with the id starts
+func (d DocumentText) AnchorOffset(id string) (int, bool) {
+	offset, ok := d.anchors[id]
+	return offset, ok
+}
+
+// BlockAt returns the block that contains the offset of Text
+func (d DocumentText) BlockAt(offset int) (TextBlock, bool) {
+	i := sort.Search(len(d.Blocks), func(i int) bool {
+		return d.Blocks[i].End > offset
+	})
+	if i == len(d.Blocks) || d.Blocks[i].Start > offset {
+		return TextBlock{}, false
+	}
+	return d.Blocks[i], true
+}
+
+type textBuilder struct {
+	text       *DocumentText
+	buf        strings.Builder
+	blockStart int
+	blockTag   string
+	space      bool
+	pre        int
+}
+
+func (b *textBuilder) walkChildren(n *html.Node, path string) {
+	elements := 0
+	chunk := 0
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		switch c.Type {
+		case html.ElementNode:
+			elements++
+			chunk = 0
+			step := path + "/" + strconv.Itoa(elements*2)
+			if id := attr(c, "id"); id != "" {
+				step += "[" + id + "]"
+			}
+			b.walkElement(c, step)
+		case html.TextNode:
+			step := path + "/" + strconv.Itoa(elements*2+1)
+			chunk = b.writeText(c.Data, step, chunk)
+		}
+	}
+}
+
+func (b *textBuilder) walkElement(n *html.Node, path string) {
+	if skippedElements[n.Data] {
+		return
+	}
+	if id := attr(n, "id"); id != "" {
+		if _, ok := b.text.anchors[id]; !ok {
+			b.text.anchors[id] = b.nextOffset()
+		}
+	}
+
+	if n.Data == "br" {
+		b.space = b.blockStart >= 0
+		return
+	}
+
+	isBlock := docutil.IsBlockElement(n.Data)
+	parentTag := b.blockTag
+	if isBlock {
+		b.flushBlock()
+		b.blockTag = n.Data
+	}
+	if n.Data == "pre" {
+		b.pre++
+	}
+
+	b.walkChildren(n, path)
+
+	if n.Data == "pre" {
+		b.pre--
+	}
+	if isBlock {
+		b.flushBlock()
+		b.blockTag = parentTag
+	}
+}
+
+// nextOffset returns the offset where the next text written will start
+func (b *textBuilder) nextOffset() int {
+	if b.blockStart < 0 && b.buf.Len() > 0 {
+		return b.buf.Len() + 1
+	}
+	return b.buf.Len()
+}
+
+// writeText writes the text of a node collapsing the white spaces
+//
+// chunk is the utf16 length of the text nodes before this one with no
+// element in between, CFI offsets count from the start of the chunk.
+// Returns the updated chunk length.
+func (b *textBuilder) writeText(data, path string, chunk int) int {
+	seg := textSegment{start: -1, path: path}
+	pos := chunk
+	end := chunk
+	for _, r := range data {
+		runeLen := utf16.RuneLen(r)
+		if runeLen < 0 {
+			runeLen = 1
+		}
+		if b.pre == 0 && unicode.IsSpace(r) {
+			b.space = b.blockStart >= 0
+			pos += runeLen
+			continue
+		}
+
+		if b.blockStart < 0 {
+			if b.buf.Len() > 0 {
+				b.buf.WriteByte('\n')
+			}
+			b.blockStart = b.buf.Len()
+			b.space = false
+		}
+		if seg.start < 0 {
+			seg.start = b.buf.Len()
+		}
+		if b.space {
+			b.buf.WriteByte(' ')
+			seg.offsets = append(seg.offsets, pos)
+			b.space = false
+		}
+		b.buf.WriteRune(r)
+		seg.offsets = append(seg.offsets, pos)
+		pos += runeLen
+		end = pos
+	}
+
+	if seg.start >= 0 {
+		seg.end = b.buf.Len()
+		seg.endOffset = end
+		b.text.segments = append(b.text.segments, seg)
+	}
+	return pos
+}
+
+func (b *textBuilder) flushBlock() {
+	if b.blockStart >= 0 {
+		b.text.Blocks = append(b.text.Blocks, TextBlock{
+			Tag:   b.blockTag,
+			Start: b.blockStart,
+			End:   b.buf.Len(),
+		})
+	}
+	b.blockStart = -1
+	b.space = false
+}
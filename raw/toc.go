@@ -0,0 +1,161 @@
+package raw
+
+// tocTarget is the position on the spine where a navigation point links to
+type tocTarget struct {
+	point    *NavPoint
+	spine    int
+	fragment string
+	offset   int
+}
+
+// tocLocator finds the entries of the index that precede positions of the book
+//
+// The offsets of the fragments are only known once the text of the
+// document is added, until then they point to the start of the document.
+type tocLocator struct {
+	targets []tocTarget
+}
+
+func newTocLocator(e Epub) *tocLocator {
+	var t tocLocator
+	for _, point := range e.NavPoints().flatten() {
+		file, fragment := e.NavPointHref(point)
+		t.targets = append(t.targets, tocTarget{
+			point:    point,
+			spine:    e.opf.spineIndex(file),
+			fragment: fragment,
+		})
+	}
+	return &t
+}
+
+func (t *tocLocator) addDocument(text *DocumentText) {
+	for i := range t.targets {
+		target := &t.targets[i]
+		if target.spine != text.SpineIndex || target.fragment == "" {
+			continue
+		}
+		if offset, ok := text.AnchorOffset(target.fragment); ok {
+			target.offset = offset
+		}
+	}
+}
+
+// before returns the last navigation point at or before the offset of the
+// document on the position spine, nil if there is none
+func (t *tocLocator) before(spine, offset int) *NavPoint {
+	var best *tocTarget
+	for i := range t.targets {
+		target := &t.targets[i]
+		if target.spine < 0 || target.spine > spine {
+			continue
+		}
+		if target.spine == spine && target.offset > offset {
+			continue
+		}
+		if best == nil || target.spine > best.spine ||
+			(target.spine == best.spine && target.offset >= best.offset) {
+			best = target
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return best.point
+}
+
+// NavPointAt returns the closest entry of the index at or before the offset
+// of the text, nil if there is none
+func (e Epub) NavPointAt(text *DocumentText, offset int) *NavPoint {
+	return e.NavPointsAt(text, []int{offset})[0]
+}
+
+// NavPointsAt returns the NavPointAt of each of the offsets of the text,
+// reading the index only once
+func (e Epub) NavPointsAt(text *DocumentText, offsets []int) []*NavPoint {
+	toc := newTocLocator(e)
+	toc.addDocument(text)
+	points := make([]*NavPoint, len(offsets))
+	for i, offset := range offsets {
+		points[i] = toc.before(text.SpineIndex, offset)
+	}
+	return points
+}
+
+// TOCEntryForSpine returns the deepest entry of the index that encloses the
+// position of the spine, and its ancestors from the top level
+//
+// The position is the start of the fragment of the document on the spine
+// index, or the start of the document if fragment is empty or there is no
+// element with that id on it, like a broken link would be followed by the
+// reading systems. Returns nil if the position is before the first entry of
+// the index.
+func (e Epub) TOCEntryForSpine(index int, fragment string) (*NavPoint, NavPointArray) {
+	if index < 0 || index >= e.opf.spineLength() {
+		return nil, nil
+	}
+	toc := newTocLocator(e)
+	offset := 0
+	if text, err := e.SpineText(index); err == nil {
+		toc.addDocument(text)
+		if anchor, ok := text.AnchorOffset(fragment); ok {
+			offset = anchor
+		}
+	}
+	point := toc.before(index, offset)
+	if point == nil {
+		return nil, nil
+	}
+	return point, navAncestors(e.NavPoints(), point)
+}
+
+// navAncestors returns the path from the top level to the parent of point
+func navAncestors(points NavPointArray, point *NavPoint) NavPointArray {
+	for _, np := range points {
+		if np == point {
+			return NavPointArray{}
+		}
+		if ancestors := navAncestors(np.NavPoints, point); ancestors != nil {
+			return append(NavPointArray{np}, ancestors...)
+		}
+	}
+	return nil
+}
+
+// SpineIndexForNavPoint returns the position on the spine of the document
+// the entry of the index links to, or -1 if it's not on the spine
+func (e Epub) SpineIndexForNavPoint(point *NavPoint) int {
+	file, _ := e.NavPointHref(point)
+	if file == "" {
+		return -1
+	}
+	return e.opf.spineIndex(file)
+}
+
+// SpineItemsWithoutTOC returns the positions of the spine that no entry of
+// the index links to
+func (e Epub) SpineItemsWithoutTOC() []int {
+	reached := make(map[int]bool)
+	for _, point := range e.NavPoints().flatten() {
+		reached[e.SpineIndexForNavPoint(point)] = true
+	}
+	unreached := []int{}
+	for i := 0; i < e.opf.spineLength(); i++ {
+		if !reached[i] {
+			unreached = append(unreached, i)
+		}
+	}
+	return unreached
+}
+
+// NavPointsOutsideSpine returns the entries of the index that link to
+// documents that are not on the spine
+func (e Epub) NavPointsOutsideSpine() NavPointArray {
+	outside := NavPointArray{}
+	for _, point := range e.NavPoints().flatten() {
+		if e.SpineIndexForNavPoint(point) < 0 {
+			outside = append(outside, point)
+		}
+	}
+	return outside
+}
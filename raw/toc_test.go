@@ -0,0 +1,68 @@
+package raw
+
+import "testing"
+
+func TestTOCEntryForSpine(t *testing.T) {
+	f, _ := NewEpub(gcdxyPath)
+	defer f.Close()
+
+	point, ancestors := f.TOCEntryForSpine(4, "")
+	if point == nil || point.Title() != "1872年德文版序言" {
+		t.Fatalf("TOCEntryForSpine(4) returned %+v", point)
+	}
+	if len(ancestors) != 1 || ancestors[0].Title() != "共产党宣言" {
+		t.Errorf("The ancestors are %+v", ancestors)
+	}
+	if index := f.SpineIndexForNavPoint(point); index != 4 {
+		t.Errorf("SpineIndexForNavPoint() returned %d", index)
+	}
+
+	point, ancestors = f.TOCEntryForSpine(17, "CHP2-11-1-2")
+	if point == nil || point.Title() != "（2）小资产阶级的社会主义" || len(ancestors) != 3 {
+		t.Errorf("TOCEntryForSpine(17, CHP2-11-1-2) returned %+v %+v", point, ancestors)
+	}
+
+	start, _ := f.TOCEntryForSpine(17, "")
+	if point, _ := f.TOCEntryForSpine(17, "missing"); point == nil || point != start {
+		t.Errorf("TOCEntryForSpine(17, missing) returned %+v, not the entry of the start %+v", point, start)
+	}
+
+	if point, _ := f.TOCEntryForSpine(0, ""); point != nil {
+		t.Errorf("TOCEntryForSpine(0) returned %+v", point)
+	}
+}
+
+func TestNavPointsAt(t *testing.T) {
+	f, _ := NewEpub(gcdxyPath)
+	defer f.Close()
+
+	text, err := f.SpineText(17)
+	if err != nil {
+		t.Fatalf("SpineText(17) returned an error: %v", err)
+	}
+	offset, _ := text.AnchorOffset("CHP2-11-1-2")
+	points := f.NavPointsAt(text, []int{0, offset})
+	if len(points) != 2 || points[1] == nil || points[1].Title() != "（2）小资产阶级的社会主义" {
+		t.Fatalf("NavPointsAt() returned %+v", points)
+	}
+	if point := f.NavPointAt(text, offset); point != points[1] {
+		t.Errorf("NavPointAt() returned %+v", point)
+	}
+}
+
+func TestTOCReports(t *testing.T) {
+	f, _ := NewEpub(bookPath)
+	defer f.Close()
+
+	if unreached := f.SpineItemsWithoutTOC(); len(unreached) != 1 || unreached[0] != 0 {
+		t.Errorf("SpineItemsWithoutTOC() returned %v", unreached)
+	}
+	if outside := f.NavPointsOutsideSpine(); len(outside) != 0 {
+		t.Errorf("NavPointsOutsideSpine() returned %v", outside)
+	}
+
+	f.NCX.NavMap[0].Content.Src = "missing.html"
+	if outside := f.NavPointsOutsideSpine(); len(outside) != 1 || outside[0] != f.NCX.NavMap[0] {
+		t.Errorf("NavPointsOutsideSpine() returned %v", outside)
+	}
+}
@@ -0,0 +1,441 @@
+package raw
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+const opfNamespace = "http://www.idpf.org/2007/opf"
+
+// guideTypes maps the types of the references of the guide to the
+// epub:type of the landmarks
+var guideTypes = map[string]string{
+	"cover":            "cover",
+	"title-page":       "titlepage",
+	"toc":              "toc",
+	"index":            "index",
+	"glossary":         "glossary",
+	"acknowledgements": "acknowledgments",
+	"bibliography":     "bibliography",
+	"colophon":         "colophon",
+	"copyright-page":   "copyright-page",
+	"dedication":       "dedication",
+	"epigraph":         "epigraph",
+	"foreword":         "foreword",
+	"loi":              "loi",
+	"lot":              "lot",
+	"notes":            "endnotes",
+	"preface":          "preface",
+	"text":             "bodymatter",
+	"start":            "bodymatter",
+}
+
+// UpgradeFile writes on the file path the epub upgraded to EPUB 3
+func (e Epub) UpgradeFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := e.Upgrade(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Upgrade writes on w the epub upgraded to EPUB 3
+//
+// A nav document is generated from the NCX, with the pageList as the
+// page-list and the guide as the landmarks. The version of the package is
+// bumped to 3.0, the opf:role, opf:file-as and opf:scheme attributes
+// become refines metas, the extra dates are dropped and the properties of
+// the manifest are set scanning the content. The NCX stays for the old
+// reading systems.
+func (e Epub) Upgrade(w io.Writer) error {
+	if strings.HasPrefix(e.opf.Version, "3") {
+		return errors.New("The epub is already an EPUB 3")
+	}
+	opfPath, err := e.getOpfPath()
+	if err != nil {
+		return err
+	}
+	f, err := e.reader.OpenFile(opfPath)
+	if err != nil {
+		return err
+	}
+	doc, err := parseXMLTree(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	pkg := doc.root()
+	if pkg == nil || pkg.element("metadata") == nil || pkg.element("manifest") == nil {
+		return errors.New("The opf file has no metadata or manifest")
+	}
+	pkg.setAttr("version", "3.0")
+
+	ids := newIDSet(doc)
+	upgradeMetadata(pkg, ids, time.Now())
+	e.setManifestProperties(pkg)
+
+	navHref := e.newFileName("nav.xhtml")
+	item := newXMLElement(qualifiedName(xml.Name{Space: pkg.name.Space, Local: "item"}),
+		"id", ids.unique("nav"),
+		"href", navHref,
+		"media-type", "application/xhtml+xml",
+		"properties", "nav")
+	pkg.element("manifest").appendIndented(item)
+
+	changes := map[string][]byte{
+		opfPath:                        doc.bytes(),
+		path.Join(e.rootPath, navHref): e.navDocument(),
+	}
+	return e.writeEpub(w, changes)
+}
+
+// upgradeMetadata converts the EPUB 2 attributes of the metadata into
+// refines metas and sets the dcterms:modified
+//
+// EPUB 3 allows a single dc:date, the date of publication: the date with
+// the opf:event publication stays, or else the first one without event or
+// the first one. A creation date becomes
+// a dcterms:created meta and the rest of the dates are dropped. Any opf
+// attribute left over is removed.
+func upgradeMetadata(pkg *xmlNode, ids idSet, modified time.Time) {
+	metadata := pkg.element("metadata")
+	metaName := qualifiedName(xml.Name{Space: pkg.name.Space, Local: "meta"})
+	metas := []*xmlNode{}
+	refine := func(el, meta *xmlNode, value string) {
+		if el.attr("id") == "" {
+			el.setAttr("id", ids.unique(el.name.Local))
+		}
+		meta.attrs = append([]xml.Attr{{Name: xml.Name{Local: "refines"}, Value: "#" + el.attr("id")}}, meta.attrs...)
+		meta.setText(value)
+		metas = append(metas, meta)
+	}
+
+	dates := metadata.elements("date")
+	publication := -1
+	for i, el := range dates {
+		event := opfAttr(el, "event")
+		if event == "publication" {
+			publication = i
+			break
+		}
+		if event == "" && publication < 0 {
+			publication = i
+		}
+	}
+	if publication < 0 {
+		publication = 0
+	}
+	for i, el := range dates {
+		if i == publication {
+			continue
+		}
+		if opfAttr(el, "event") == "creation" {
+			meta := newXMLElement(metaName, "property", "dcterms:created")
+			meta.setText(strings.TrimSpace(el.text()))
+			metas = append(metas, meta)
+		}
+		metadata.removeChild(el)
+	}
+
+	for _, el := range metadata.elements("") {
+		for _, a := range opfAttrs(el) {
+			switch {
+			case a.Name.Local == "role" && (el.name.Local == "creator" || el.name.Local == "contributor"):
+				refine(el, newXMLElement(metaName, "property", "role", "scheme", "marc:relators"), a.Value)
+			case a.Name.Local == "file-as" && (el.name.Local == "creator" || el.name.Local == "contributor"):
+				refine(el, newXMLElement(metaName, "property", "file-as"), a.Value)
+			case a.Name.Local == "scheme" && el.name.Local == "identifier":
+				refine(el, newXMLElement(metaName, "property", "identifier-type"), a.Value)
+			}
+			el.removeAttr(a.Name)
+		}
+	}
+	for _, meta := range metas {
+		metadata.appendIndented(meta)
+	}
+
+	for _, el := range metadata.elements("meta") {
+		if el.attr("property") == "dcterms:modified" {
+			metadata.removeChild(el)
+		}
+	}
+	meta := newXMLElement(metaName, "property", "dcterms:modified")
+	meta.setText(modified.UTC().Format("2006-01-02T15:04:05Z"))
+	metadata.appendIndented(meta)
+}
+
+// opfAttrs returns the attributes of the element on the opf namespace
+func opfAttrs(el *xmlNode) []xml.Attr {
+	attrs := []xml.Attr{}
+	for _, a := range el.attrs {
+		if a.Name.Space != "" && el.namespace(a.Name.Space) == opfNamespace {
+			attrs = append(attrs, a)
+		}
+	}
+	return attrs
+}
+
+// opfAttr returns the value of the attribute on the opf namespace with the
+// local name
+func opfAttr(el *xmlNode, local string) string {
+	for _, a := range opfAttrs(el) {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// setManifestProperties sets the cover-image, scripted, svg and mathml
+// properties of the items of the manifest
+func (e Epub) setManifestProperties(pkg *xmlNode) {
+	cover := ""
+	for _, m := range e.opf.Metadata.Meta {
+		if m.Name == "cover" {
+			cover = m.Content
+		}
+	}
+
+	for _, item := range pkg.element("manifest").elements("item") {
+		properties := strings.Fields(item.attr("properties"))
+		if item.attr("id") == cover && strings.HasPrefix(item.attr("media-type"), "image/") {
+			properties = append(properties, "cover-image")
+		}
+		if isTextContent(item.attr("media-type")) {
+			if doc, err := e.ParseDocument(item.attr("href")); err == nil {
+				properties = append(properties, contentProperties(doc)...)
+			}
+		}
+		if len(properties) > 0 {
+			item.setAttr("properties", strings.Join(uniqueStrings(properties), " "))
+		}
+	}
+}
+
+// contentProperties returns the properties of the manifest needed by the
+// content document
+func contentProperties(doc *html.Node) []string {
+	found := map[string]bool{}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch {
+			case n.Namespace == "svg":
+				found["svg"] = true
+			case n.Namespace == "math":
+				found["mathml"] = true
+			}
+			if n.Data == "script" || (n.Data == "form" && n.Namespace == "") {
+				found["scripted"] = true
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	properties := []string{}
+	for property := range found {
+		properties = append(properties, property)
+	}
+	sort.Strings(properties)
+	return properties
+}
+
+func uniqueStrings(list []string) []string {
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, s := range list {
+		if !seen[s] {
+			seen[s] = true
+			unique = append(unique, s)
+		}
+	}
+	return unique
+}
+
+// newFileName returns name, or a variation of it, that is not used by any
+// file of the epub
+func (e Epub) newFileName(name string) string {
+	used := map[string]bool{}
+	for _, f := range e.fileNames() {
+		used[strings.ToLower(f)] = true
+	}
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 2; used[strings.ToLower(path.Join(e.rootPath, name))]; i++ {
+		name = base + "-" + strconv.Itoa(i) + ext
+	}
+	return name
+}
+
+// idSet are the ids used on a document
+type idSet map[string]bool
+
+func newIDSet(doc *xmlNode) idSet {
+	ids := idSet{}
+	var walk func(n *xmlNode)
+	walk = func(n *xmlNode) {
+		if id := n.attr("id"); id != "" {
+			ids[id] = true
+		}
+		for _, c := range n.elements("") {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return ids
+}
+
+// unique returns a new id starting by prefix and registers it
+func (ids idSet) unique(prefix string) string {
+	id := prefix
+	for i := 1; ids[id]; i++ {
+		id = prefix + strconv.Itoa(i)
+	}
+	ids[id] = true
+	return id
+}
+
+// navDocument generates the EPUB 3 navigation document
+//
+// The document is expected to be on the same folder than the opf file.
+func (e Epub) navDocument() []byte {
+	title := ""
+	if len(e.opf.Metadata.Title) > 0 {
+		title = strings.TrimSpace(e.opf.Metadata.Title[0])
+	}
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	b.WriteString("<!DOCTYPE html>\n")
+	b.WriteString(`<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops"`)
+	if len(e.opf.Metadata.Language) > 0 {
+		lang := html.EscapeString(strings.TrimSpace(e.opf.Metadata.Language[0]))
+		b.WriteString(` lang="` + lang + `" xml:lang="` + lang + `"`)
+	}
+	b.WriteString(">\n<head>\n<meta charset=\"utf-8\"/>\n")
+	b.WriteString("<title>" + html.EscapeString(title) + "</title>\n</head>\n<body>\n")
+
+	b.WriteString("<nav epub:type=\"toc\" id=\"toc\">\n")
+	if title != "" {
+		b.WriteString("<h1>" + html.EscapeString(title) + "</h1>\n")
+	}
+	if e.NCX != nil && len(e.NCX.NavMap) > 0 {
+		e.writeNavList(&b, e.NCX.NavMap)
+	} else {
+		e.writeSpineList(&b)
+	}
+	b.WriteString("</nav>\n")
+
+	if e.NCX != nil && len(e.NCX.PageList) > 0 {
+		b.WriteString("<nav epub:type=\"page-list\" id=\"page-list\" hidden=\"hidden\">\n<ol>\n")
+		for _, page := range e.NCX.PageList {
+			file, fragment := splitFragment(page.Content.Src)
+			if file != "" {
+				file = resolveHref(e.ncxPath, file)
+			}
+			writeNavLink(&b, escapeHref(file, fragment), strings.TrimSpace(page.Title()), "")
+			b.WriteString("</li>\n")
+		}
+		b.WriteString("</ol>\n</nav>\n")
+	}
+
+	landmarks := []reference{}
+	for _, ref := range e.opf.Guide {
+		if _, ok := guideTypes[ref.Type]; ok && ref.Href != "" {
+			landmarks = append(landmarks, ref)
+		}
+	}
+	if len(landmarks) > 0 {
+		b.WriteString("<nav epub:type=\"landmarks\" id=\"landmarks\" hidden=\"hidden\">\n<ol>\n")
+		for _, ref := range landmarks {
+			label := ref.Title
+			if label == "" {
+				label = ref.Type
+			}
+			writeNavLink(&b, ref.Href, label, guideTypes[ref.Type])
+			b.WriteString("</li>\n")
+		}
+		b.WriteString("</ol>\n</nav>\n")
+	}
+	b.WriteString("</body>\n</html>\n")
+	return []byte(b.String())
+}
+
+func (e Epub) writeNavList(b *strings.Builder, points NavPointArray) {
+	b.WriteString("<ol>\n")
+	for _, point := range points {
+		file, fragment := e.NavPointHref(point)
+		href := ""
+		if file != "" || fragment != "" {
+			href = escapeHref(file, fragment)
+		}
+		if href == "" && len(point.NavPoints) == 0 {
+			continue
+		}
+		writeNavLink(b, href, strings.TrimSpace(point.Title()), "")
+		if len(point.NavPoints) > 0 {
+			b.WriteString("\n")
+			e.writeNavList(b, point.NavPoints)
+		}
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</ol>\n")
+}
+
+// writeSpineList writes a table of contents with the documents of the
+// spine, for the epubs without NCX
+func (e Epub) writeSpineList(b *strings.Builder) {
+	b.WriteString("<ol>\n")
+	for i := range e.opf.Spine.Items {
+		href := e.opf.spineURL(i)
+		title := ""
+		if doc, err := e.ParseDocument(href); err == nil {
+			if el := findElement(doc, "title"); el != nil && el.FirstChild != nil {
+				title = strings.TrimSpace(el.FirstChild.Data)
+			}
+		}
+		if title == "" {
+			title = path.Base(cleanHref(href))
+		}
+		writeNavLink(b, href, title, "")
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</ol>\n")
+}
+
+// writeNavLink opens an item of a nav list with a link, or a span if there
+// is no href
+func writeNavLink(b *strings.Builder, href, label, epubType string) {
+	b.WriteString("<li>")
+	if href == "" {
+		b.WriteString("<span>" + html.EscapeString(label) + "</span>")
+		return
+	}
+	b.WriteString("<a")
+	if epubType != "" {
+		b.WriteString(` epub:type="` + html.EscapeString(epubType) + `"`)
+	}
+	b.WriteString(` href="` + html.EscapeString(href) + `">` + html.EscapeString(label) + "</a>")
+}
+
+// escapeHref builds the url of a file and a fragment
+func escapeHref(file, fragment string) string {
+	u := url.URL{Path: file, Fragment: fragment}
+	return u.String()
+}
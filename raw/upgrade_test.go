@@ -0,0 +1,235 @@
+package raw
+
+import (
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestUpgrade(t *testing.T) {
+	f, _ := NewEpub(bookPath)
+	defer f.Close()
+
+	upgraded := filepath.Join(t.TempDir(), "upgraded.epub")
+	if err := f.UpgradeFile(upgraded); err != nil {
+		t.Fatalf("UpgradeFile() return an error: %v", err)
+	}
+	e, err := NewEpub(upgraded)
+	if err != nil {
+		t.Fatalf("Can't open the upgraded epub: %v", err)
+	}
+	defer e.Close()
+
+	if e.Version() != "3.0" {
+		t.Errorf("The version is '%v'", e.Version())
+	}
+	if err := e.Upgrade(nil); err == nil {
+		t.Errorf("Upgrade() didn't fail on an EPUB 3")
+	}
+	if len(e.NavPoints()) != len(f.NavPoints()) {
+		t.Errorf("The NCX was not kept")
+	}
+
+	var nav *manifest
+	for _, item := range e.opf.Manifest {
+		if item.Properties == "nav" {
+			nav = item
+		}
+	}
+	if nav == nil {
+		t.Fatalf("There is no nav on the manifest")
+	}
+	if cover := e.FileManifest(e.GetFileHrefByID("coverpage")); cover.Properties != "cover-image" {
+		t.Errorf("The properties of the cover are '%v'", cover.Properties)
+	}
+
+	doc, err := e.ParseDocument(nav.Href)
+	if err != nil {
+		t.Fatalf("Can't parse the nav: %v", err)
+	}
+	links := 0
+	landmarks := 0
+	var walk func(n *html.Node, landmark bool)
+	walk = func(n *html.Node, landmark bool) {
+		if n.Data == "nav" && attr(n, "epub:type") == "landmarks" {
+			landmark = true
+		}
+		if n.Data == "a" {
+			if landmark {
+				landmarks++
+				if attr(n, "epub:type") != "cover" || attr(n, "href") != "wrap0000.html" {
+					t.Errorf("The landmark is %v", n.Attr)
+				}
+			} else {
+				links++
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c, landmark)
+		}
+	}
+	walk(doc, false)
+	if links != len(f.NavPoints().flatten()) || landmarks != 1 {
+		t.Errorf("The nav has %d links and %d landmarks", links, landmarks)
+	}
+
+	modified := false
+	fileAs := false
+	for _, m := range e.opf.Metadata.Meta {
+		switch {
+		case m.Property == "dcterms:modified" && strings.HasSuffix(m.Data, "Z"):
+			modified = true
+		case m.Property == "file-as" && m.Data == "Twain, Mark" && m.Refines == "#"+e.opf.Metadata.Creator[0].ID:
+			fileAs = true
+		}
+	}
+	if !modified {
+		t.Errorf("There is no dcterms:modified on %v", e.opf.Metadata.Meta)
+	}
+	if !fileAs || e.opf.Metadata.Creator[0].FileAs != "" {
+		t.Errorf("The opf:file-as of the creator didn't become a meta: %+v %v", e.opf.Metadata.Creator[0], e.opf.Metadata.Meta)
+	}
+	metas, _ := e.MetadataAttr("meta")
+	if len(metas) != 4 || metas[2]["property"] != "file-as" || metas[2]["refines"] != "#"+e.opf.Metadata.Creator[0].ID {
+		t.Errorf("The attributes of the metas are %v", metas)
+	}
+	if metas[1]["property"] != "identifier-type" || metas[1]["refines"] != "#id" {
+		t.Errorf("The opf:scheme of the identifier didn't become a meta: %v", metas[1])
+	}
+	if dates, _ := e.Metadata("date"); len(dates) != 1 || dates[0] != "2004-06-01" {
+		t.Errorf("The dates are %v", dates)
+	}
+	checkNoOPFAttrs(t, e)
+}
+
+// checkNoOPFAttrs checks the metadata of the upgraded epub has no opf
+// attribute left
+func checkNoOPFAttrs(t *testing.T, e *Epub) {
+	t.Helper()
+	opfPath, _ := e.getOpfPath()
+	f, err := e.reader.OpenFile(opfPath)
+	if err != nil {
+		t.Fatalf("Can't open the opf: %v", err)
+	}
+	doc, err := parseXMLTree(f)
+	f.Close()
+	if err != nil {
+		t.Fatalf("Can't parse the opf: %v", err)
+	}
+	for _, el := range doc.root().element("metadata").elements("") {
+		if attrs := opfAttrs(el); len(attrs) != 0 {
+			t.Errorf("The element %v has the opf attributes %v", el.name.Local, attrs)
+		}
+	}
+}
+
+// listlessReader is a reader that can't list its files
+type listlessReader struct {
+	files memReader
+}
+
+func (r listlessReader) OpenFile(name string) (io.ReadCloser, error) {
+	return r.files.OpenFile(name)
+}
+
+func (r listlessReader) Close() {}
+
+func TestUpgradeProperties(t *testing.T) {
+	const opf = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="2.0" unique-identifier="id">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:opf="http://www.idpf.org/2007/opf">
+<dc:identifier id="id">urn:uuid:0b5e5c3c-7b37-4b3a-9d8e-2d5b0c9b6e11</dc:identifier>
+<dc:title>Properties</dc:title>
+<dc:creator opf:role="ill" opf:file-as="Doe, Jane">Jane Doe</dc:creator>
+<dc:identifier opf:scheme="ISBN">9780000000002</dc:identifier>
+<dc:date opf:event="creation">2001-01-01</dc:date>
+<dc:date opf:event="modification">2003-03-03</dc:date>
+<dc:date>2002-02-02</dc:date>
+<dc:subject opf:authority="BISAC">Fiction</dc:subject>
+<meta name="generator" content="test"/>
+</metadata>
+<manifest>
+<item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+<item id="c1" href="c1.xhtml" media-type="application/xhtml+xml"/>
+<item id="c2" href="c2.xhtml" media-type="application/xhtml+xml"/>
+</manifest>
+<spine toc="ncx"><itemref idref="c1"/><itemref idref="c2"/></spine>
+</package>`
+	const ncx = `<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1"><navMap>
+<navPoint id="p1"><navLabel><text>One</text></navLabel><content src="c1.xhtml"/></navPoint>
+</navMap></ncx>`
+	const c1 = `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml"><head><script src="a.js"></script></head><body>
+<svg xmlns="http://www.w3.org/2000/svg"><circle r="1"/></svg></body></html>`
+	const c2 = `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml"><body>
+<math xmlns="http://www.w3.org/1998/Math/MathML"><mi>x</mi></math></body></html>`
+
+	files := memReader{
+		"mimetype":               []byte("application/epub+zip"),
+		"META-INF/container.xml": []byte(`<container><rootfiles><rootfile full-path="content.opf"/></rootfiles></container>`),
+		"content.opf":            []byte(opf),
+		"toc.ncx":                []byte(ncx),
+		"c1.xhtml":               []byte(c1),
+		"c2.xhtml":               []byte(c2),
+	}
+	listless := &Epub{reader: listlessReader{files}}
+	if err := listless.parseFiles(); err != nil {
+		t.Fatalf("parseFiles() return an error: %v", err)
+	}
+	if err := listless.Upgrade(ioutil.Discard); err == nil {
+		t.Errorf("Upgrade() didn't fail with a reader that can't list the files")
+	}
+
+	e := &Epub{reader: files}
+	if err := e.parseFiles(); err != nil {
+		t.Fatalf("parseFiles() return an error: %v", err)
+	}
+	upgraded := filepath.Join(t.TempDir(), "upgraded.epub")
+	if err := e.UpgradeFile(upgraded); err != nil {
+		t.Fatalf("UpgradeFile() return an error: %v", err)
+	}
+	u, err := NewEpub(upgraded)
+	if err != nil {
+		t.Fatalf("Can't open the upgraded epub: %v", err)
+	}
+	defer u.Close()
+
+	for id, properties := range map[string]string{"c1": "scripted svg", "c2": "mathml"} {
+		if item := u.FileManifest(u.GetFileHrefByID(id)); item == nil || item.Properties != properties {
+			t.Errorf("The properties of %s are %+v", id, item)
+		}
+	}
+	creator := u.opf.Metadata.Creator[0]
+	refines := map[string]string{}
+	for _, m := range u.opf.Metadata.Meta {
+		if m.Refines == "#"+creator.ID {
+			refines[m.Property] = m.Data + " " + m.Scheme
+		}
+	}
+	if creator.ID == "" || creator.Role != "" || refines["role"] != "ill marc:relators" || refines["file-as"] != "Doe, Jane " {
+		t.Errorf("The creator is %+v with the metas %v", creator, refines)
+	}
+	if generator, _ := u.Metadata("meta"); len(generator) == 0 || generator[0] != "test" {
+		t.Errorf("The content of the metas is %v", generator)
+	}
+	if dates, _ := u.Metadata("date"); len(dates) != 1 || dates[0] != "2002-02-02" {
+		t.Errorf("The dates are %v", dates)
+	}
+	isbn := u.opf.Metadata.Identifier[1]
+	metas := map[string]string{}
+	for _, m := range u.opf.Metadata.Meta {
+		if m.Refines == "" || m.Refines == "#"+isbn.ID {
+			metas[m.Property] = m.Data
+		}
+	}
+	if isbn.ID == "" || metas["identifier-type"] != "ISBN" || metas["dcterms:created"] != "2001-01-01" {
+		t.Errorf("The identifier is %+v with the metas %v", isbn, metas)
+	}
+	checkNoOPFAttrs(t, u)
+}
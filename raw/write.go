@@ -0,0 +1,88 @@
+package raw
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"sort"
+	"time"
+)
+
+// writeEpub writes the files of the epub on w as a new epub
+//
+// The files on changes, by their path on the zip, replace the ones of the
+// epub or are added at the end. A nil content removes the file.
+// Returns an error if the reader can't list the files, the files out of the
+// manifest would be lost.
+func (e Epub) writeEpub(w io.Writer, changes map[string][]byte) error {
+	if _, ok := e.reader.(FileLister); !ok {
+		return errors.New("The reader can't list the files of the epub")
+	}
+	zw := zip.NewWriter(w)
+	now := time.Now()
+
+	// the mimetype goes first and uncompressed
+	mimetype, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store, Modified: now})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mimetype, "application/epub+zip"); err != nil {
+		return err
+	}
+
+	written := map[string]bool{"mimetype": true}
+	for _, name := range e.fileNames() {
+		if written[name] {
+			continue
+		}
+		written[name] = true
+		if content, ok := changes[name]; ok {
+			if content != nil {
+				if err := writeZipFile(zw, name, content, now); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := e.copyZipFile(zw, name, now); err != nil {
+			return err
+		}
+	}
+
+	names := []string{}
+	for name, content := range changes {
+		if !written[name] && content != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := writeZipFile(zw, name, changes[name], now); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func (e Epub) copyZipFile(zw *zip.Writer, name string, modified time.Time) error {
+	f, err := e.reader.OpenFile(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dst, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, f)
+	return err
+}
+
+func writeZipFile(zw *zip.Writer, name string, content []byte, modified time.Time) error {
+	dst, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return err
+	}
+	_, err = dst.Write(content)
+	return err
+}
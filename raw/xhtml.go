@@ -0,0 +1,131 @@
+package raw
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// elementNamespaces are the namespaces of the elements by the Namespace of
+// the parsed tree
+var elementNamespaces = map[string]string{
+	"":     "http://www.w3.org/1999/xhtml",
+	"svg":  "http://www.w3.org/2000/svg",
+	"math": "http://www.w3.org/1998/Math/MathML",
+}
+
+// voidElements are the HTML elements that can't have content
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"param": true, "source": true, "track": true, "wbr": true,
+}
+
+// renderXHTML writes the tree of doc as an XHTML document
+//
+// Unlike html.Render the output is well formed XML: empty elements are
+// closed and the namespaces of the elements and of the prefixed attributes
+// are declared, so the documents parsed by the HTML parser can be read back
+// as XHTML.
+func renderXHTML(w io.Writer, doc *html.Node) error {
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	for c := doc.FirstChild; c != nil; c = c.NextSibling {
+		writeXHTML(&b, c, "-")
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func writeXHTML(b *bytes.Buffer, n *html.Node, parentNamespace string) {
+	switch n.Type {
+	case html.DoctypeNode:
+		b.WriteString("<!DOCTYPE " + n.Data)
+		public, system := attr(n, "public"), attr(n, "system")
+		switch {
+		case public != "":
+			b.WriteString(` PUBLIC "` + public + `" "` + system + `"`)
+		case system != "":
+			b.WriteString(` SYSTEM "` + system + `"`)
+		}
+		b.WriteString(">\n")
+	case html.CommentNode:
+		b.WriteString("<!--" + strings.Replace(n.Data, "--", "- -", -1) + "-->")
+	case html.TextNode:
+		b.WriteString(html.EscapeString(n.Data))
+	case html.ElementNode:
+		b.WriteString("<" + n.Data)
+		if n.Namespace != parentNamespace {
+			b.WriteString(` xmlns="` + elementNamespaces[n.Namespace] + `"`)
+		}
+		if parentNamespace == "-" {
+			writePrefixDeclarations(b, n)
+		}
+		for _, a := range n.Attr {
+			if a.Namespace == "" && a.Key == "xmlns" {
+				continue
+			}
+			key := a.Key
+			if a.Namespace != "" {
+				key = a.Namespace + ":" + key
+			}
+			b.WriteString(" " + key + `="` + html.EscapeString(a.Val) + `"`)
+		}
+		switch {
+		case n.FirstChild != nil:
+			b.WriteString(">")
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				writeXHTML(b, c, n.Namespace)
+			}
+			b.WriteString("</" + n.Data + ">")
+		case n.Namespace != "" || voidElements[n.Data]:
+			b.WriteString("/>")
+		default:
+			b.WriteString("></" + n.Data + ">")
+		}
+	}
+}
+
+// writePrefixDeclarations declares on the root element the well known
+// prefixes used by the attributes of the tree that are not declared on it
+func writePrefixDeclarations(b *bytes.Buffer, root *html.Node) {
+	declared := map[string]bool{"xml": true, "xmlns": true}
+	for _, a := range root.Attr {
+		if strings.HasPrefix(a.Key, "xmlns:") {
+			declared[strings.TrimPrefix(a.Key, "xmlns:")] = true
+		} else if a.Namespace == "xmlns" {
+			declared[a.Key] = true
+		}
+	}
+	used := map[string]bool{}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for _, a := range n.Attr {
+			prefix := a.Namespace
+			if i := strings.Index(a.Key, ":"); prefix == "" && i > 0 {
+				prefix = a.Key[:i]
+			}
+			if prefix != "" && !declared[prefix] {
+				used[prefix] = true
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+
+	namespaces := []string{}
+	for namespace, prefix := range namespacePrefixes {
+		if used[prefix] {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	sort.Strings(namespaces)
+	for _, namespace := range namespaces {
+		b.WriteString(" xmlns:" + namespacePrefixes[namespace] + `="` + namespace + `"`)
+	}
+}
@@ -0,0 +1,303 @@
+package raw
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+// xmlNode is a node of an xml document that can be modified and written
+// back without losing the prefixes, comments or declarations of the original
+//
+// Elements have a name, the rest of the nodes keep their token. Like on
+// xml.Decoder.RawToken the Space of the names is the prefix, not the
+// namespace.
+type xmlNode struct {
+	name     xml.Name
+	attrs    []xml.Attr
+	children []*xmlNode
+	parent   *xmlNode
+	token    xml.Token
+}
+
+// parseXMLTree parses the whole document, the returned node is the document
+// itself with the root element among its children
+func parseXMLTree(r io.Reader) (*xmlNode, error) {
+	decoder := xml.NewDecoder(r)
+	decoder.Entity = xml.HTMLEntity
+	decoder.CharsetReader = charset.NewReaderLabel
+
+	doc := &xmlNode{}
+	curr := doc
+	for {
+		tok, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			node := &xmlNode{name: t.Name, attrs: append([]xml.Attr{}, t.Attr...)}
+			curr.appendChild(node)
+			curr = node
+		case xml.EndElement:
+			if curr.parent != nil {
+				curr = curr.parent
+			}
+		default:
+			curr.appendChild(&xmlNode{token: xml.CopyToken(tok)})
+		}
+	}
+	return doc, nil
+}
+
+// newXMLElement creates an element, attrs are pairs of name and value
+func newXMLElement(name string, attrs ...string) *xmlNode {
+	node := &xmlNode{name: xmlName(name)}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		node.setAttr(attrs[i], attrs[i+1])
+	}
+	return node
+}
+
+// xmlName converts a prefixed name like "dc:title" into an xml.Name
+func xmlName(name string) xml.Name {
+	if i := strings.Index(name, ":"); i >= 0 {
+		return xml.Name{Space: name[:i], Local: name[i+1:]}
+	}
+	return xml.Name{Local: name}
+}
+
+func (n *xmlNode) isElement() bool {
+	return n.name.Local != ""
+}
+
+// root returns the root element of the document
+func (n *xmlNode) root() *xmlNode {
+	for _, c := range n.children {
+		if c.isElement() {
+			return c
+		}
+	}
+	return nil
+}
+
+// elements returns the children elements with the local name, all of them
+// if local is empty
+func (n *xmlNode) elements(local string) []*xmlNode {
+	elements := []*xmlNode{}
+	for _, c := range n.children {
+		if c.isElement() && (local == "" || c.name.Local == local) {
+			elements = append(elements, c)
+		}
+	}
+	return elements
+}
+
+// element returns the first child element with the local name, or nil
+func (n *xmlNode) element(local string) *xmlNode {
+	if elements := n.elements(local); len(elements) > 0 {
+		return elements[0]
+	}
+	return nil
+}
+
+// text returns the text content of the element
+func (n *xmlNode) text() string {
+	var b strings.Builder
+	for _, c := range n.children {
+		if data, ok := c.token.(xml.CharData); ok {
+			b.Write(data)
+		}
+		if c.isElement() {
+			b.WriteString(c.text())
+		}
+	}
+	return b.String()
+}
+
+// setText replaces the content of the element by the text
+func (n *xmlNode) setText(text string) {
+	n.children = nil
+	n.appendChild(&xmlNode{token: xml.CharData(text)})
+}
+
+// attr returns the value of the attribute name, like "id" or "opf:role"
+func (n *xmlNode) attr(name string) string {
+	key := xmlName(name)
+	for _, a := range n.attrs {
+		if a.Name == key {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// setAttr sets the attribute name, adding it if it's missing
+func (n *xmlNode) setAttr(name, value string) {
+	key := xmlName(name)
+	for i, a := range n.attrs {
+		if a.Name == key {
+			n.attrs[i].Value = value
+			return
+		}
+	}
+	n.attrs = append(n.attrs, xml.Attr{Name: key, Value: value})
+}
+
+// removeAttr removes the attribute, returns whether it was there
+func (n *xmlNode) removeAttr(name xml.Name) bool {
+	for i, a := range n.attrs {
+		if a.Name == name {
+			n.attrs = append(n.attrs[:i], n.attrs[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// namespace returns the namespace bound to the prefix on the scope of the
+// node, the default namespace if prefix is empty
+func (n *xmlNode) namespace(prefix string) string {
+	switch prefix {
+	case "xml":
+		return "http://www.w3.org/XML/1998/namespace"
+	case "xmlns":
+		return "http://www.w3.org/2000/xmlns/"
+	}
+	for node := n; node != nil; node = node.parent {
+		for _, a := range node.attrs {
+			if prefix == "" && a.Name.Space == "" && a.Name.Local == "xmlns" ||
+				prefix != "" && a.Name.Space == "xmlns" && a.Name.Local == prefix {
+				return a.Value
+			}
+		}
+	}
+	return ""
+}
+
+func (n *xmlNode) appendChild(child *xmlNode) {
+	child.parent = n
+	n.children = append(n.children, child)
+}
+
+// removeChild removes the child and the indentation before it
+func (n *xmlNode) removeChild(child *xmlNode) {
+	for i, c := range n.children {
+		if c != child {
+			continue
+		}
+		start := i
+		if i > 0 && isWhitespaceNode(n.children[i-1]) {
+			start--
+		}
+		n.children = append(n.children[:start], n.children[i+1:]...)
+		child.parent = nil
+		return
+	}
+}
+
+// appendIndented appends the child element with the same indentation than
+// the last element of n
+func (n *xmlNode) appendIndented(child *xmlNode) {
+	indent := "\n"
+	var closing xml.Token
+	if len(n.children) > 0 && isWhitespaceNode(n.children[len(n.children)-1]) {
+		closing = n.children[len(n.children)-1].token
+		n.children = n.children[:len(n.children)-1]
+	}
+	for i := len(n.children) - 1; i > 0; i-- {
+		if n.children[i].isElement() && isWhitespaceNode(n.children[i-1]) {
+			indent = string(n.children[i-1].token.(xml.CharData))
+			break
+		}
+	}
+	n.appendChild(&xmlNode{token: xml.CharData(indent)})
+	n.appendChild(child)
+	if closing == nil {
+		closing = xml.CharData("\n")
+	}
+	n.appendChild(&xmlNode{token: closing})
+}
+
+func isWhitespaceNode(n *xmlNode) bool {
+	data, ok := n.token.(xml.CharData)
+	return ok && len(bytes.TrimSpace(data)) == 0
+}
+
+var encodingRegexp = regexp.MustCompile(`encoding\s*=\s*("[^"]*"|'[^']*')`)
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;",
+		"\n", "&#xA;", "\r", "&#xD;", "\t", "&#x9;")
+)
+
+// bytes serializes the tree as UTF-8
+func (n *xmlNode) bytes() []byte {
+	var b bytes.Buffer
+	n.write(&b)
+	return b.Bytes()
+}
+
+func (n *xmlNode) write(b *bytes.Buffer) {
+	switch t := n.token.(type) {
+	case xml.CharData:
+		textEscaper.WriteString(b, string(t))
+		return
+	case xml.Comment:
+		b.WriteString("<!--")
+		b.Write(t)
+		b.WriteString("-->")
+		return
+	case xml.ProcInst:
+		inst := string(t.Inst)
+		if t.Target == "xml" {
+			// the content was decoded, it's written back as UTF-8
+			inst = encodingRegexp.ReplaceAllString(inst, `encoding="UTF-8"`)
+		}
+		b.WriteString("<?" + t.Target + " " + inst + "?>")
+		return
+	case xml.Directive:
+		b.WriteString("<!")
+		b.Write(t)
+		b.WriteString(">")
+		return
+	}
+
+	if !n.isElement() {
+		for _, c := range n.children {
+			c.write(b)
+		}
+		return
+	}
+	b.WriteString("<" + qualifiedName(n.name))
+	for _, a := range n.attrs {
+		b.WriteString(" " + qualifiedName(a.Name) + `="`)
+		attrEscaper.WriteString(b, a.Value)
+		b.WriteString(`"`)
+	}
+	if len(n.children) == 0 {
+		b.WriteString("/>")
+		return
+	}
+	b.WriteString(">")
+	for _, c := range n.children {
+		c.write(b)
+	}
+	b.WriteString("</" + qualifiedName(n.name) + ">")
+}
+
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
@@ -0,0 +1,47 @@
+package raw
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestXMLTreeRoundTrip(t *testing.T) {
+	const opf = `<?xml version='1.0' encoding='UTF-8'?>
+<!-- generated -->
+<package xmlns:opf="http://www.idpf.org/2007/opf" xmlns="http://www.idpf.org/2007/opf" version="2.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:creator opf:role="aut">Tom &amp; Jerry</dc:creator>
+    <meta content="cover" name="cover"/>
+  </metadata>
+</package>`
+
+	doc, err := parseXMLTree(strings.NewReader(opf))
+	if err != nil {
+		t.Fatalf("parseXMLTree() return an error: %v", err)
+	}
+	if out := string(doc.bytes()); out != strings.Replace(opf, "'UTF-8'", `"UTF-8"`, 1) {
+		t.Errorf("The document changed writing it back:\n%v", out)
+	}
+
+	metadata := doc.root().element("metadata")
+	creator := metadata.element("creator")
+	if creator.namespace(creator.name.Space) != "http://purl.org/dc/elements/1.1/" {
+		t.Errorf("The namespace of dc is '%v'", creator.namespace(creator.name.Space))
+	}
+	if creator.namespace("opf") != opfNamespace {
+		t.Errorf("The namespace of opf is '%v'", creator.namespace("opf"))
+	}
+	if creator.text() != "Tom & Jerry" {
+		t.Errorf("The text of the creator is '%v'", creator.text())
+	}
+
+	metadata.removeChild(metadata.element("meta"))
+	metadata.appendIndented(newXMLElement("meta", "property", "dcterms:modified"))
+	expected := `<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:creator opf:role="aut">Tom &amp; Jerry</dc:creator>
+    <meta property="dcterms:modified"/>
+  </metadata>`
+	if out := string(doc.bytes()); !strings.Contains(out, expected) {
+		t.Errorf("The metadata was not modified as expected:\n%v", out)
+	}
+}
@@ -43,6 +43,18 @@ func (e *ZipReader) OpenFile(name string) (io.ReadCloser, error) {
 	return openFile(e.zip, name)
 }
 
+// FileNames returns the names of all the files on the zip, in the order
+// they are stored
+func (e *ZipReader) FileNames() []string {
+	names := []string{}
+	for _, f := range e.zip.File {
+		if !strings.HasSuffix(f.Name, "/") {
+			names = append(names, f.Name)
+		}
+	}
+	return names
+}
+
 // Open opens an existing epub
 func NewZipReader(path string) (e *ZipReader, err error) {
 	e = new(ZipReader)
@@ -0,0 +1,390 @@
+// Package searchindex implements a full-text index over a collection of books
+//
+// Each document of the spine of a book is indexed on its own and the hits
+// are ranked with BM25. The index can be saved to disk and updated
+// incrementally when books are added, changed or removed:
+//
+//	idx, err := searchindex.Load("shelf.idx")
+//	idx.AddFile("path/of/the/file.epub")
+//	results := idx.Search("dog's tale", 10)
+//	err = idx.Save("shelf.idx")
+package searchindex
+
+import (
+	"encoding/gob"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ssor/epubgo/internal/docutil"
+	"github.com/ssor/epubgo/raw"
+)
+
+// BM25 parameters
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+const snippetLength = 40
+
+// Index is an inverted index of the text of a collection of books
+//
+// It is safe for concurrent use.
+type Index struct {
+	mu   sync.RWMutex
+	data indexData
+}
+
+// indexData is the persistent part of the index
+type indexData struct {
+	Books    map[string]*Book
+	Docs     map[int]*document
+	Postings map[string]map[int]posting
+	NextDoc  int
+	TotalLen int
+}
+
+// Book is a book on the index
+type Book struct {
+	ID      string
+	Title   string
+	ModTime time.Time
+	Size    int64
+	Docs    []int
+}
+
+// document is a document of the spine of a book
+type document struct {
+	Book       string
+	SpineIndex int
+	Href       string
+	Text       string
+	Length     int
+	Terms      []string
+	Chapters   []chapterMark
+}
+
+// chapterMark is the offset of the text where an entry of the index starts
+type chapterMark struct {
+	Offset int
+	Title  string
+}
+
+type posting struct {
+	Freq  int
+	First int
+}
+
+// Result is a document that matches a search
+type Result struct {
+	BookID     string
+	BookTitle  string
+	SpineIndex int
+	Href       string
+	Chapter    string
+	Snippet    string
+	Score      float64
+}
+
+// New creates an empty index
+func New() *Index {
+	return &Index{data: indexData{
+		Books:    make(map[string]*Book),
+		Docs:     make(map[int]*document),
+		Postings: make(map[string]map[int]posting),
+	}}
+}
+
+// Load reads an index saved with Save
+//
+// If the file doesn't exist an empty index is returned.
+func Load(path string) (*Index, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return New(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	idx := New()
+	if err := gob.NewDecoder(f).Decode(&idx.data); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// Save writes the index on the file path
+func (idx *Index) Save(path string) error {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := gob.NewEncoder(tmp).Encode(&idx.data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Books returns the books on the index
+func (idx *Index) Books() []Book {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	books := make([]Book, 0, len(idx.data.Books))
+	for _, book := range idx.data.Books {
+		books = append(books, *book)
+	}
+	sort.Slice(books, func(i, j int) bool { return books[i].ID < books[j].ID })
+	return books
+}
+
+// AddFile indexes the epub file on path using the path as id
+//
+// If the book is already on the index and the file didn't change since
+// then, it's not indexed again. Returns whether the book was indexed.
+func (idx *Index) AddFile(path string) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	idx.mu.RLock()
+	book, ok := idx.data.Books[path]
+	idx.mu.RUnlock()
+	if ok && book.ModTime.Equal(info.ModTime()) && book.Size == info.Size() {
+		return false, nil
+	}
+
+	e, err := raw.NewEpub(path)
+	if err != nil {
+		return false, err
+	}
+	defer e.Close()
+	if err := idx.addBook(&Book{ID: path, ModTime: info.ModTime(), Size: info.Size()}, e); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// AddBook indexes the documents of the spine of the book
+//
+// If there was already a book with the same id it gets replaced.
+func (idx *Index) AddBook(id string, e *raw.Epub) error {
+	return idx.addBook(&Book{ID: id}, e)
+}
+
+func (idx *Index) addBook(book *Book, e *raw.Epub) error {
+	id := book.ID
+	if titles, err := e.Metadata("title"); err == nil && len(titles) > 0 {
+		book.Title = titles[0]
+	}
+
+	it, err := e.Spine()
+	if err != nil {
+		return err
+	}
+	var docs []*document
+	for i := 0; ; i++ {
+		if item := e.FileManifest(it.URL()); item != nil && docutil.IsTextContent(item.MediaType) {
+			text, err := e.SpineText(i)
+			if err != nil {
+				return err
+			}
+			docs = append(docs, newDocument(id, e, text))
+		}
+		if it.Next() != nil {
+			break
+		}
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(id)
+	for _, doc := range docs {
+		book.Docs = append(book.Docs, idx.add(doc))
+	}
+	idx.data.Books[id] = book
+	return nil
+}
+
+// RemoveBook removes the book from the index, returns false if it was not there
+func (idx *Index) RemoveBook(id string) bool {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.remove(id)
+}
+
+// Search returns the documents that best match the query, at most limit of them
+//
+// A limit of 0 returns all the matching documents.
+func (idx *Index) Search(query string, limit int) []Result {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	terms := queryTerms(query)
+	if len(terms) == 0 || len(idx.data.Docs) == 0 {
+		return []Result{}
+	}
+
+	n := float64(len(idx.data.Docs))
+	avgLen := float64(idx.data.TotalLen) / n
+	scores := make(map[int]float64)
+	// the rarest term of each document is the one shown on the snippet
+	best := make(map[int]float64)
+	snippetAt := make(map[int]int)
+	for term := range terms {
+		postings := idx.data.Postings[term]
+		df := float64(len(postings))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for docID, p := range postings {
+			doc := idx.data.Docs[docID]
+			tf := float64(p.Freq)
+			norm := tf + k1*(1-b+b*float64(doc.Length)/avgLen)
+			scores[docID] += idf * tf * (k1 + 1) / norm
+			if idf > best[docID] {
+				best[docID] = idf
+				snippetAt[docID] = p.First
+			}
+		}
+	}
+
+	results := make([]Result, 0, len(scores))
+	for docID, score := range scores {
+		doc := idx.data.Docs[docID]
+		offset := snippetAt[docID]
+		results = append(results, Result{
+			BookID:     doc.Book,
+			BookTitle:  idx.data.Books[doc.Book].Title,
+			SpineIndex: doc.SpineIndex,
+			Href:       doc.Href,
+			Chapter:    doc.chapter(offset),
+			Snippet:    snippet(doc.Text, offset),
+			Score:      score,
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if results[i].BookID != results[j].BookID {
+			return results[i].BookID < results[j].BookID
+		}
+		return results[i].SpineIndex < results[j].SpineIndex
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+func newDocument(bookID string, e *raw.Epub, text *raw.DocumentText) *document {
+	doc := &document{
+		Book:       bookID,
+		SpineIndex: text.SpineIndex,
+		Href:       text.Href,
+		Text:       text.Text,
+	}
+	offsets := make([]int, len(text.Blocks))
+	for i, block := range text.Blocks {
+		offsets[i] = block.Start
+	}
+	var last *raw.NavPoint
+	for i, point := range e.NavPointsAt(text, offsets) {
+		if point != nil && point != last {
+			doc.Chapters = append(doc.Chapters, chapterMark{Offset: offsets[i], Title: point.Title()})
+			last = point
+		}
+	}
+	return doc
+}
+
+// add adds the document to the index and returns its id
+func (idx *Index) add(doc *document) int {
+	docID := idx.data.NextDoc
+	idx.data.NextDoc++
+
+	freqs := make(map[string]posting)
+	tokens := Tokenize(doc.Text)
+	for _, token := range tokens {
+		p, ok := freqs[token.Term]
+		if !ok {
+			p.First = token.Start
+			doc.Terms = append(doc.Terms, token.Term)
+		}
+		p.Freq++
+		freqs[token.Term] = p
+	}
+	for term, p := range freqs {
+		postings, ok := idx.data.Postings[term]
+		if !ok {
+			postings = make(map[int]posting)
+			idx.data.Postings[term] = postings
+		}
+		postings[docID] = p
+	}
+	doc.Length = len(tokens)
+	idx.data.TotalLen += doc.Length
+	idx.data.Docs[docID] = doc
+	return docID
+}
+
+func (idx *Index) remove(id string) bool {
+	book, ok := idx.data.Books[id]
+	if !ok {
+		return false
+	}
+	for _, docID := range book.Docs {
+		doc := idx.data.Docs[docID]
+		for _, term := range doc.Terms {
+			delete(idx.data.Postings[term], docID)
+			if len(idx.data.Postings[term]) == 0 {
+				delete(idx.data.Postings, term)
+			}
+		}
+		idx.data.TotalLen -= doc.Length
+		delete(idx.data.Docs, docID)
+	}
+	delete(idx.data.Books, id)
+	return true
+}
+
+// chapter returns the title of the entry of the index where the offset is
+func (doc document) chapter(offset int) string {
+	title := ""
+	for _, mark := range doc.Chapters {
+		if mark.Offset > offset {
+			break
+		}
+		title = mark.Title
+	}
+	return title
+}
+
+func snippet(text string, offset int) string {
+	from := offset
+	for i := 0; i < snippetLength && from > 0; i++ {
+		_, size := utf8.DecodeLastRuneInString(text[:from])
+		from -= size
+	}
+	to := offset
+	for i := 0; i < 2*snippetLength && to < len(text); i++ {
+		_, size := utf8.DecodeRuneInString(text[to:])
+		to += size
+	}
+	return strings.TrimSpace(strings.Replace(text[from:to], "\n", " ", -1))
+}
@@ -0,0 +1,115 @@
+package searchindex
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	dogsTalePath = "../testdata/a_dogs_tale.epub"
+	gcdxyPath    = "../testdata/gcdxy.epub"
+)
+
+func TestTokenize(t *testing.T) {
+	tokens := Tokenize("Crème brûlée, 共产党宣言")
+	terms := []string{}
+	for _, token := range tokens {
+		terms = append(terms, token.Term)
+	}
+	expected := "creme brulee 共 共产 产 产党 党 党宣 宣 宣言 言"
+	if strings.Join(terms, " ") != expected {
+		t.Errorf("Tokenize() return %v, the expected was %v", terms, expected)
+	}
+	if tokens[3].Start != len("Crème brûlée, ") || tokens[3].End != len("Crème brûlée, 共产") {
+		t.Errorf("the CJK bigram is at %v-%v", tokens[3].Start, tokens[3].End)
+	}
+
+	if terms := queryTerms("党 共产党"); len(terms) != 3 || !terms["党"] || !terms["共产"] || !terms["产党"] {
+		t.Errorf("the terms of the query are %v", terms)
+	}
+}
+
+func TestSearchCJKCharacter(t *testing.T) {
+	idx := New()
+	if _, err := idx.AddFile(gcdxyPath); err != nil {
+		t.Fatalf("AddFile(%v) return an error: %v", gcdxyPath, err)
+	}
+	results := idx.Search("党", 5)
+	if len(results) == 0 || !strings.Contains(results[0].Snippet, "党") {
+		t.Errorf("Search(党) return %v", results)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	idx := New()
+	for _, path := range []string{dogsTalePath, gcdxyPath} {
+		if _, err := idx.AddFile(path); err != nil {
+			t.Fatalf("AddFile(%v) return an error: %v", path, err)
+		}
+	}
+
+	results := idx.Search("puppy", 5)
+	if len(results) == 0 || results[0].BookID != dogsTalePath {
+		t.Fatalf("Search(puppy) return %v", results)
+	}
+	if !strings.Contains(strings.ToLower(results[0].Snippet), "puppy") {
+		t.Errorf("the snippet '%v' doesn't contain the query", results[0].Snippet)
+	}
+
+	results = idx.Search("海伦·麦克法兰", 5)
+	if len(results) == 0 || results[0].BookID != gcdxyPath {
+		t.Fatalf("Search(海伦·麦克法兰) return %v", results)
+	}
+	if results[0].Href != "chapter_00003.xhtml" || results[0].Chapter != "1872年德文版序言" {
+		t.Errorf("the first result is %v", results[0])
+	}
+	if results[0].BookTitle != "共产党宣言_B_1978_000042" {
+		t.Errorf("the book title is '%v'", results[0].BookTitle)
+	}
+}
+
+func TestIncremental(t *testing.T) {
+	idx := New()
+	idx.AddFile(dogsTalePath)
+	if added, _ := idx.AddFile(dogsTalePath); added {
+		t.Errorf("AddFile() indexed again a book that didn't change")
+	}
+	idx.AddFile(gcdxyPath)
+	if !idx.RemoveBook(dogsTalePath) {
+		t.Errorf("RemoveBook() didn't find the book")
+	}
+	if results := idx.Search("puppy", 0); len(results) != 0 {
+		t.Errorf("Search() found %v results of a removed book", len(results))
+	}
+	for term, postings := range idx.data.Postings {
+		for docID := range postings {
+			if idx.data.Docs[docID] == nil {
+				t.Fatalf("the term %v points to a removed document", term)
+			}
+		}
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shelf.idx")
+	idx, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() of a missing file return an error: %v", err)
+	}
+	idx.AddFile(gcdxyPath)
+	if err := idx.Save(path); err != nil {
+		t.Fatalf("Save() return an error: %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() return an error: %v", err)
+	}
+	if len(loaded.Books()) != 1 {
+		t.Errorf("the loaded index has %v books", len(loaded.Books()))
+	}
+	if results := loaded.Search("共产党", 1); len(results) != 1 {
+		t.Errorf("Search() on the loaded index return %v", results)
+	}
+}
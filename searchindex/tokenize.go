@@ -0,0 +1,102 @@
+package searchindex
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ssor/epubgo/internal/docutil"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Token is a term of the text and its byte offsets on it
+type Token struct {
+	Term  string
+	Start int
+	End   int
+}
+
+// Tokenize splits the text on terms for the index
+//
+// Words are lower cased and without diacritics. CJK text has no spaces
+// between words, it is split on its characters and overlapping bigrams
+// (共产党 -> 共, 共产, 产, 产党, 党) so single characters can be searched too.
+func Tokenize(text string) []Token {
+	tokens := []Token{}
+	wordStart := -1
+	var cjk []Token
+
+	flushWord := func(end int) {
+		if wordStart >= 0 {
+			tokens = append(tokens, Token{
+				Term:  foldTerm(text[wordStart:end]),
+				Start: wordStart,
+				End:   end,
+			})
+		}
+		wordStart = -1
+	}
+	flushCJK := func() {
+		for i := range cjk {
+			tokens = append(tokens, cjk[i])
+			if i+1 < len(cjk) {
+				tokens = append(tokens, Token{
+					Term:  cjk[i].Term + cjk[i+1].Term,
+					Start: cjk[i].Start,
+					End:   cjk[i+1].End,
+				})
+			}
+		}
+		cjk = cjk[:0]
+	}
+
+	for i, r := range text {
+		switch {
+		case docutil.IsCJK(r):
+			flushWord(i)
+			cjk = append(cjk, Token{Term: string(r), Start: i, End: i + utf8.RuneLen(r)})
+		case isWordRune(r):
+			flushCJK()
+			if wordStart < 0 {
+				wordStart = i
+			}
+		default:
+			flushWord(i)
+			flushCJK()
+		}
+	}
+	flushWord(len(text))
+	flushCJK()
+	return tokens
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+}
+
+// foldTerm lower cases the word and removes its diacritics
+func foldTerm(word string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(word) {
+		if !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return norm.NFC.String(b.String())
+}
+
+// queryTerms returns the terms of the query. The characters of the CJK
+// runs are left out when the run has bigrams, they would match too much.
+func queryTerms(query string) map[string]bool {
+	tokens := Tokenize(query)
+	terms := map[string]bool{}
+	for i, token := range tokens {
+		if r, size := utf8.DecodeRuneInString(token.Term); size == len(token.Term) && docutil.IsCJK(r) &&
+			(i+1 < len(tokens) && tokens[i+1].Start == token.Start ||
+				i > 0 && tokens[i-1].End == token.End && tokens[i-1].Start < token.Start) {
+			continue
+		}
+		terms[token.Term] = true
+	}
+	return terms
+}
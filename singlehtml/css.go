@@ -0,0 +1,277 @@
+package singlehtml
+
+import (
+	"regexp"
+	"strings"
+)
+
+// cssRule is a top level statement of a stylesheet
+//
+// For at-rules without block, like @import, block is empty and the
+// statement ends on a ';'.
+type cssRule struct {
+	prelude  string
+	block    string
+	hasBlock bool
+}
+
+// parseCSS splits the stylesheet on its top level statements
+func parseCSS(css string) []cssRule {
+	css = stripComments(css)
+	rules := []cssRule{}
+	start := 0
+	depth := 0
+	var quote byte
+	var rule cssRule
+	for i := 0; i < len(css); i++ {
+		ch := css[i]
+		switch {
+		case quote != 0:
+			if ch == '\\' {
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '{':
+			if depth == 0 {
+				rule.prelude = strings.TrimSpace(css[start:i])
+				start = i + 1
+			}
+			depth++
+		case ch == '}':
+			if depth == 0 {
+				continue
+			}
+			depth--
+			if depth == 0 {
+				rule.block = css[start:i]
+				rule.hasBlock = true
+				rules = append(rules, rule)
+				rule = cssRule{}
+				start = i + 1
+			}
+		case ch == ';' && depth == 0:
+			if prelude := strings.TrimSpace(css[start:i]); prelude != "" {
+				rules = append(rules, cssRule{prelude: prelude})
+			}
+			start = i + 1
+		}
+	}
+	return rules
+}
+
+func stripComments(css string) string {
+	var b strings.Builder
+	for {
+		i := strings.Index(css, "/*")
+		if i < 0 {
+			b.WriteString(css)
+			return b.String()
+		}
+		b.WriteString(css[:i])
+		end := strings.Index(css[i+2:], "*/")
+		if end < 0 {
+			return b.String()
+		}
+		css = css[i+2+end+2:]
+	}
+}
+
+// scopedCSS is a stylesheet whose rules only apply inside an element
+type scopedCSS struct {
+	rules     []string
+	fontFaces []string
+}
+
+// scopeCSS prefixes the selectors of the stylesheet with scope
+//
+// The url() of the declarations are rewritten with rewriteURL and the ids
+// of the #id selectors with rewriteID. @import rules call importCSS to get
+// the imported stylesheet already scoped. @font-face rules are global, they
+// are returned apart.
+func scopeCSS(css, scope string, rewriteURL, rewriteID func(string) string, importCSS func(string) scopedCSS) scopedCSS {
+	var out scopedCSS
+	for _, rule := range parseCSS(css) {
+		keyword := atKeyword(rule.prelude)
+		switch {
+		case keyword == "import":
+			if importURL := importTarget(rule.prelude); importURL != "" && importCSS != nil {
+				imported := importCSS(importURL)
+				out.rules = append(out.rules, imported.rules...)
+				out.fontFaces = append(out.fontFaces, imported.fontFaces...)
+			}
+		case keyword == "charset" || keyword == "namespace":
+		case keyword == "font-face":
+			out.fontFaces = append(out.fontFaces, "@font-face {"+rewriteURLs(rule.block, rewriteURL)+"}")
+		case (keyword == "media" || keyword == "supports" || keyword == "layer") && rule.hasBlock:
+			inner := scopeCSS(rule.block, scope, rewriteURL, rewriteID, importCSS)
+			out.rules = append(out.rules, rule.prelude+" {\n"+strings.Join(inner.rules, "\n")+"\n}")
+			out.fontFaces = append(out.fontFaces, inner.fontFaces...)
+		case keyword != "":
+			if rule.hasBlock {
+				out.rules = append(out.rules, rule.prelude+" {"+rewriteURLs(rule.block, rewriteURL)+"}")
+			}
+		case rule.hasBlock:
+			out.rules = append(out.rules, scopeSelectors(rule.prelude, scope, rewriteID)+" {"+rewriteURLs(rule.block, rewriteURL)+"}")
+		}
+	}
+	return out
+}
+
+// atKeyword returns the name of the at-rule without the '@', or "" if the
+// prelude is not an at-rule
+func atKeyword(prelude string) string {
+	if !strings.HasPrefix(prelude, "@") {
+		return ""
+	}
+	end := strings.IndexAny(prelude, " \t\n\r\"'(")
+	if end < 0 {
+		end = len(prelude)
+	}
+	keyword := strings.ToLower(prelude[1:end])
+	// vendor prefixes, like @-webkit-keyframes
+	if strings.HasPrefix(keyword, "-") {
+		if i := strings.Index(keyword[1:], "-"); i >= 0 {
+			keyword = keyword[i+2:]
+		}
+	}
+	return keyword
+}
+
+var importRegexp = regexp.MustCompile(`^@import\s+(?:url\(\s*)?["']?([^"')\s]+)`)
+
+func importTarget(prelude string) string {
+	if m := importRegexp.FindStringSubmatch(prelude); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+var urlRegexp = regexp.MustCompile(`url\(\s*(?:"([^"]*)"|'([^']*)'|([^)"'\s]*))\s*\)`)
+
+// rewriteURLs replaces the url() of the declarations by the ones returned by rewrite
+func rewriteURLs(declarations string, rewrite func(string) string) string {
+	if rewrite == nil {
+		return declarations
+	}
+	return urlRegexp.ReplaceAllStringFunc(declarations, func(u string) string {
+		m := urlRegexp.FindStringSubmatch(u)
+		target := m[1] + m[2] + m[3]
+		return `url("` + rewrite(target) + `")`
+	})
+}
+
+// scopeSelectors prefixes each selector of the list with scope and renames
+// the ids of the #id selectors with rewriteID, if not nil
+//
+// The html, body and :root elements are the chapter itself, they are
+// replaced by the scope.
+func scopeSelectors(selectors, scope string, rewriteID func(string) string) string {
+	scoped := []string{}
+	for _, selector := range splitSelectors(selectors) {
+		selector = rewriteIDSelectors(strings.TrimSpace(selector), rewriteID)
+		if selector == "" {
+			continue
+		}
+		rest := selector
+		isRoot := false
+		for {
+			trimmed := strings.TrimLeft(rest, " \t\n>")
+			word := rootSelector(trimmed)
+			if word == "" {
+				break
+			}
+			rest = trimmed[len(word):]
+			isRoot = true
+		}
+		if isRoot {
+			scoped = append(scoped, scope+rest)
+		} else {
+			scoped = append(scoped, scope+" "+selector)
+		}
+	}
+	return strings.Join(scoped, ", ")
+}
+
+// rewriteIDSelectors replaces the ids of the #id selectors by the ones
+// returned by rewrite, the strings and attribute selectors are left as they
+// are
+func rewriteIDSelectors(selector string, rewrite func(string) string) string {
+	if rewrite == nil || !strings.Contains(selector, "#") {
+		return selector
+	}
+	var b strings.Builder
+	var quote byte
+	brackets := 0
+	for i := 0; i < len(selector); i++ {
+		ch := selector[i]
+		switch {
+		case quote != 0:
+			if ch == '\\' && i+1 < len(selector) {
+				b.WriteByte(ch)
+				i++
+				ch = selector[i]
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '[':
+			brackets++
+		case ch == ']':
+			brackets--
+		case ch == '#' && brackets == 0:
+			end := i + 1
+			for end < len(selector) && (isIdentChar(selector[end]) || selector[end] >= 0x80) {
+				end++
+			}
+			if end > i+1 {
+				b.WriteString("#" + rewrite(selector[i+1:end]))
+				i = end - 1
+				continue
+			}
+		}
+		b.WriteByte(ch)
+	}
+	return b.String()
+}
+
+func rootSelector(selector string) string {
+	for _, word := range []string{":root", "html", "body"} {
+		if !strings.HasPrefix(strings.ToLower(selector), word) {
+			continue
+		}
+		if len(selector) == len(word) || !isIdentChar(selector[len(word)]) {
+			return selector[:len(word)]
+		}
+	}
+	return ""
+}
+
+func isIdentChar(ch byte) bool {
+	return ch == '-' || ch == '_' || ch >= '0' && ch <= '9' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z'
+}
+
+// splitSelectors splits a selector list on the commas that are not inside
+// parenthesis or brackets
+func splitSelectors(selectors string) []string {
+	parts := []string{}
+	depth := 0
+	start := 0
+	for i := 0; i < len(selectors); i++ {
+		switch selectors[i] {
+		case '(', '[':
+			depth++
+		case ')', ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, selectors[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, selectors[start:])
+}
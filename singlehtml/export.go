@@ -0,0 +1,494 @@
+// Package singlehtml exports epub books as a single self-contained HTML file
+//
+// The documents of the spine are concatenated in order, each one on its own
+// section with its stylesheets scoped to it. Images and fonts are inlined
+// as data URIs and the index of the book is rendered as a sidebar. The
+// documents are sanitized first, no script of the book runs on the page:
+//
+//	book, _ := raw.NewEpub("path/of/the/file.epub")
+//	err := singlehtml.ExportFile(book, "book.html", singlehtml.Options{})
+package singlehtml
+
+import (
+	"bytes"
+	"encoding/base64"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"mime"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ssor/epubgo/internal/docutil"
+	"github.com/ssor/epubgo/raw"
+	"golang.org/x/net/html"
+)
+
+// Options configures the export
+type Options struct {
+	// Title of the page, the title of the book if empty
+	Title string
+}
+
+// ExportFile exports the book as a single HTML file on path
+func ExportFile(e *raw.Epub, path string, opts Options) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return Export(e, f, opts)
+}
+
+// Export writes the book as a single HTML page on w
+func Export(e *raw.Epub, w io.Writer, opts Options) error {
+	x := exporter{
+		epub:      e,
+		chapters:  make(map[string]string),
+		dataURIs:  make(map[string]string),
+		scopes:    make(map[string]string),
+		fontFaces: make(map[string]bool),
+		policy:    raw.DefaultSanitizePolicy(),
+	}
+	x.policy.RemoteResources = true
+	if opts.Title == "" {
+		if titles, err := e.Metadata("title"); err == nil && len(titles) > 0 {
+			opts.Title = titles[0]
+		}
+	}
+
+	it, err := e.Spine()
+	if err != nil {
+		return err
+	}
+	hrefs := []string{}
+	for {
+		href := it.URL()
+		if item := e.FileManifest(href); item != nil && docutil.IsTextContent(item.MediaType) {
+			x.chapters[docutil.CleanHref(href)] = "ch" + strconv.Itoa(len(hrefs))
+			hrefs = append(hrefs, href)
+		}
+		if it.Next() != nil {
+			break
+		}
+	}
+
+	var sections bytes.Buffer
+	for _, href := range hrefs {
+		if err := x.chapter(&sections, href); err != nil {
+			return err
+		}
+	}
+
+	page := pageData{
+		Title:    opts.Title,
+		Language: firstMetadata(e, "language"),
+		CSS:      template.CSS(escapeStyleEnd(strings.Join(append(x.fontFaceList, x.css...), "\n"))),
+		TOC:      template.HTML(x.toc(e.NavPoints())),
+		Content:  template.HTML(sections.String()),
+	}
+	return pageTemplate.Execute(w, page)
+}
+
+type exporter struct {
+	epub *raw.Epub
+	// section id of each document of the spine by href
+	chapters map[string]string
+	dataURIs map[string]string
+	// class of each set of stylesheets
+	scopes       map[string]string
+	css          []string
+	fontFaces    map[string]bool
+	fontFaceList []string
+	// policy to sanitize the documents, the page runs the scripts of none
+	policy *raw.SanitizePolicy
+}
+
+func (x *exporter) chapter(w io.Writer, href string) error {
+	doc, err := x.epub.ParseDocument(href)
+	if err != nil {
+		return err
+	}
+	raw.SanitizeDocument(doc, x.policy)
+	chapterID := x.chapters[docutil.CleanHref(href)]
+
+	section := &html.Node{Type: html.ElementNode, Data: "section"}
+	classes := []string{"chapter"}
+	if scope := x.styleScope(doc, href, chapterID); scope != "" {
+		classes = append(classes, scope)
+	}
+	lang := ""
+	if root := docutil.FindElement(doc, "html"); root != nil {
+		lang = docutil.Attr(root, "xml:lang")
+		if lang == "" {
+			lang = docutil.Attr(root, "lang")
+		}
+	}
+
+	body := docutil.FindElement(doc, "body")
+	if body != nil {
+		if class := docutil.Attr(body, "class"); class != "" {
+			classes = append(classes, class)
+		}
+		if id := docutil.Attr(body, "id"); id != "" {
+			section.AppendChild(&html.Node{
+				Type: html.ElementNode,
+				Data: "span",
+				Attr: []html.Attribute{{Key: "id", Val: chapterID + "-" + id}},
+			})
+		}
+		for child := body.FirstChild; child != nil; {
+			next := child.NextSibling
+			body.RemoveChild(child)
+			section.AppendChild(child)
+			child = next
+		}
+	}
+
+	section.Attr = []html.Attribute{
+		{Key: "id", Val: chapterID},
+		{Key: "class", Val: strings.Join(classes, " ")},
+	}
+	if lang != "" {
+		section.Attr = append(section.Attr, html.Attribute{Key: "lang", Val: lang})
+	}
+	for child := section.FirstChild; child != nil; child = child.NextSibling {
+		x.rewrite(child, href, chapterID)
+	}
+	if err := html.Render(w, section); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// rewrite makes the ids unique, points the links to the sections and
+// inlines the images of n and the elements under it
+//
+// The attributes referencing ids, like for, aria-labelledby or the href of
+// an SVG use, follow the renamed ids. The scripts are removed, they were
+// written for the document alone, and so are the stylesheets, already
+// scoped to the section by styleScope.
+func (x *exporter) rewrite(n *html.Node, href, chapterID string) {
+	for child := n.FirstChild; child != nil; {
+		next := child.NextSibling
+		if child.Type == html.ElementNode && (child.Data == "script" || child.Data == "noscript" || child.Data == "style" ||
+			child.Data == "link" && hasWord(docutil.Attr(child, "rel"), "stylesheet")) {
+			n.RemoveChild(child)
+		} else {
+			x.rewrite(child, href, chapterID)
+		}
+		child = next
+	}
+	if n.Type != html.ElementNode {
+		return
+	}
+
+	rewriteURL := func(u string) string {
+		if strings.HasPrefix(u, "#") {
+			return "#" + chapterID + "-" + u[1:]
+		}
+		return x.dataURI(href, u)
+	}
+	attrs := n.Attr[:0]
+	for _, a := range n.Attr {
+		isHref := a.Key == "href" || a.Key == "xlink:href"
+		switch {
+		case a.Key == "id":
+			a.Val = chapterID + "-" + a.Val
+		case isHref && (n.Data == "a" || n.Data == "area"):
+			a.Val = x.link(href, a.Val)
+		case isHref && strings.HasPrefix(a.Val, "#"):
+			a.Val = "#" + chapterID + "-" + a.Val[1:]
+		case a.Key == "src" && n.Data == "img", isHref && n.Data == "image":
+			a.Val = x.dataURI(href, a.Val)
+		case idRefAttributes[a.Key]:
+			ids := strings.Fields(a.Val)
+			for i, id := range ids {
+				ids[i] = chapterID + "-" + id
+			}
+			a.Val = strings.Join(ids, " ")
+		case a.Key == "srcset":
+			continue
+		case a.Key == "style", n.Namespace == "svg" && strings.Contains(a.Val, "url("):
+			a.Val = rewriteURLs(a.Val, rewriteURL)
+		}
+		attrs = append(attrs, a)
+	}
+	n.Attr = attrs
+}
+
+// idRefAttributes are the attributes whose value is a list of ids of the
+// document
+var idRefAttributes = map[string]bool{
+	"for":                   true,
+	"form":                  true,
+	"headers":               true,
+	"list":                  true,
+	"aria-activedescendant": true,
+	"aria-controls":         true,
+	"aria-describedby":      true,
+	"aria-details":          true,
+	"aria-errormessage":     true,
+	"aria-flowto":           true,
+	"aria-labelledby":       true,
+	"aria-owns":             true,
+}
+
+// link points the links to documents of the spine to their sections
+func (x *exporter) link(base, href string) string {
+	if isExternal(href) {
+		return href
+	}
+	file, fragment := docutil.SplitFragment(href)
+	target := base
+	if file != "" {
+		target = docutil.ResolveHref(base, file)
+	}
+	chapterID, ok := x.chapters[docutil.CleanHref(target)]
+	if !ok {
+		return href
+	}
+	if fragment == "" {
+		return "#" + chapterID
+	}
+	return "#" + chapterID + "-" + fragment
+}
+
+// dataURI returns the resource url, relative to the file base, as a data URI
+func (x *exporter) dataURI(base, url string) string {
+	if url == "" || isExternal(url) {
+		return url
+	}
+	file, _ := docutil.SplitFragment(url)
+	href := docutil.ResolveHref(base, file)
+	if uri, ok := x.dataURIs[href]; ok {
+		return uri
+	}
+
+	f, err := x.epub.OpenResource(href)
+	if err != nil {
+		return url
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return url
+	}
+	mediaType := mime.TypeByExtension(path.Ext(href))
+	if item := x.epub.FileManifest(href); item != nil && item.MediaType != "" {
+		mediaType = item.MediaType
+	}
+	uri := "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data)
+	x.dataURIs[href] = uri
+	return uri
+}
+
+// styleScope collects the stylesheets of the document and returns the class
+// their rules are scoped to
+//
+// Documents with the same stylesheets share the same class, unless the
+// stylesheets have #id selectors: the ids are renamed for each chapter and
+// so are the selectors, on a class of the chapter alone.
+func (x *exporter) styleScope(doc *html.Node, href, chapterID string) string {
+	type sheet struct {
+		href    string
+		content string
+	}
+	sheets := []sheet{}
+	key := ""
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch {
+			case n.Data == "link" && hasWord(docutil.Attr(n, "rel"), "stylesheet") && !isExternal(docutil.Attr(n, "href")):
+				sheetHref := docutil.ResolveHref(href, docutil.Attr(n, "href"))
+				sheets = append(sheets, sheet{href: sheetHref, content: x.readText(sheetHref)})
+				key += "link:" + sheetHref + "\n"
+			case n.Data == "style":
+				content := textContent(n)
+				sheets = append(sheets, sheet{href: href, content: content})
+				key += "style:" + href + ":" + content + "\n"
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	collect(doc)
+	if len(sheets) == 0 {
+		return ""
+	}
+	if scope, ok := x.scopes[key]; ok {
+		return scope
+	}
+
+	scope := "epub-css-" + strconv.Itoa(len(x.scopes))
+	hasIDs := false
+	rewriteID := func(id string) string {
+		hasIDs = true
+		return chapterID + "-" + id
+	}
+	for _, s := range sheets {
+		scoped := x.scopeSheet(s.content, s.href, scope, rewriteID, map[string]bool{s.href: true})
+		x.css = append(x.css, scoped.rules...)
+		for _, fontFace := range scoped.fontFaces {
+			if !x.fontFaces[fontFace] {
+				x.fontFaces[fontFace] = true
+				x.fontFaceList = append(x.fontFaceList, fontFace)
+			}
+		}
+	}
+	if hasIDs {
+		key += "chapter:" + chapterID
+	}
+	x.scopes[key] = scope
+	return scope
+}
+
+// scopeSheet scopes the css of the file href, following its imports
+func (x *exporter) scopeSheet(css, href, scope string, rewriteID func(string) string, visited map[string]bool) scopedCSS {
+	rewriteURL := func(u string) string {
+		if strings.HasPrefix(u, "#") {
+			return "#" + rewriteID(u[1:])
+		}
+		return x.dataURI(href, u)
+	}
+	importCSS := func(u string) scopedCSS {
+		if isExternal(u) {
+			return scopedCSS{}
+		}
+		imported := docutil.ResolveHref(href, u)
+		if visited[imported] {
+			return scopedCSS{}
+		}
+		visited[imported] = true
+		return x.scopeSheet(x.readText(imported), imported, scope, rewriteID, visited)
+	}
+	return scopeCSS(css, "."+scope, rewriteURL, rewriteID, importCSS)
+}
+
+func (x *exporter) readText(href string) string {
+	f, err := x.epub.OpenResource(href)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return ""
+	}
+	return string(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+}
+
+// toc renders the navigation points as nested lists of links to the sections
+func (x *exporter) toc(points raw.NavPointArray) string {
+	if len(points) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("<ol>")
+	for _, point := range points {
+		file, fragment := x.epub.NavPointHref(point)
+		target := "#"
+		if chapterID, ok := x.chapters[docutil.CleanHref(file)]; ok {
+			target += chapterID
+			if fragment != "" {
+				target += "-" + fragment
+			}
+		}
+		b.WriteString(`<li><a href="` + html.EscapeString(target) + `">` + html.EscapeString(point.Title()) + "</a>")
+		b.WriteString(x.toc(point.Children()))
+		b.WriteString("</li>")
+	}
+	b.WriteString("</ol>")
+	return b.String()
+}
+
+func firstMetadata(e *raw.Epub, field string) string {
+	values, err := e.Metadata(field)
+	if err != nil || len(values) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(values[0])
+}
+
+var styleEnd = regexp.MustCompile(`(?i)</(style)`)
+
+// escapeStyleEnd escapes the end tags of style on the css, so the book
+// can't close the style element of the page
+func escapeStyleEnd(css string) string {
+	return styleEnd.ReplaceAllString(css, `<\/$1`)
+}
+
+var schemeRegexp = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+
+func isExternal(url string) bool {
+	return schemeRegexp.MatchString(url)
+}
+
+func hasWord(list, word string) bool {
+	for _, w := range strings.Fields(strings.ToLower(list)) {
+		if w == word {
+			return true
+		}
+	}
+	return false
+}
+
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(textContent(c))
+	}
+	return b.String()
+}
+
+type pageData struct {
+	Title    string
+	Language string
+	CSS      template.CSS
+	TOC      template.HTML
+	Content  template.HTML
+}
+
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html{{if .Language}} lang="{{.Language}}"{{end}}>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { margin: 0; }
+#epub-toc { position: fixed; top: 0; bottom: 0; left: 0; width: 18em; overflow-y: auto; box-sizing: border-box; padding: 1em; border-right: 1px solid #ccc; font-family: sans-serif; font-size: 0.9em; }
+#epub-toc ol { list-style: none; margin: 0; padding-left: 1em; }
+#epub-toc > ol { padding-left: 0; }
+#epub-toc a { text-decoration: none; }
+#epub-content { margin-left: 18em; padding: 1em 2em; }
+#epub-content > section.chapter + section.chapter { border-top: 1px solid #ccc; }
+@media print {
+	#epub-toc { display: none; }
+	#epub-content { margin-left: 0; }
+	#epub-content > section.chapter { page-break-before: always; }
+}
+</style>
+<style>
+{{.CSS}}
+</style>
+</head>
+<body>
+<nav id="epub-toc">
+<h1>{{.Title}}</h1>
+{{.TOC}}
+</nav>
+<main id="epub-content">
+{{.Content}}
+</main>
+</body>
+</html>
+`))
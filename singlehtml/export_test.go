@@ -0,0 +1,218 @@
+package singlehtml
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ssor/epubgo/internal/docutil"
+	"github.com/ssor/epubgo/raw"
+	"golang.org/x/net/html"
+)
+
+const (
+	gcdxyPath = "../testdata/gcdxy.epub"
+)
+
+func TestExport(t *testing.T) {
+	book, err := raw.NewEpub(gcdxyPath)
+	if err != nil {
+		t.Fatalf("NewEpub(%v) return an error: %v", gcdxyPath, err)
+	}
+	defer book.Close()
+
+	var buff bytes.Buffer
+	if err := Export(book, &buff, Options{}); err != nil {
+		t.Fatalf("Export() return an error: %v", err)
+	}
+	page := buff.String()
+
+	for _, expected := range []string{
+		"<title>共产党宣言_B_1978_000042</title>",
+		`<section id="ch0" class="chapter epub-css-0" lang="zh-CN">`,
+		`<h2 id="ch4-CHP2-1">`,
+		`<a href="#ch4-CHP2-1">1872年德文版序言</a>`,
+		`href="#ch4-wz_2_13"`,
+		`src="data:image/jpeg;base64,`,
+		".epub-css-0 p {",
+	} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("the page doesn't contain %q", expected)
+		}
+	}
+	if strings.Count(page, ".epub-css-0 p {") != 1 {
+		t.Errorf("the stylesheet shared by all the chapters is repeated")
+	}
+	if strings.Contains(page, `src="images/`) {
+		t.Errorf("there are images not inlined")
+	}
+}
+
+func TestScopeSelectors(t *testing.T) {
+	tests := []struct {
+		selectors string
+		scoped    string
+	}{
+		{"p", ".s p"},
+		{"h1, h2.title", ".s h1, .s h2.title"},
+		{"body", ".s"},
+		{"html body > p", ".s > p"},
+		{"body.dark a", ".s.dark a"},
+		{"bodytext", ".s bodytext"},
+		{":is(p, li) em", ".s :is(p, li) em"},
+		{"#note", ".s #ch0-note"},
+		{"p#note > a, :not(#x)", ".s p#ch0-note > a, .s :not(#ch0-x)"},
+		{`a[href="#note"]`, `.s a[href="#note"]`},
+	}
+	rewriteID := func(id string) string { return "ch0-" + id }
+	for _, test := range tests {
+		if scoped := scopeSelectors(test.selectors, ".s", rewriteID); scoped != test.scoped {
+			t.Errorf("scopeSelectors(%q) return %q, the expected was %q", test.selectors, scoped, test.scoped)
+		}
+	}
+}
+
+func TestScopeCSS(t *testing.T) {
+	css := `@charset "utf-8";
+@import url("other.css");
+/* a comment { */
+@font-face { font-family: "Serif"; src: url(fonts/serif.otf) }
+@media screen { p { background: url('bg.png') } }
+p { content: "}" }`
+	imported := 0
+	scoped := scopeCSS(css, ".s", func(u string) string { return "data:" + u }, nil,
+		func(u string) scopedCSS {
+			imported++
+			return scopedCSS{rules: []string{".s em {}"}}
+		})
+
+	if imported != 1 {
+		t.Errorf("the import was followed %v times", imported)
+	}
+	if len(scoped.fontFaces) != 1 || !strings.Contains(scoped.fontFaces[0], `url("data:fonts/serif.otf")`) {
+		t.Errorf("unexpected font faces: %v", scoped.fontFaces)
+	}
+	rules := strings.Join(scoped.rules, "\n")
+	for _, expected := range []string{".s em {}", "@media screen {\n.s p { background: url(\"data:bg.png\") }", `.s p { content: "}" }`} {
+		if !strings.Contains(rules, expected) {
+			t.Errorf("the scoped rules don't contain %q:\n%v", expected, rules)
+		}
+	}
+}
+
+func TestRewriteStyles(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<html><body><p>text</p><style>p { color: red }</style>` +
+		`<div><link rel="stylesheet" href="a.css"><script>alert(1)</script></div></body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := exporter{chapters: map[string]string{"text.xhtml": "ch0"}}
+	body := docutil.FindElement(doc, "body")
+	x.rewrite(body, "text.xhtml", "ch0")
+	var b strings.Builder
+	html.Render(&b, body)
+	if out := b.String(); out != "<body><p>text</p><div></div></body>" {
+		t.Errorf("the rewritten body is %q", out)
+	}
+
+	if css := escapeStyleEnd(`p::after { content: "</STYLE><script>alert(1)</script>" }`); strings.Contains(strings.ToLower(css), "</style") {
+		t.Errorf("the end of the style element was not escaped: %q", css)
+	}
+}
+
+func TestRewriteIDs(t *testing.T) {
+	const page = `<html><head><style>#note { color: red } p { margin: 0 }</style></head><body>
+<label for="name">Name</label><input id="name" aria-describedby="hint note">
+<p id="note">note</p><p id="hint">hint</p>
+<svg><defs><linearGradient id="g"></linearGradient></defs><circle id="c" fill="url(#g)"></circle><use xlink:href="#c"></use></svg>
+</body></html>`
+	x := exporter{
+		chapters: map[string]string{"text.xhtml": "ch0", "other.xhtml": "ch1"},
+		scopes:   make(map[string]string),
+	}
+	for i, chapterID := range []string{"ch0", "ch1"} {
+		doc, _ := html.Parse(strings.NewReader(page))
+		if scope := x.styleScope(doc, "text.xhtml", chapterID); scope != "epub-css-"+strconv.Itoa(i) {
+			t.Errorf("the scope of %v is %q", chapterID, scope)
+		}
+	}
+	css := strings.Join(x.css, "\n")
+	for _, expected := range []string{".epub-css-0 #ch0-note { color: red }", ".epub-css-1 #ch1-note { color: red }"} {
+		if !strings.Contains(css, expected) {
+			t.Errorf("the css doesn't contain %q:\n%v", expected, css)
+		}
+	}
+
+	doc, _ := html.Parse(strings.NewReader(page))
+	body := docutil.FindElement(doc, "body")
+	x.rewrite(body, "text.xhtml", "ch0")
+	var b strings.Builder
+	html.Render(&b, body)
+	out := b.String()
+	for _, expected := range []string{
+		`<label for="ch0-name">`,
+		`<input id="ch0-name" aria-describedby="ch0-hint ch0-note"/>`,
+		`<circle id="ch0-c" fill="url(&#34;#ch0-g&#34;)">`,
+		`<use xlink:href="#ch0-c">`,
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("the body doesn't contain %q:\n%v", expected, out)
+		}
+	}
+}
+
+// writeEpub writes an epub with the files, besides the mimetype and the
+// container, and returns its path
+func writeEpub(t *testing.T, files map[string]string) string {
+	name := filepath.Join(t.TempDir(), "book.epub")
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	files["mimetype"] = "application/epub+zip"
+	files["META-INF/container.xml"] = `<container><rootfiles><rootfile full-path="content.opf"/></rootfiles></container>`
+	for name, content := range files {
+		w, _ := zw.Create(name)
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestExportSanitized(t *testing.T) {
+	book, err := raw.NewEpub(writeEpub(t, map[string]string{
+		"content.opf": `<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>Unsafe</dc:title></metadata>
+<manifest><item id="c1" href="c1.xhtml" media-type="application/xhtml+xml"/></manifest>
+<spine><itemref idref="c1"/></spine></package>`,
+		"c1.xhtml": `<html xmlns="http://www.w3.org/1999/xhtml"><body onload="steal()">
+<p onclick="steal()">text <a href="javascript:steal()">bad</a> <a href="http://example.com/">good</a></p>
+<img src="http://example.com/a.png" onerror="steal()"/></body></html>`,
+	}))
+	if err != nil {
+		t.Fatalf("Can't open the book: %v", err)
+	}
+	defer book.Close()
+
+	var buff bytes.Buffer
+	if err := Export(book, &buff, Options{}); err != nil {
+		t.Fatalf("Export() return an error: %v", err)
+	}
+	page := buff.String()
+	if strings.Contains(page, "steal") || strings.Contains(page, "javascript:") {
+		t.Errorf("the page has scripts of the book:\n%s", page)
+	}
+	for _, expected := range []string{`<a href="http://example.com/">good</a>`, `<img src="http://example.com/a.png"/>`} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("the page doesn't contain %q", expected)
+		}
+	}
+}